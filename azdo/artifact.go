@@ -0,0 +1,125 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package azdo
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/build"
+)
+
+// DownloadArtifact downloads the named artifact of the given build and extracts it into destDir.
+// AzDO artifact zips contain a top-level dir named after the artifact, so the returned path is
+// destDir/artifactName, the dir that contains the artifact's files.
+func DownloadArtifact(ctx context.Context, conn *azuredevops.Connection, project string, buildID int, artifactName, destDir string) (string, error) {
+	c, err := build.NewClient(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	a, err := c.GetArtifact(ctx, build.GetArtifactArgs{
+		Project:      &project,
+		BuildId:      &buildID,
+		ArtifactName: &artifactName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get artifact %q of build %v: %w", artifactName, buildID, err)
+	}
+	if a.Resource == nil || a.Resource.DownloadUrl == nil || *a.Resource.DownloadUrl == "" {
+		return "", fmt.Errorf("artifact %q of build %v has no download URL", artifactName, buildID)
+	}
+
+	zipFile, err := os.CreateTemp("", "azdo-artifact-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		zipFile.Close()
+		if err := os.Remove(zipFile.Name()); err != nil {
+			log.Printf("Unable to clean up temp artifact zip %#q: %v\n", zipFile.Name(), err)
+		}
+	}()
+
+	log.Printf("Downloading artifact %q from %v\n", artifactName, *a.Resource.DownloadUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", *a.Resource.DownloadUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", conn.AuthorizationString)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download artifact %q: http status %v", artifactName, resp.Status)
+	}
+	size, err := io.Copy(zipFile, resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := zip.NewReader(zipFile, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to read artifact %q zip: %w", artifactName, err)
+	}
+	if err := extractZip(r, destDir); err != nil {
+		return "", fmt.Errorf("failed to extract artifact %q: %w", artifactName, err)
+	}
+	return filepath.Join(destDir, artifactName), nil
+}
+
+// extractZip extracts every file in r into destDir. Returns an error if a file's path would place
+// it outside destDir.
+func extractZip(r *zip.Reader, destDir string) error {
+	absDestDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		path := filepath.Join(absDestDir, filepath.FromSlash(f.Name))
+		if path != absDestDir && !strings.HasPrefix(path, absDestDir+string(filepath.Separator)) {
+			return fmt.Errorf("zip entry %q is outside the destination dir", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractZipFile(f, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, path string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, dst.Close())
+	}()
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package azdo
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestZip(t *testing.T, files map[string]string) *zip.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func Test_extractZip(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestZip(t, map[string]string{
+		"Binaries/go.tar.gz":        "archive",
+		"Binaries/sub/assets.json":  "{}",
+		"Binaries/go.tar.gz.sha256": "hash",
+	})
+	if err := extractZip(r, dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"Binaries/go.tar.gz":       "archive",
+		"Binaries/sub/assets.json": "{}",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%v content = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_extractZip_OutsideDest(t *testing.T) {
+	r := newTestZip(t, map[string]string{
+		"../escape.txt": "bad",
+	})
+	if err := extractZip(r, t.TempDir()); err == nil {
+		t.Error("expected error extracting a file outside the destination dir, got nil")
+	}
+}
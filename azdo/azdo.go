@@ -7,8 +7,10 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/microsoft/azure-devops-go-api/azuredevops"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/build"
@@ -73,16 +75,52 @@ func GetBuildWebURL(b *build.Build) (string, bool) {
 	return s, true
 }
 
+// stdout is where logging commands are written. Tests replace it to capture the output.
+var stdout io.Writer = os.Stdout
+
+// InPipeline returns true if this process is running inside an Azure Pipelines agent, based on the
+// TF_BUILD and SYSTEM_TEAMFOUNDATIONCOLLECTIONURI env vars that the agent sets for every task.
+func InPipeline() bool {
+	return strings.EqualFold(os.Getenv("TF_BUILD"), "true") ||
+		os.Getenv("SYSTEM_TEAMFOUNDATIONCOLLECTIONURI") != ""
+}
+
 // LogCmdSetVariable uses an AzDO logging command to set a variable in the current (build) context.
+// If not running in a pipeline, prints the name and value plainly instead.
 // https://docs.microsoft.com/en-us/azure/devops/pipelines/scripts/logging-commands?view=azure-devops&tabs=bash#setvariable-initialize-or-modify-the-value-of-a-variable
 func LogCmdSetVariable(name, value string) {
-	fmt.Printf("##vso[task.setvariable variable=%v]%v\n", name, value)
+	if !InPipeline() {
+		fmt.Fprintf(stdout, "%v=%v\n", name, value)
+		return
+	}
+	fmt.Fprintf(stdout, "##vso[task.setvariable variable=%v]%v\n", name, value)
+}
+
+// LogCmdGroup uses an AzDO formatting command to start a collapsible group of log lines with the
+// given name. End the group with LogCmdEndGroup. If not running in a pipeline, prints the name as a
+// plain header instead.
+// https://learn.microsoft.com/en-us/azure/devops/pipelines/scripts/logging-commands?view=azure-devops&tabs=bash#formatting-commands
+func LogCmdGroup(name string) {
+	if !InPipeline() {
+		fmt.Fprintf(stdout, "---- %v\n", name)
+		return
+	}
+	fmt.Fprintf(stdout, "##[group]%v\n", name)
+}
+
+// LogCmdEndGroup uses an AzDO formatting command to end the group started by LogCmdGroup. If not
+// running in a pipeline, does nothing.
+func LogCmdEndGroup() {
+	if !InPipeline() {
+		return
+	}
+	fmt.Fprintln(stdout, "##[endgroup]")
 }
 
 // LogCmdPrependPath uses an AzDO logging command to prepend a path to future steps' PATH env vars.
 // https://learn.microsoft.com/en-us/azure/devops/pipelines/scripts/logging-commands?view=azure-devops&tabs=bash#prependpath-prepend-a-path-to-the--path-environment-variable
 func LogCmdPrependPath(path string) {
-	fmt.Printf("##vso[task.prependpath]%v\n", path)
+	fmt.Fprintf(stdout, "##vso[task.prependpath]%v\n", path)
 }
 
 // LogCmdUploadSummary uses an AzDO logging command to upload a summary file. The file is shown on
@@ -90,7 +128,14 @@ func LogCmdPrependPath(path string) {
 // Markdown features. The path must be a full path.
 // https://docs.microsoft.com/en-us/azure/devops/pipelines/scripts/logging-commands?view=azure-devops&tabs=bash#uploadsummary-add-some-markdown-content-to-the-build-summary
 func LogCmdUploadSummary(path string) {
-	fmt.Printf("##vso[task.uploadsummary]%v\n", path)
+	fmt.Fprintf(stdout, "##vso[task.uploadsummary]%v\n", path)
+}
+
+// LogCmdUploadArtifact uses an AzDO logging command to upload a file to the named pipeline
+// artifact, in the given folder inside the artifact. The path must be a full path.
+// https://learn.microsoft.com/en-us/azure/devops/pipelines/scripts/logging-commands?view=azure-devops&tabs=bash#upload-upload-an-artifact
+func LogCmdUploadArtifact(containerFolder, artifactName, path string) {
+	fmt.Fprintf(stdout, "##vso[artifact.upload containerfolder=%v;artifactname=%v]%v\n", containerFolder, artifactName, path)
 }
 
 // AzDOBuildDetectionDoc describes how AzDO build detection works, listing the env vars used. Use
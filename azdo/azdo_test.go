@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package azdo
+
+import (
+	"bytes"
+	"testing"
+)
+
+// setInPipeline sets the env vars checked by InPipeline and captures logging command output.
+func setInPipeline(t *testing.T, inPipeline bool) *bytes.Buffer {
+	t.Helper()
+	tfBuild := ""
+	if inPipeline {
+		tfBuild = "True"
+	}
+	t.Setenv("TF_BUILD", tfBuild)
+	t.Setenv("SYSTEM_TEAMFOUNDATIONCOLLECTIONURI", "")
+
+	var buf bytes.Buffer
+	oldStdout := stdout
+	stdout = &buf
+	t.Cleanup(func() { stdout = oldStdout })
+	return &buf
+}
+
+func TestInPipeline(t *testing.T) {
+	setInPipeline(t, false)
+	if InPipeline() {
+		t.Error("InPipeline() = true without env vars, want false")
+	}
+	t.Setenv("SYSTEM_TEAMFOUNDATIONCOLLECTIONURI", "https://dev.azure.com/dnceng/")
+	if !InPipeline() {
+		t.Error("InPipeline() = false with SYSTEM_TEAMFOUNDATIONCOLLECTIONURI, want true")
+	}
+	setInPipeline(t, true)
+	if !InPipeline() {
+		t.Error("InPipeline() = false with TF_BUILD, want true")
+	}
+}
+
+func TestLogCmds(t *testing.T) {
+	tests := []struct {
+		name       string
+		inPipeline bool
+		want       string
+	}{
+		{"pipeline", true, "##[group]Sync\n##vso[task.setvariable variable=PRNumber]42\n##[endgroup]\n"},
+		{"local", false, "---- Sync\nPRNumber=42\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := setInPipeline(t, tt.inPipeline)
+			LogCmdGroup("Sync")
+			LogCmdSetVariable("PRNumber", "42")
+			LogCmdEndGroup()
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
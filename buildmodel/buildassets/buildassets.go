@@ -10,8 +10,12 @@ package buildassets
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -49,16 +53,51 @@ type BuildAssets struct {
 // on the branch of the Go repo that was built, or returns empty string if no branch needs to be
 // updated.
 func (b BuildAssets) GetDockerRepoTargetBranch() string {
-	if b.Branch == "main" ||
-		strings.HasPrefix(b.Branch, "release-branch.") ||
-		strings.HasPrefix(b.Branch, "dev.boringcrypto") {
+	branch, _ := b.DockerRepoTargetBranchReason()
+	return branch
+}
+
+// DockerRepoTargetBranchReason returns the same branch as GetDockerRepoTargetBranch, along with a
+// description of the mapping rule that selected it. This helps explain the choice when debugging.
+func (b BuildAssets) DockerRepoTargetBranchReason() (branch, reason string) {
+	switch {
+	case b.Branch == "main":
+		return "microsoft/nightly", "builds of main update the nightly branch"
+	case strings.HasPrefix(b.Branch, "release-branch."):
+		return "microsoft/nightly", "builds of release-branch.* update the nightly branch"
+	case strings.HasPrefix(b.Branch, "dev.boringcrypto"):
+		return "microsoft/nightly", "builds of dev.boringcrypto* update the nightly branch"
+	case strings.HasPrefix(b.Branch, "dev/official/"):
+		return b.Branch, "builds of dev/official/* update the branch with the same name"
+	}
+	return "", fmt.Sprintf("branch %q doesn't match any rule", b.Branch)
+}
 
-		return "microsoft/nightly"
+// DockerRepoTargetBranches returns the distinct Go Docker images repo branches that need to be
+// updated for the given assets, in the order they first appear. This lets a caller with multiple
+// build assets files create one update per branch.
+//
+// Returns an error listing every asset that isn't associated with any branch.
+func DockerRepoTargetBranches(assets []*BuildAssets) ([]string, error) {
+	var branches []string
+	var unmapped []string
+	seen := make(map[string]struct{})
+	for _, a := range assets {
+		branch := a.GetDockerRepoTargetBranch()
+		if branch == "" {
+			unmapped = append(unmapped, fmt.Sprintf("%v (branch %q)", a.Version, a.Branch))
+			continue
+		}
+		if _, ok := seen[branch]; ok {
+			continue
+		}
+		seen[branch] = struct{}{}
+		branches = append(branches, branch)
 	}
-	if strings.HasPrefix(b.Branch, "dev/official/") {
-		return b.Branch
+	if len(unmapped) > 0 {
+		return nil, fmt.Errorf("build assets not associated with any Docker image repo branch: %v", strings.Join(unmapped, ", "))
 	}
-	return ""
+	return branches, nil
 }
 
 // GetDockerRepoVersionsKey gets the Docker Versions key that should be updated with new builds
@@ -113,6 +152,118 @@ func (b BuildAssets) GoVersion() *goversion.GoVersion {
 	return goversion.New(v)
 }
 
+// Validate checks that the fields of b are well-formed: each checksum is a 64-character hex SHA256,
+// each URL is an absolute http(s) URL, Version parses as a Microsoft build of Go version, and each
+// non-source arch has an env with a GOOS/GOARCH pair that no other arch claims. This catches a
+// corrupt, truncated, or malformed build asset JSON file before it's used in a release. Returns an
+// error naming each bad field.
+func (b *BuildAssets) Validate() error {
+	var errs []error
+	if err := validateVersion(b.Version); err != nil {
+		errs = append(errs, fmt.Errorf("version: %w", err))
+	}
+	if b.GoSrcURL != "" {
+		if err := validateURL(b.GoSrcURL); err != nil {
+			errs = append(errs, fmt.Errorf("goSrcURL: %w", err))
+		}
+	}
+	if b.GoSrcSHA256 != "" {
+		if err := validateSHA256(b.GoSrcSHA256); err != nil {
+			errs = append(errs, fmt.Errorf("goSrcSHA256: %w", err))
+		}
+	}
+	// platforms maps each GOOS/GOARCH pair to the index of the first arch that claims it.
+	platforms := make(map[string]int, len(b.Arches))
+	for i, a := range b.Arches {
+		if a == nil {
+			errs = append(errs, fmt.Errorf("arches[%v]: null", i))
+			continue
+		}
+		if a.Env != nil {
+			if err := validateArchEnv(a.Env); err != nil {
+				errs = append(errs, fmt.Errorf("arches[%v].env: %w", i, err))
+			} else {
+				platform := a.Env.GOOS + "/" + a.Env.GOARCH
+				if first, ok := platforms[platform]; ok {
+					errs = append(errs, fmt.Errorf("arches[%v].env: %v is already used by arches[%v]", i, platform, first))
+				} else {
+					platforms[platform] = i
+				}
+			}
+		}
+		if err := validateURL(a.URL); err != nil {
+			errs = append(errs, fmt.Errorf("arches[%v].url: %w", i, err))
+		}
+		if err := validateSHA256(a.SHA256); err != nil {
+			errs = append(errs, fmt.Errorf("arches[%v].sha256: %w", i, err))
+		}
+		if a.SHA256ChecksumURL != "" {
+			if err := validateURL(a.SHA256ChecksumURL); err != nil {
+				errs = append(errs, fmt.Errorf("arches[%v].sha256ChecksumUrl: %w", i, err))
+			}
+		}
+		if a.PGPSignatureURL != "" {
+			if err := validateURL(a.PGPSignatureURL); err != nil {
+				errs = append(errs, fmt.Errorf("arches[%v].pgpSignatureUrl: %w", i, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid build assets: %w", err)
+	}
+	return nil
+}
+
+func validateVersion(v string) error {
+	if v == "" {
+		return errors.New("empty")
+	}
+	gv := goversion.New(v)
+	parts := []string{gv.Major, gv.Minor, gv.Patch, gv.Revision}
+	// A build of the main branch uses "main" rather than a numeric version. goversion parses this
+	// as an empty major version with a "main" prerelease.
+	if gv.Major == "" && gv.Prerelease == "main" {
+		parts = parts[1:]
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return fmt.Errorf("%q isn't a valid version", v)
+		}
+	}
+	return nil
+}
+
+func validateArchEnv(env *dockerversions.ArchEnv) error {
+	if env.GOOS == "" || env.GOARCH == "" {
+		return fmt.Errorf("GOOS %q and GOARCH %q must both be set", env.GOOS, env.GOARCH)
+	}
+	if env.GOARM != "" && env.GOARCH != "arm" {
+		return fmt.Errorf("GOARM %q is set, but GOARCH is %q, not \"arm\"", env.GOARM, env.GOARCH)
+	}
+	return nil
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%q isn't an absolute http(s) URL", s)
+	}
+	return nil
+}
+
+func validateSHA256(s string) error {
+	if len(s) != sha256.Size*2 {
+		return fmt.Errorf("%q isn't %v hex characters", s, sha256.Size*2)
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return fmt.Errorf("%q isn't hex: %w", s, err)
+	}
+	return nil
+}
+
 // Basic information about how the build output assets are formatted by Microsoft builds of Go. The
 // archiving infra is stored in each release branch to make it local to the code it operates on and
 // less likely to unintentionally break, so some of that information is duplicated here.
@@ -134,6 +285,12 @@ type BuildResultsDirectoryInfo struct {
 	// DestinationURL is the URL where the assets will be uploaded, if this is an internal build
 	// that will be published somewhere. This lets us include the final URL in the build asset data
 	// so auto-update can pick it up easily.
+	//
+	// DestinationURL may be a template for builds that publish each platform under a different
+	// path. The placeholders "{version}", "{platform}", "{os}", and "{arch}" are replaced with the
+	// build's version (e.g. "1.17.2-1") and the platform of each file (e.g. "linux-amd64", "linux",
+	// and "amd64"). For the source archive, the platform, os, and arch are all "src". The file name
+	// is appended to the result. See ExpandDestinationURL.
 	DestinationURL string
 	// DestinationManifest is the path of a manifest file that lists where each artifact has been
 	// published to. Fails if a file doesn't match up. Causes DestinationURL to be ignored.
@@ -149,6 +306,9 @@ type BuildResultsDirectoryInfo struct {
 
 // CreateSummary scans the paths/info from a BuildResultsDirectoryInfo to summarize the outputs of
 // the build in a BuildAssets struct. The result can be used later to perform an auto-update.
+//
+// The checksum of each archive is read from its ".sha256" file if there is an up to date one, and
+// otherwise computed from the archive. See artifactSHA256.
 func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 	// Look for VERSION files in the submodule and the source repo. Prefer the source repo.
 	goVersion, err := getVersion(filepath.Join(b.SourceDir, "VERSION"), "main")
@@ -182,8 +342,11 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 		return a
 	}
 
+	version := goVersion + "-" + goRevision
+
 	getURL := func(name string) (string, error) {
-		return b.DestinationURL + "/" + name, nil
+		_, platform, _, _ := CutToolsetFileParts(name)
+		return ExpandDestinationURL(b.DestinationURL, version, platform) + "/" + name, nil
 	}
 	// Swap out getURL with a func that gets info from the destination manifest file, if one exists.
 	if b.DestinationManifest != "" {
@@ -206,6 +369,12 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 
 	var goSrcURL string
 	var goSrcSHA256 string
+	var goSrcName string
+
+	// Paths of the archives and checksum files, by archive name. The checksums are determined
+	// once all files are found.
+	archivePaths := make(map[string]string)
+	checksumPaths := make(map[string]string)
 
 	if b.ArtifactsDir != "" {
 		entries, err := os.ReadDir(b.ArtifactsDir)
@@ -227,16 +396,9 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 			// Is it a checksum file?
 			if associatedName, ok := stringutil.CutSuffix(e.Name(), checksumSuffix); ok {
 				a := getOrCreateArch(associatedName)
-
-				// Store the checksum value.
-				checksumLine, err := os.ReadFile(fullPath)
-				if err != nil {
-					return nil, fmt.Errorf("unable to read checksum file %q: %w", fullPath, err)
-				}
-				a.SHA256 = strings.Fields(string(checksumLine))[0]
-
+				checksumPaths[associatedName] = fullPath
 				if platform == "src" {
-					goSrcSHA256 = a.SHA256
+					goSrcName = associatedName
 				}
 
 				a.SHA256ChecksumURL, err = getURL(e.Name())
@@ -265,6 +427,8 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 				}
 				a := getOrCreateArch(e.Name())
 				a.URL = goSrcURL
+				archivePaths[e.Name()] = fullPath
+				goSrcName = e.Name()
 				continue
 			}
 
@@ -293,6 +457,7 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 			}
 
 			a := getOrCreateArch(e.Name())
+			archivePaths[e.Name()] = fullPath
 			a.URL, err = getURL(e.Name())
 			if err != nil {
 				return nil, fmt.Errorf("unable to get URL for binary archive %q: %w", e.Name(), err)
@@ -306,7 +471,14 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 	}
 
 	arches := make([]*dockerversions.Arch, 0, len(archMap))
-	for _, v := range archMap {
+	for name, v := range archMap {
+		v.SHA256, err = artifactSHA256(archivePaths[name], checksumPaths[name])
+		if err != nil {
+			return nil, err
+		}
+		if name == goSrcName {
+			goSrcSHA256 = v.SHA256
+		}
 		arches = append(arches, v)
 	}
 
@@ -318,13 +490,111 @@ func (b BuildResultsDirectoryInfo) CreateSummary() (*BuildAssets, error) {
 	return &BuildAssets{
 		Branch:      b.Branch,
 		BuildID:     b.BuildID,
-		Version:     goVersion + "-" + goRevision,
+		Version:     version,
 		Arches:      arches,
 		GoSrcURL:    goSrcURL,
 		GoSrcSHA256: goSrcSHA256,
 	}, nil
 }
 
+// ExpandDestinationURL replaces the placeholders in a DestinationURL template with the given
+// version and the platform of a file, such as "linux-amd64" or "src". A URL without placeholders is
+// returned as is.
+func ExpandDestinationURL(template, version, platform string) string {
+	goOS, goArch, found := strings.Cut(platform, "-")
+	if !found {
+		// The source archive platform, "src", is used for all the placeholders.
+		goArch = goOS
+	}
+	return strings.NewReplacer(
+		"{version}", version,
+		"{platform}", platform,
+		"{os}", goOS,
+		"{arch}", goArch,
+	).Replace(template)
+}
+
+// artifactSHA256 returns the SHA256 checksum of the archive at archivePath. If checksumPath is a
+// checksum file that is at least as new as the archive, reads the checksum from it rather than
+// hashing the archive, which may be large. Otherwise, hashes the archive. Either path may be empty
+// if the file doesn't exist. If both are empty, returns "".
+//
+// Returns an error if the checksum file is malformed, or if it's older than the archive and
+// doesn't match it: it would be published alongside the archive with the wrong checksum.
+func artifactSHA256(archivePath, checksumPath string) (string, error) {
+	if checksumPath == "" {
+		if archivePath == "" {
+			return "", nil
+		}
+		return fileSHA256(archivePath)
+	}
+
+	content, err := os.ReadFile(checksumPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read checksum file %q: %w", checksumPath, err)
+	}
+	archiveName, _ := stringutil.CutSuffix(filepath.Base(checksumPath), checksumSuffix)
+	sum, err := parseChecksumFile(string(content), archiveName)
+	if err != nil {
+		return "", fmt.Errorf("malformed checksum file %q: %w", checksumPath, err)
+	}
+	if archivePath == "" {
+		return sum, nil
+	}
+
+	archiveInfo, err := os.Stat(archivePath)
+	if err != nil {
+		return "", err
+	}
+	checksumInfo, err := os.Stat(checksumPath)
+	if err != nil {
+		return "", err
+	}
+	if !checksumInfo.ModTime().Before(archiveInfo.ModTime()) {
+		return sum, nil
+	}
+
+	actual, err := fileSHA256(archivePath)
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(actual, sum) {
+		return "", fmt.Errorf("checksum file %q is older than %q and contains %v, but the archive's checksum is %v", checksumPath, archivePath, sum, actual)
+	}
+	return actual, nil
+}
+
+// parseChecksumFile parses the content of a checksum file in the format written by sha256sum: a
+// hex SHA256 checksum, optionally followed by whitespace and the name of the file it's for.
+func parseChecksumFile(content, archiveName string) (string, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", fmt.Errorf("expected a checksum and optional file name, found %v fields", len(fields))
+	}
+	if err := validateSHA256(fields[0]); err != nil {
+		return "", err
+	}
+	// sha256sum marks a file that was read in binary mode with "*".
+	if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") != archiveName {
+		return "", fmt.Errorf("checksum is for %q, not %q", fields[1], archiveName)
+	}
+	return fields[0], nil
+}
+
+// fileSHA256 returns the hex SHA256 checksum of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("unable to hash %q: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // CutToolsetFileParts cuts the given filename into a prefix containing the version information,
 // platform (treating "src" as a platform), and extension. The extension always begins with ".". If
 // the filename doesn't match the expected format, returns ok = false.
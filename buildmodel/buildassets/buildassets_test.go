@@ -9,8 +9,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/microsoft/go-infra/buildmodel/dockerversions"
 	"github.com/microsoft/go-infra/goldentest"
 	"golang.org/x/tools/txtar"
 )
@@ -20,6 +24,7 @@ func TestBuildResultsDirectoryInfo_GoldenCreateSummary(t *testing.T) {
 		"1.17-build",
 		"1.23dev-publish",
 		"1.23dev-missing-publish",
+		"1.17-build-url-template",
 	}
 	for _, tt := range tests {
 		t.Run(tt, func(t *testing.T) {
@@ -43,6 +48,11 @@ func TestBuildResultsDirectoryInfo_GoldenCreateSummary(t *testing.T) {
 			if _, err := os.Stat(destManifestPath); err != nil {
 				if errors.Is(err, os.ErrNotExist) {
 					b.DestinationURL = "https://example.org"
+					if template, err := os.ReadFile(filepath.Join(dir, "destination-url.txt")); err == nil {
+						b.DestinationURL = strings.TrimSpace(string(template))
+					} else if !errors.Is(err, os.ErrNotExist) {
+						t.Fatal(err)
+					}
 				} else {
 					t.Fatal(err)
 				}
@@ -66,6 +76,24 @@ func TestBuildResultsDirectoryInfo_GoldenCreateSummary(t *testing.T) {
 	}
 }
 
+func TestExpandDestinationURL(t *testing.T) {
+	tests := []struct {
+		template, platform, want string
+	}{
+		{"https://example.org", "linux-amd64", "https://example.org"},
+		{"https://example.org/{version}/{os}/{arch}", "linux-amd64", "https://example.org/1.17.2-1/linux/amd64"},
+		{"https://example.org/{platform}", "windows-arm64", "https://example.org/windows-arm64"},
+		{"https://example.org/{os}/{arch}", "src", "https://example.org/src/src"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template+"_"+tt.platform, func(t *testing.T) {
+			if got := ExpandDestinationURL(tt.template, "1.17.2-1", tt.platform); got != tt.want {
+				t.Errorf("ExpandDestinationURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_getVersion(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -115,3 +143,234 @@ func tempExtractTxtar(t *testing.T, path string) (outDir string, ok bool) {
 	}
 	return td, true
 }
+
+func TestDockerRepoTargetBranches(t *testing.T) {
+	tests := []struct {
+		name    string
+		assets  []*BuildAssets
+		want    []string
+		wantErr bool
+	}{
+		{
+			"deduplicate",
+			[]*BuildAssets{
+				{Branch: "release-branch.go1.22", Version: "1.22.1-1"},
+				{Branch: "dev/official/go1.23", Version: "1.23.1-1"},
+				{Branch: "main", Version: "1.24.0-1"},
+			},
+			[]string{"microsoft/nightly", "dev/official/go1.23"},
+			false,
+		},
+		{
+			"unmapped",
+			[]*BuildAssets{
+				{Branch: "main", Version: "1.24.0-1"},
+				{Branch: "dev/someone/feature", Version: "1.24.0-1"},
+			},
+			nil,
+			true,
+		},
+		{
+			"empty",
+			nil,
+			nil,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DockerRepoTargetBranches(tt.assets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DockerRepoTargetBranches() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("DockerRepoTargetBranches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildAssets_DockerRepoTargetBranchReason(t *testing.T) {
+	tests := []struct {
+		branch     string
+		want       string
+		wantReason string
+	}{
+		{"main", "microsoft/nightly", "main"},
+		{"release-branch.go1.22", "microsoft/nightly", "release-branch.*"},
+		{"dev.boringcrypto.go1.18", "microsoft/nightly", "dev.boringcrypto*"},
+		{"dev/official/go1.23", "dev/official/go1.23", "dev/official/*"},
+		{"dev/someone/feature", "", "doesn't match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			b := BuildAssets{Branch: tt.branch}
+			got, reason := b.DockerRepoTargetBranchReason()
+			if got != tt.want || !strings.Contains(reason, tt.wantReason) {
+				t.Errorf("DockerRepoTargetBranchReason() = %q, %q; want %q and a reason containing %q", got, reason, tt.want, tt.wantReason)
+			}
+			if got != b.GetDockerRepoTargetBranch() {
+				t.Errorf("GetDockerRepoTargetBranch() = %q, want %q", b.GetDockerRepoTargetBranch(), got)
+			}
+		})
+	}
+}
+
+func TestBuildAssets_Validate(t *testing.T) {
+	const validSHA256 = "0ecd8a6b43ae3e993eedddde6141a7785fc65d1cc8a322c6e67fa02420a883fd"
+	valid := func() *BuildAssets {
+		return &BuildAssets{
+			Version:     "1.22.1-1",
+			GoSrcURL:    "https://example.org/go.src.tar.gz",
+			GoSrcSHA256: validSHA256,
+			Arches: []*dockerversions.Arch{
+				{
+					URL:               "https://example.org/go.linux-amd64.tar.gz",
+					SHA256:            validSHA256,
+					SHA256ChecksumURL: "https://example.org/go.linux-amd64.tar.gz.sha256",
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name       string
+		modify     func(b *BuildAssets)
+		wantFields []string
+	}{
+		{"valid", func(b *BuildAssets) {}, nil},
+		{"main version", func(b *BuildAssets) { b.Version = "main-1" }, nil},
+		{"prerelease version", func(b *BuildAssets) { b.Version = "1.23rc1-1" }, nil},
+		{"bad version", func(b *BuildAssets) { b.Version = "latest" }, []string{"version"}},
+		{"truncated hash", func(b *BuildAssets) { b.Arches[0].SHA256 = validSHA256[:60] }, []string{"arches[0].sha256"}},
+		{
+			"relative URLs",
+			func(b *BuildAssets) {
+				b.GoSrcURL = "go.src.tar.gz"
+				b.Arches[0].SHA256ChecksumURL = "ftp://example.org/go.sha256"
+			},
+			[]string{"goSrcURL", "arches[0].sha256ChecksumUrl"},
+		},
+		{
+			"platforms",
+			func(b *BuildAssets) {
+				b.Arches[0].Env = &dockerversions.ArchEnv{GOOS: "linux", GOARCH: "amd64"}
+				for _, env := range []*dockerversions.ArchEnv{
+					{GOOS: "windows", GOARCH: "amd64"},
+					{GOOS: "linux", GOARCH: "arm", GOARM: "6"},
+					nil,
+				} {
+					b.Arches = append(b.Arches, &dockerversions.Arch{
+						Env:    env,
+						URL:    "https://example.org/go.tar.gz",
+						SHA256: validSHA256,
+					})
+				}
+			},
+			nil,
+		},
+		{
+			"duplicate platform",
+			func(b *BuildAssets) {
+				b.Arches[0].Env = &dockerversions.ArchEnv{GOOS: "linux", GOARCH: "amd64"}
+				b.Arches = append(b.Arches, &dockerversions.Arch{
+					Env:    &dockerversions.ArchEnv{GOOS: "linux", GOARCH: "amd64"},
+					URL:    "https://example.org/go.linux-amd64-2.tar.gz",
+					SHA256: validSHA256,
+				})
+			},
+			[]string{"arches[1].env"},
+		},
+		{"missing GOARCH", func(b *BuildAssets) { b.Arches[0].Env = &dockerversions.ArchEnv{GOOS: "linux"} }, []string{"arches[0].env"}},
+		{
+			"GOARM without arm",
+			func(b *BuildAssets) {
+				b.Arches[0].Env = &dockerversions.ArchEnv{GOOS: "linux", GOARCH: "arm64", GOARM: "7"}
+			},
+			[]string{"arches[0].env"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := valid()
+			tt.modify(b)
+			err := b.Validate()
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() expected error")
+			}
+			for _, f := range tt.wantFields {
+				if !strings.Contains(err.Error(), f+":") {
+					t.Errorf("Validate() error doesn't name %v: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func Test_artifactSHA256(t *testing.T) {
+	const (
+		name = "go.linux-amd64.tar.gz"
+		// The SHA256 of "archive content".
+		actualSHA256 = "fa868b2818c90263b5c2c8e056180232a6f3c34547ca49b7f3ca10599a52db3d"
+		otherSHA256  = "0ecd8a6b43ae3e993eedddde6141a7785fc65d1cc8a322c6e67fa02420a883fd"
+	)
+	archiveTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		checksum string // Content of the checksum file, or empty if there is none.
+		stale    bool   // If true, the checksum file is older than the archive.
+		want     string
+		wantErr  bool
+	}{
+		{"no checksum file", "", false, actualSHA256, false},
+		// An up to date checksum file is trusted without hashing the archive.
+		{"fresh", otherSHA256 + "  " + name + "\n", false, otherSHA256, false},
+		{"fresh without name", otherSHA256, false, otherSHA256, false},
+		{"fresh binary mode", otherSHA256 + " *" + name + "\n", false, otherSHA256, false},
+		{"stale matching", actualSHA256 + "  " + name + "\n", true, actualSHA256, false},
+		{"stale mismatch", otherSHA256 + "  " + name + "\n", true, "", true},
+		{"empty", "\n", false, "", true},
+		{"truncated", otherSHA256[:60] + "  " + name + "\n", false, "", true},
+		{"wrong name", otherSHA256 + "  go.linux-arm64.tar.gz\n", false, "", true},
+		{"extra fields", otherSHA256 + "  " + name + " extra\n", false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, name)
+			if err := os.WriteFile(archivePath, []byte("archive content"), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(archivePath, archiveTime, archiveTime); err != nil {
+				t.Fatal(err)
+			}
+			var checksumPath string
+			if tt.checksum != "" {
+				checksumPath = archivePath + ".sha256"
+				if err := os.WriteFile(checksumPath, []byte(tt.checksum), 0o666); err != nil {
+					t.Fatal(err)
+				}
+				checksumTime := archiveTime
+				if tt.stale {
+					checksumTime = archiveTime.Add(-time.Hour)
+				}
+				if err := os.Chtimes(checksumPath, checksumTime, checksumTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := artifactSHA256(archivePath, checksumPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("artifactSHA256() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("artifactSHA256() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,10 +6,16 @@
 package buildmodel
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/microsoft/go-infra/buildmodel/buildassets"
@@ -323,6 +329,469 @@ func UpdateVersions(assets *buildassets.BuildAssets, versions dockerversions.Ver
 	return nil
 }
 
+// ErrConflictingAssets indicates that two build assets passed to UpdateVersionsMulti target the
+// same versions.json channel with different data.
+var ErrConflictingAssets = errors.New("conflicting build assets")
+
+// UpdateVersionsMulti applies several build asset files to a versions.json model in one pass, so a
+// single update can cover every version in a multi-version release. The assets are applied in
+// ascending version order, so a new major.minor channel is based on the already-updated previous
+// channel. Identical duplicate assets are applied once.
+//
+// Returns an error wrapping ErrConflictingAssets without modifying versions if two assets target
+// the same channel with different data.
+func UpdateVersionsMulti(assets []*buildassets.BuildAssets, versions dockerversions.Versions) error {
+	byKey := make(map[string]*buildassets.BuildAssets, len(assets))
+	unique := make([]*buildassets.BuildAssets, 0, len(assets))
+	var conflicts []string
+	for _, a := range assets {
+		key := a.GetDockerRepoVersionsKey()
+		prev, ok := byKey[key]
+		if !ok {
+			byKey[key] = a
+			unique = append(unique, a)
+			continue
+		}
+		if !reflect.DeepEqual(prev, a) {
+			conflicts = append(conflicts, fmt.Sprintf("%v (%v and %v)", key, prev.Version, a.Version))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: multiple assets for versions.json keys: %v", ErrConflictingAssets, strings.Join(conflicts, ", "))
+	}
+
+	slices.SortStableFunc(unique, func(a, b *buildassets.BuildAssets) int {
+		return compareVersions(a.GoVersion(), b.GoVersion())
+	})
+	for _, a := range unique {
+		if err := UpdateVersions(a, versions); err != nil {
+			return fmt.Errorf("failed to apply build assets for %v: %w", a.Version, err)
+		}
+	}
+	return nil
+}
+
+// ErrUnknownChannel indicates that a versions.json key that was expected to exist doesn't.
+var ErrUnknownChannel = errors.New("unknown versions.json channel")
+
+// BumpRevision sets the Microsoft revision of the given versions.json channel (key), keeping its
+// upstream version. If revision is empty, increments the current revision. This is useful for a
+// respin. It doesn't change the artifact URLs or checksums.
+func BumpRevision(versions dockerversions.Versions, channel, revision string) error {
+	v, ok := versions[channel]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownChannel, channel)
+	}
+	if revision == "" {
+		current, err := strconv.Atoi(v.Revision)
+		if err != nil {
+			return fmt.Errorf("unable to increment revision %q of %q: %w", v.Revision, channel, err)
+		}
+		revision = strconv.Itoa(current + 1)
+	} else if n, err := strconv.Atoi(revision); err != nil || n < 1 {
+		return fmt.Errorf("revision %q is not a positive integer", revision)
+	}
+	v.Revision = revision
+	return nil
+}
+
+// SharedTagRules configures how UpdateSharedTags picks the versions that receive shared tags that
+// float between versions: the major-only alias (like "1") and the versionless "latest" tags.
+type SharedTagRules struct {
+	// IncludePrerelease allows a beta or rc version to receive shared tags. By default, only
+	// stable releases are considered.
+	IncludePrerelease bool
+	// ExcludeKeys lists versions.json keys that never receive shared tags.
+	ExcludeKeys []string
+}
+
+// UpdateSharedTags recomputes PreferredMajor and PreferredMinor for every entry in versions so that
+// the shared tags generated by UpdateManifest point at the highest applicable version. Within each
+// major version, the highest version is the preferred minor. The highest version overall is the
+// preferred major. Entries with a TagPrefix or BranchSuffix have their own tag namespace, so they
+// are only compared against other entries with the same affixes. Keys that aren't a numeric
+// major.minor version, such as "main", never receive shared tags.
+func UpdateSharedTags(versions dockerversions.Versions, rules SharedTagRules) {
+	type candidate struct {
+		v      *dockerversions.MajorMinorVersion
+		parsed *goversion.GoVersion
+	}
+	// Best candidate per tag namespace, and per major version within each namespace.
+	bestMajor := make(map[string]candidate)
+	bestMinor := make(map[string]candidate)
+
+	// Iterate in a stable order so ties are broken consistently.
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := versions[key]
+		v.PreferredMajor = false
+		v.PreferredMinor = false
+
+		if slices.Contains(rules.ExcludeKeys, key) {
+			continue
+		}
+		parsed := goversion.New(v.Version)
+		if !isNumericVersion(parsed) {
+			continue
+		}
+		if parsed.Prerelease != "" && !rules.IncludePrerelease {
+			continue
+		}
+		if v.Revision != "" {
+			parsed.Revision = v.Revision
+		}
+
+		c := candidate{v, parsed}
+		namespace := v.TagPrefix + "|" + v.BranchSuffix
+		if best, ok := bestMajor[namespace]; !ok || compareVersions(parsed, best.parsed) > 0 {
+			bestMajor[namespace] = c
+		}
+		majorNamespace := namespace + "|" + parsed.Major
+		if best, ok := bestMinor[majorNamespace]; !ok || compareVersions(parsed, best.parsed) > 0 {
+			bestMinor[majorNamespace] = c
+		}
+	}
+
+	for _, c := range bestMajor {
+		c.v.PreferredMajor = true
+	}
+	for _, c := range bestMinor {
+		c.v.PreferredMinor = true
+	}
+}
+
+// isNumericVersion returns true if the major, minor, and patch parts of v are all integers.
+func isNumericVersion(v *goversion.GoVersion) bool {
+	for _, part := range []string{v.Major, v.Minor, v.Patch} {
+		if _, err := strconv.Atoi(part); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersions returns -1, 0, or +1 depending on whether a is less than, equal to, or greater
+// than b. Both versions must be numeric, see isNumericVersion. A stable release is greater than
+// any prerelease of the same major.minor.patch version.
+func compareVersions(a, b *goversion.GoVersion) int {
+	for _, parts := range [][2]string{
+		{a.Major, b.Major},
+		{a.Minor, b.Minor},
+		{a.Patch, b.Patch},
+	} {
+		if c := compareInts(parts[0], parts[1]); c != 0 {
+			return c
+		}
+	}
+	if a.Prerelease != b.Prerelease {
+		switch {
+		case a.Prerelease == "":
+			return 1
+		case b.Prerelease == "":
+			return -1
+		}
+		return strings.Compare(a.Prerelease, b.Prerelease)
+	}
+	return compareInts(a.Revision, b.Revision)
+}
+
+// compareInts compares two integer strings. Strings that can't be parsed are treated as 0.
+func compareInts(a, b string) int {
+	ai, _ := strconv.Atoi(a)
+	bi, _ := strconv.Atoi(b)
+	return cmp.Compare(ai, bi)
+}
+
+// ErrNotAdditive indicates that an update would have removed a version channel or image tag while
+// additive-only mode was enabled.
+var ErrNotAdditive = errors.New("update is not additive")
+
+// CheckAdditive compares the state of a versions.json and manifest.json model before and after an
+// update. Returns an error wrapping ErrNotAdditive if the update removed a version channel (a
+// versions.json key), a variant of a channel, or an image tag. Updating a channel to a new version
+// replaces its version-specific tags, so removing a tag that starts with the old version number of
+// an updated channel is allowed.
+func CheckAdditive(
+	oldVersions, newVersions dockerversions.Versions,
+	oldManifest, newManifest *dockermanifest.Manifest,
+) error {
+	var problems []string
+	// Old version numbers that are no longer in use after the update, with the channel's tag prefix.
+	var replacedVersions []string
+	for key, oldV := range oldVersions {
+		newV, ok := newVersions[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("removed version %q", key))
+			continue
+		}
+		for _, variant := range oldV.Variants {
+			if !slices.Contains(newV.Variants, variant) {
+				problems = append(problems, fmt.Sprintf("removed variant %q of version %q", variant, key))
+			}
+		}
+		if oldV.Version != newV.Version {
+			replacedVersions = append(replacedVersions, oldV.TagPrefix+oldV.Version)
+		}
+		if oldFull := joinTag(oldV.Version, oldV.Revision); oldFull != joinTag(newV.Version, newV.Revision) {
+			replacedVersions = append(replacedVersions, oldV.TagPrefix+oldFull)
+		}
+	}
+
+	newTags := manifestTags(newManifest)
+	for tag := range manifestTags(oldManifest) {
+		if _, ok := newTags[tag]; ok {
+			continue
+		}
+		replaced := false
+		for _, v := range replacedVersions {
+			// Match whole components: 1.22.1-1 must not match 1.22.1-10-bookworm.
+			if tag == v || strings.HasPrefix(tag, v+"-") {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			problems = append(problems, fmt.Sprintf("removed tag %q", tag))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %v", ErrNotAdditive, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// manifestTags returns the set of every tag in the manifest, shared or platform-specific.
+func manifestTags(m *dockermanifest.Manifest) map[string]struct{} {
+	tags := make(map[string]struct{})
+	for _, r := range m.Repos {
+		for _, image := range r.Images {
+			for tag := range image.SharedTags {
+				tags[tag] = struct{}{}
+			}
+			for _, p := range image.Platforms {
+				for tag := range p.Tags {
+					tags[tag] = struct{}{}
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// UpdateChanges summarizes the versions.json channels and manifest.json tags that an update adds,
+// removes, or modifies. Each list is sorted.
+type UpdateChanges struct {
+	AddedVersions   []string
+	RemovedVersions []string
+	// ChangedVersions lists versions.json keys that exist before and after the update but whose
+	// content differs, for example because of a patch version update.
+	ChangedVersions []string
+
+	AddedTags   []string
+	RemovedTags []string
+}
+
+// Empty returns true if the update doesn't change any version channel or tag.
+func (c *UpdateChanges) Empty() bool {
+	return len(c.AddedVersions) == 0 &&
+		len(c.RemovedVersions) == 0 &&
+		len(c.ChangedVersions) == 0 &&
+		len(c.AddedTags) == 0 &&
+		len(c.RemovedTags) == 0
+}
+
+// String formats the changes as a human-readable list, one change per line.
+func (c *UpdateChanges) String() string {
+	if c.Empty() {
+		return "No changes.\n"
+	}
+	var b strings.Builder
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%v:\n", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "  %v\n", item)
+		}
+	}
+	section("Added versions", c.AddedVersions)
+	section("Removed versions", c.RemovedVersions)
+	section("Changed versions", c.ChangedVersions)
+	section("Added tags", c.AddedTags)
+	section("Removed tags", c.RemovedTags)
+	return b.String()
+}
+
+// DiffUpdate compares the state of a versions.json and manifest.json model before and after an
+// update and returns the channels and tags that changed. Unlike CheckAdditive, this doesn't judge
+// whether the changes are allowed.
+func DiffUpdate(
+	oldVersions, newVersions dockerversions.Versions,
+	oldManifest, newManifest *dockermanifest.Manifest,
+) *UpdateChanges {
+	var c UpdateChanges
+	for key, oldV := range oldVersions {
+		newV, ok := newVersions[key]
+		if !ok {
+			c.RemovedVersions = append(c.RemovedVersions, key)
+		} else if !reflect.DeepEqual(oldV, newV) {
+			c.ChangedVersions = append(c.ChangedVersions, key)
+		}
+	}
+	for key := range newVersions {
+		if _, ok := oldVersions[key]; !ok {
+			c.AddedVersions = append(c.AddedVersions, key)
+		}
+	}
+
+	oldTags := manifestTags(oldManifest)
+	newTags := manifestTags(newManifest)
+	for tag := range oldTags {
+		if _, ok := newTags[tag]; !ok {
+			c.RemovedTags = append(c.RemovedTags, tag)
+		}
+	}
+	for tag := range newTags {
+		if _, ok := oldTags[tag]; !ok {
+			c.AddedTags = append(c.AddedTags, tag)
+		}
+	}
+
+	sort.Strings(c.AddedVersions)
+	sort.Strings(c.RemovedVersions)
+	sort.Strings(c.ChangedVersions)
+	sort.Strings(c.AddedTags)
+	sort.Strings(c.RemovedTags)
+	return &c
+}
+
+// ErrInvalidManifest indicates that a manifest.json file doesn't match the model or would fail to
+// build the images.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// ValidateManifestFile reads the manifest.json file in the given Go Docker images repo and checks
+// it using ValidateManifest, including checking the Dockerfiles on disk. The file must not contain
+// any fields that aren't in the dockermanifest model. Returns an error wrapping ErrInvalidManifest
+// if the file is invalid.
+func ValidateManifestFile(repoRoot string) error {
+	manifestJSONPath := filepath.Join(repoRoot, "manifest.json")
+	f, err := os.Open(manifestJSONPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	var manifest dockermanifest.Manifest
+	if err := d.Decode(&manifest); err != nil {
+		return fmt.Errorf("%w: %v doesn't match the model: %v", ErrInvalidManifest, manifestJSONPath, err)
+	}
+	return ValidateManifest(&manifest, repoRoot)
+}
+
+// ValidateManifest checks that the manifest has the fields the .NET Docker tooling needs to build
+// each image, and that no tag is used more than once in the same repo. If repoRoot isn't empty,
+// also checks that each Dockerfile the manifest refers to exists in repoRoot. Returns an error
+// wrapping ErrInvalidManifest that names each problem.
+func ValidateManifest(m *dockermanifest.Manifest, repoRoot string) error {
+	var errs []error
+	if len(m.Repos) == 0 {
+		errs = append(errs, errors.New("repos: empty"))
+	}
+	for i, r := range m.Repos {
+		if r == nil {
+			errs = append(errs, fmt.Errorf("repos[%v]: null", i))
+			continue
+		}
+		if r.Name == "" {
+			errs = append(errs, fmt.Errorf("repos[%v].name: empty", i))
+		}
+		// Where each tag is first used in the repo, to report duplicates.
+		tagLocations := make(map[string]string)
+		checkTag := func(tag, location string) {
+			if first, ok := tagLocations[tag]; ok {
+				errs = append(errs, fmt.Errorf("%v: duplicate tag %q, first used in %v", location, tag, first))
+				return
+			}
+			tagLocations[tag] = location
+		}
+		for j, image := range r.Images {
+			imageLocation := fmt.Sprintf("repos[%v].images[%v]", i, j)
+			if image == nil {
+				errs = append(errs, fmt.Errorf("%v: null", imageLocation))
+				continue
+			}
+			if len(image.Platforms) == 0 {
+				errs = append(errs, fmt.Errorf("%v.platforms: empty", imageLocation))
+			}
+			for _, tag := range sortedTags(image.SharedTags) {
+				checkTag(tag, imageLocation+".sharedTags")
+			}
+			for k, p := range image.Platforms {
+				platformLocation := fmt.Sprintf("%v.platforms[%v]", imageLocation, k)
+				if p == nil {
+					errs = append(errs, fmt.Errorf("%v: null", platformLocation))
+					continue
+				}
+				if p.OS == "" {
+					errs = append(errs, fmt.Errorf("%v.os: empty", platformLocation))
+				}
+				if len(p.Tags) == 0 {
+					errs = append(errs, fmt.Errorf("%v.tags: empty", platformLocation))
+				}
+				for _, tag := range sortedTags(p.Tags) {
+					checkTag(tag, platformLocation+".tags")
+				}
+				if p.Dockerfile == "" {
+					errs = append(errs, fmt.Errorf("%v.dockerfile: empty", platformLocation))
+				} else if repoRoot != "" {
+					if err := checkDockerfileExists(repoRoot, p.Dockerfile); err != nil {
+						errs = append(errs, fmt.Errorf("%v.dockerfile: %w", platformLocation, err))
+					}
+				}
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+	return nil
+}
+
+// sortedTags returns the tag names in tags, sorted, so problems are reported in a stable order.
+func sortedTags(tags map[string]dockermanifest.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkDockerfileExists checks that the manifest Dockerfile path, relative to repoRoot, exists. Like
+// the .NET Docker tooling, the path may be a Dockerfile or a directory containing a "Dockerfile".
+func checkDockerfileExists(repoRoot, path string) error {
+	fullPath := filepath.Join(repoRoot, filepath.FromSlash(path))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return fmt.Errorf("%q doesn't exist", path)
+	}
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(fullPath, "Dockerfile")); err != nil {
+			return fmt.Errorf("directory %q doesn't contain a Dockerfile", path)
+		}
+	}
+	return nil
+}
+
 // makeOSArchPlatform creates a Docker manifest platform based on the given OS, OS version, and
 // architecture information. This func processes the info to present it in the way .NET Docker's
 // build infrastructure expects.
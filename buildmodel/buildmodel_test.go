@@ -4,15 +4,23 @@
 package buildmodel
 
 import (
+	"encoding/base64"
 	"errors"
 	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/go-test/deep"
 	"github.com/microsoft/go-infra/buildmodel/buildassets"
 	"github.com/microsoft/go-infra/buildmodel/dockermanifest"
 	"github.com/microsoft/go-infra/buildmodel/dockerversions"
+	"github.com/microsoft/go-infra/executil"
+	"github.com/microsoft/go-infra/gitpr"
 	"github.com/microsoft/go-infra/stringutil"
 )
 
@@ -200,6 +208,341 @@ func TestUpdateVersions(t *testing.T) {
 	checkGoldenJSON(t, filepath.Join(assetDir, "updatedVersions.golden.json"), versions)
 }
 
+func TestUpdateVersionsMulti(t *testing.T) {
+	newAssets := func(version, sha string) *buildassets.BuildAssets {
+		return &buildassets.BuildAssets{
+			Version: version,
+			Arches: []*dockerversions.Arch{{
+				Env:    &dockerversions.ArchEnv{GOARCH: "amd64", GOOS: "linux"},
+				SHA256: sha,
+				URL:    "example.org/" + version,
+			}},
+		}
+	}
+	newVersions := func() dockerversions.Versions {
+		return dockerversions.Versions{
+			"1.41": {
+				Version:          "1.41.1",
+				Revision:         "1",
+				PreferredVariant: "bookworm",
+				Variants:         []string{"bookworm"},
+			},
+		}
+	}
+
+	t.Run("Apply all", func(t *testing.T) {
+		v := newVersions()
+		// 1.42 is listed first, but it's a new channel based on 1.41, so 1.41 must be updated
+		// first to make sure the new channel is based on the updated data.
+		err := UpdateVersionsMulti([]*buildassets.BuildAssets{
+			newAssets("1.42.0-1", "new-sha"),
+			newAssets("1.41.2-1", "updated-sha"),
+			newAssets("1.41.2-1", "updated-sha"),
+		}, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, want := range map[string]string{"1.41": "1.41.2", "1.42": "1.42.0"} {
+			if got := v[key].Version; got != want {
+				t.Errorf("%v: Version = %v, want %v", key, got, want)
+			}
+		}
+		if got := v["1.42"].Arches["amd64"].SHA256; got != "new-sha" {
+			t.Errorf("1.42 SHA256 = %v, want new-sha", got)
+		}
+	})
+
+	t.Run("Reject conflict", func(t *testing.T) {
+		v := newVersions()
+		err := UpdateVersionsMulti([]*buildassets.BuildAssets{
+			newAssets("1.41.2-1", "a"),
+			newAssets("1.41.3-1", "b"),
+		}, v)
+		if !errors.Is(err, ErrConflictingAssets) {
+			t.Fatalf("UpdateVersionsMulti() error = %v, want %v", err, ErrConflictingAssets)
+		}
+		if diff := deep.Equal(v, newVersions()); diff != nil {
+			t.Errorf("versions modified despite conflict: %v", diff)
+		}
+	})
+}
+
+func Test_upToDateCommit(t *testing.T) {
+	assetDir := filepath.Join("testdata", "UpdateVersions")
+	var assets buildassets.BuildAssets
+	if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "assets.json"), &assets); err != nil {
+		t.Fatal(err)
+	}
+	// manifestFor returns the manifest.json content generated from the given versions.json file.
+	manifestFor := func(versionsFile string) []byte {
+		var versions dockerversions.Versions
+		if err := stringutil.ReadJSONFile(filepath.Join(assetDir, versionsFile), &versions); err != nil {
+			t.Fatal(err)
+		}
+		var manifest dockermanifest.Manifest
+		UpdateManifest(&manifest, versions)
+		content, err := stringutil.MarshalJSONFile(&manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+	upToDateManifest := manifestFor("updatedVersions.golden.json")
+
+	tests := []struct {
+		name         string
+		versionsFile string
+		manifest     []byte
+		want         string
+		wantErr      bool
+	}{
+		{"up to date", "updatedVersions.golden.json", upToDateManifest, "abc123", false},
+		{"needs update", "versions.json", manifestFor("versions.json"), "", false},
+		{"stale manifest", "updatedVersions.golden.json", manifestFor("versions.json"), "", false},
+		{"missing file", "", upToDateManifest, "", true},
+		{"missing manifest", "updatedVersions.golden.json", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/repos/microsoft/go-images/commits/microsoft/main":
+					w.Write([]byte(`{"sha": "abc123"}`))
+				case r.URL.Path == "/repos/microsoft/go-images/contents/src/microsoft/versions.json" &&
+					r.URL.Query().Get("ref") == "abc123" && tt.versionsFile != "":
+					content, err := os.ReadFile(filepath.Join(assetDir, tt.versionsFile))
+					if err != nil {
+						t.Error(err)
+					}
+					w.Write([]byte(`{"encoding": "base64", "content": "` + base64.StdEncoding.EncodeToString(content) + `"}`))
+				case r.URL.Path == "/repos/microsoft/go-images/contents/manifest.json" &&
+					r.URL.Query().Get("ref") == "abc123" && tt.manifest != nil:
+					w.Write([]byte(`{"encoding": "base64", "content": "` + base64.StdEncoding.EncodeToString(tt.manifest) + `"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"message": "Not Found"}`))
+				}
+			}))
+			defer server.Close()
+
+			got, err := upToDateCommit(gitpr.NewClient(server.URL), "microsoft/go-images", "microsoft/main", []*buildassets.BuildAssets{&assets}, nil, "pat")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("upToDateCommit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("upToDateCommit() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSharedTags(t *testing.T) {
+	newVersions := func() dockerversions.Versions {
+		newVersion := func(version string, preferred bool) *dockerversions.MajorMinorVersion {
+			return &dockerversions.MajorMinorVersion{
+				Arches: map[string]*dockerversions.Arch{
+					"amd64": {
+						Env:       &dockerversions.ArchEnv{GOARCH: "amd64", GOOS: "linux"},
+						Supported: true,
+					},
+				},
+				Variants:         []string{"bookworm"},
+				Version:          version,
+				Revision:         "1",
+				PreferredMajor:   preferred,
+				PreferredMinor:   preferred,
+				PreferredVariant: "bookworm",
+			}
+		}
+		return dockerversions.Versions{
+			"1.21": newVersion("1.21.9", true),
+			"1.22": newVersion("1.22rc2", false),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(versions dockerversions.Versions)
+		rules  SharedTagRules
+		// wantTags maps a shared tag to the versions.json key whose image should carry it.
+		wantTags map[string]string
+	}{
+		{
+			"no change",
+			func(versions dockerversions.Versions) {},
+			SharedTagRules{},
+			map[string]string{"1": "1.21", "latest": "1.21"},
+		},
+		{
+			"patch of older minor",
+			func(versions dockerversions.Versions) { versions["1.21"].Version = "1.21.10" },
+			SharedTagRules{},
+			map[string]string{"1": "1.21", "latest": "1.21"},
+		},
+		{
+			"release of newer minor moves alias",
+			func(versions dockerversions.Versions) { versions["1.22"].Version = "1.22.0" },
+			SharedTagRules{},
+			map[string]string{"1": "1.22", "1-bookworm": "1.22", "latest": "1.22"},
+		},
+		{
+			"include prerelease",
+			func(versions dockerversions.Versions) {},
+			SharedTagRules{IncludePrerelease: true},
+			map[string]string{"1": "1.22", "latest": "1.22"},
+		},
+		{
+			"excluded key",
+			func(versions dockerversions.Versions) { versions["1.22"].Version = "1.22.0" },
+			SharedTagRules{ExcludeKeys: []string{"1.22"}},
+			map[string]string{"1": "1.21", "latest": "1.21"},
+		},
+		{
+			"branch suffix has its own namespace",
+			func(versions dockerversions.Versions) {
+				versions["1.22"].Version = "1.22.0"
+				fips := newVersions()["1.21"]
+				fips.BranchSuffix = "-fips"
+				versions["1.21-fips"] = fips
+			},
+			SharedTagRules{},
+			map[string]string{"1": "1.22", "latest": "1.22", "1-fips": "1.21-fips", "latest-fips": "1.21-fips"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := newVersions()
+			tt.modify(versions)
+			UpdateSharedTags(versions, tt.rules)
+
+			var manifest dockermanifest.Manifest
+			UpdateManifest(&manifest, versions)
+
+			owners := make(map[string][]string)
+			for _, image := range manifest.Repos[0].Images {
+				for tag := range image.SharedTags {
+					owners[tag] = append(owners[tag], image.Platforms[0].Dockerfile)
+				}
+			}
+			for tag, key := range tt.wantTags {
+				want := "src/microsoft/" + key + "/bookworm"
+				if got := owners[tag]; len(got) != 1 || got[0] != want {
+					t.Errorf("tag %q is on %v, want only %v", tag, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDockerfileGeneration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake generation script requires sh")
+	}
+
+	tests := []struct {
+		name      string
+		generated string
+		wantErr   error
+	}{
+		{"up to date", "FROM golang\n", nil},
+		{"out of date", "FROM golang:new\n", ErrDockerfilesOutOfDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up a fork-style go-images repo: apply-templates.sh is in the repo root.
+			d := t.TempDir()
+			dockerfileDir := filepath.Join(d, "src", "microsoft", "1.22", "bookworm")
+			if err := os.MkdirAll(dockerfileDir, 0o777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dockerfileDir, "Dockerfile"), []byte("FROM golang\n"), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			script := "#!/bin/sh\nprintf '" + tt.generated + "' > 1.22/bookworm/Dockerfile\n"
+			if err := os.WriteFile(filepath.Join(d, "apply-templates.sh"), []byte(script), 0o777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(d, "Dockerfile-linux.template"), []byte("template"), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			for _, args := range [][]string{
+				{"init", "-q"},
+				{"add", "."},
+				{"-c", "user.name=test", "-c", "user.email=test@example.org", "commit", "-q", "-m", "initial"},
+			} {
+				if err := executil.Run(executil.Dir(d, "git", args...)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := CheckDockerfileGeneration(d, false, false)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("CheckDockerfileGeneration() unexpected error: %v", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckDockerfileGeneration() error = %v, want %v", err, tt.wantErr)
+			} else if !strings.Contains(err.Error(), "src/microsoft/1.22/bookworm/Dockerfile") {
+				t.Errorf("CheckDockerfileGeneration() error = %v, want it to list the changed Dockerfile", err)
+			}
+		})
+	}
+}
+
+func TestDiffDockerfileGeneration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake generation script requires sh")
+	}
+
+	// Set up a fork-style go-images repo: apply-templates.sh is in the repo root. Generation
+	// changes one Dockerfile and adds another.
+	d := t.TempDir()
+	dockerfileDir := filepath.Join(d, "src", "microsoft", "1.22", "bookworm")
+	if err := os.MkdirAll(dockerfileDir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	dockerfile := filepath.Join(dockerfileDir, "Dockerfile")
+	if err := os.WriteFile(dockerfile, []byte("FROM golang\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\n" +
+		"printf 'FROM golang:new\\n' > 1.22/bookworm/Dockerfile\n" +
+		"mkdir -p 1.22/alpine && printf 'FROM alpine\\n' > 1.22/alpine/Dockerfile\n"
+	if err := os.WriteFile(filepath.Join(d, "apply-templates.sh"), []byte(script), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(d, "Dockerfile-linux.template"), []byte("template"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := DiffDockerfileGeneration(d, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"src/microsoft/1.22/bookworm/Dockerfile",
+		"-FROM golang\n",
+		"+FROM golang:new\n",
+		"src/microsoft/1.22/alpine/Dockerfile",
+		"+FROM alpine\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff doesn't contain %q:\n%v", want, diff)
+		}
+	}
+	if strings.Contains(diff, ".template") {
+		t.Errorf("diff contains a template:\n%v", diff)
+	}
+
+	// The repo isn't modified.
+	if content, err := os.ReadFile(dockerfile); err != nil || string(content) != "FROM golang\n" {
+		t.Errorf("Dockerfile content = %q, %v, want unchanged", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(d, "src", "microsoft", "1.22", "alpine")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("generated dir exists in the repo: %v", err)
+	}
+}
+
 func checkGoldenJSON[T any](t *testing.T, goldenPath string, actual T) {
 	if *update {
 		if err := stringutil.WriteJSONFile(goldenPath, actual); err != nil {
@@ -222,3 +565,366 @@ func checkGoldenJSON[T any](t *testing.T, goldenPath string, actual T) {
 		t.Error("Actual result didn't match golden file. Run 'go test ./buildmodel -update' to update golden file.")
 	}
 }
+
+func TestCheckAdditive(t *testing.T) {
+	assetDir := filepath.Join("testdata", "UpdateVersions")
+	read := func(t *testing.T) (dockerversions.Versions, *dockermanifest.Manifest) {
+		var versions dockerversions.Versions
+		if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "versions.json"), &versions); err != nil {
+			t.Fatal(err)
+		}
+		var manifest dockermanifest.Manifest
+		UpdateManifest(&manifest, versions)
+		return versions, &manifest
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T, versions dockerversions.Versions)
+		wantErr bool
+	}{
+		{
+			"version update",
+			func(t *testing.T, versions dockerversions.Versions) {
+				var assets buildassets.BuildAssets
+				if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "assets.json"), &assets); err != nil {
+					t.Fatal(err)
+				}
+				if err := UpdateVersions(&assets, versions); err != nil {
+					t.Fatal(err)
+				}
+			},
+			false,
+		},
+		{
+			"removed version",
+			func(t *testing.T, versions dockerversions.Versions) {
+				delete(versions, "1.18")
+			},
+			true,
+		},
+		{
+			"removed variant",
+			func(t *testing.T, versions dockerversions.Versions) {
+				v := versions["1.18"]
+				v.Variants = v.Variants[1:]
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldVersions, oldManifest := read(t)
+			versions, _ := read(t)
+			tt.modify(t, versions)
+			var manifest dockermanifest.Manifest
+			UpdateManifest(&manifest, versions)
+
+			err := CheckAdditive(oldVersions, versions, oldManifest, &manifest)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotAdditive) {
+					t.Errorf("CheckAdditive() error = %v, want ErrNotAdditive", err)
+				}
+			} else if err != nil {
+				t.Errorf("CheckAdditive() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckAdditive_VersionPrefix(t *testing.T) {
+	oldVersions := dockerversions.Versions{"1.22": {Version: "1.22.1", Revision: "1"}}
+	newVersions := dockerversions.Versions{"1.22": {Version: "1.22.1", Revision: "2"}}
+	manifest := func(tags ...string) *dockermanifest.Manifest {
+		image := &dockermanifest.Image{SharedTags: make(map[string]dockermanifest.Tag)}
+		for _, tag := range tags {
+			image.SharedTags[tag] = dockermanifest.Tag{}
+		}
+		return &dockermanifest.Manifest{Repos: []*dockermanifest.Repo{{Images: []*dockermanifest.Image{image}}}}
+	}
+
+	tests := []struct {
+		name    string
+		removed string
+		wantErr bool
+	}{
+		{"replaced revision", "1.22.1-1", false},
+		{"replaced revision variant", "1.22.1-1-bookworm", false},
+		{"replaced revision fips", "1.22.1-1-fips", false},
+		{"revision with replaced revision as prefix", "1.22.1-10-bookworm", true},
+		{"revision tag with replaced revision as prefix", "1.22.1-11", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckAdditive(oldVersions, newVersions, manifest("1.22", tt.removed), manifest("1.22"))
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotAdditive) {
+					t.Errorf("CheckAdditive() error = %v, want ErrNotAdditive", err)
+				}
+			} else if err != nil {
+				t.Errorf("CheckAdditive() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	newManifest := func() *dockermanifest.Manifest {
+		return &dockermanifest.Manifest{
+			Repos: []*dockermanifest.Repo{{
+				Name: "oss/go/microsoft/golang",
+				Images: []*dockermanifest.Image{{
+					SharedTags: map[string]dockermanifest.Tag{"1.22": {}},
+					Platforms: []*dockermanifest.Platform{{
+						Dockerfile: "src/microsoft/1.22/bookworm",
+						OS:         "linux",
+						Tags:       map[string]dockermanifest.Tag{"1.22.1-1-bookworm-amd64": {}},
+					}},
+				}},
+			}},
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(m *dockermanifest.Manifest)
+		wantErrs []string
+	}{
+		{"valid", func(m *dockermanifest.Manifest) {}, nil},
+		{
+			"duplicate tag",
+			func(m *dockermanifest.Manifest) {
+				m.Repos[0].Images[0].Platforms[0].Tags["1.22"] = dockermanifest.Tag{}
+			},
+			[]string{`repos[0].images[0].platforms[0].tags: duplicate tag "1.22", first used in repos[0].images[0].sharedTags`},
+		},
+		{
+			"missing fields",
+			func(m *dockermanifest.Manifest) {
+				p := m.Repos[0].Images[0].Platforms[0]
+				p.Dockerfile = ""
+				p.OS = ""
+			},
+			[]string{"repos[0].images[0].platforms[0].dockerfile: empty", "repos[0].images[0].platforms[0].os: empty"},
+		},
+		{
+			"missing Dockerfile",
+			func(m *dockermanifest.Manifest) {
+				m.Repos[0].Images[0].Platforms[0].Dockerfile = "src/microsoft/1.23/bookworm"
+			},
+			[]string{`repos[0].images[0].platforms[0].dockerfile: "src/microsoft/1.23/bookworm" doesn't exist`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoRoot := t.TempDir()
+			dockerfileDir := filepath.Join(repoRoot, "src", "microsoft", "1.22", "bookworm")
+			if err := os.MkdirAll(dockerfileDir, 0o777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dockerfileDir, "Dockerfile"), nil, 0o666); err != nil {
+				t.Fatal(err)
+			}
+
+			m := newManifest()
+			tt.modify(m)
+			err := ValidateManifest(m, repoRoot)
+			if tt.wantErrs == nil {
+				if err != nil {
+					t.Fatalf("ValidateManifest() unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidManifest) {
+				t.Fatalf("ValidateManifest() error = %v, want ErrInvalidManifest", err)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateManifest() error doesn't contain %q: %v", want, err)
+				}
+			}
+		})
+	}
+
+}
+
+func TestValidateManifestFile(t *testing.T) {
+	repoRoot := t.TempDir()
+	manifestPath := filepath.Join(repoRoot, "manifest.json")
+	if err := os.WriteFile(manifestPath, []byte(`{"repos": [], "unknownField": true}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	err := ValidateManifestFile(repoRoot)
+	if !errors.Is(err, ErrInvalidManifest) || !strings.Contains(err.Error(), "unknownField") {
+		t.Errorf("ValidateManifestFile() error = %v, want ErrInvalidManifest naming unknownField", err)
+	}
+}
+
+func TestPreviewGoImagesRepoUpdate(t *testing.T) {
+	assetDir := filepath.Join("testdata", "UpdateVersions")
+	repoRoot := t.TempDir()
+	versionsPath := filepath.Join(repoRoot, "src", "microsoft", "versions.json")
+	manifestPath := filepath.Join(repoRoot, "manifest.json")
+
+	var versions dockerversions.Versions
+	if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "versions.json"), &versions); err != nil {
+		t.Fatal(err)
+	}
+	var manifest dockermanifest.Manifest
+	UpdateManifest(&manifest, versions)
+	if err := os.MkdirAll(filepath.Dir(versionsPath), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringutil.WriteJSONFile(versionsPath, versions); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringutil.WriteJSONFile(manifestPath, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	originalVersions, err := os.ReadFile(versionsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalManifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no assets", func(t *testing.T) {
+		changes, err := PreviewGoImagesRepoUpdate(repoRoot, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !changes.Empty() {
+			t.Errorf("PreviewGoImagesRepoUpdate() = %v, want no changes", changes)
+		}
+	})
+
+	t.Run("version update", func(t *testing.T) {
+		var assets buildassets.BuildAssets
+		if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "assets.json"), &assets); err != nil {
+			t.Fatal(err)
+		}
+		changes, err := PreviewGoImagesRepoUpdate(repoRoot, []*buildassets.BuildAssets{&assets}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := deep.Equal(changes.ChangedVersions, []string{"1.18"}); diff != nil {
+			t.Errorf("ChangedVersions: %v", diff)
+		}
+		if len(changes.AddedVersions) != 0 || len(changes.RemovedVersions) != 0 {
+			t.Errorf("PreviewGoImagesRepoUpdate() = %v, want no added or removed versions", changes)
+		}
+		if len(changes.AddedTags) == 0 {
+			t.Errorf("PreviewGoImagesRepoUpdate() = %v, want added tags", changes)
+		}
+	})
+
+	for path, want := range map[string][]byte{versionsPath: originalVersions, manifestPath: originalManifest} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%v was modified by the preview", path)
+		}
+	}
+}
+
+func TestDiffGoImagesRepoUpdate(t *testing.T) {
+	assetDir := filepath.Join("testdata", "UpdateVersions")
+	repoRoot := t.TempDir()
+	versionsPath := filepath.Join(repoRoot, "src", "microsoft", "versions.json")
+	manifestPath := filepath.Join(repoRoot, "manifest.json")
+
+	var versions dockerversions.Versions
+	if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "versions.json"), &versions); err != nil {
+		t.Fatal(err)
+	}
+	var manifest dockermanifest.Manifest
+	UpdateManifest(&manifest, versions)
+	if err := os.MkdirAll(filepath.Dir(versionsPath), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringutil.WriteJSONFile(versionsPath, versions); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringutil.WriteJSONFile(manifestPath, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	originalVersions, err := os.ReadFile(versionsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := DiffGoImagesRepoUpdate(repoRoot, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Errorf("DiffGoImagesRepoUpdate() without assets = %q, want no diff", diff)
+	}
+
+	var assets buildassets.BuildAssets
+	if err := stringutil.ReadJSONFile(filepath.Join(assetDir, "assets.json"), &assets); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = DiffGoImagesRepoUpdate(repoRoot, []*buildassets.BuildAssets{&assets}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The diff labels files by their path in the repo and adds the tags for the new version.
+	for _, want := range []string{"\n+++ b/src/microsoft/versions.json\n", "\n+++ b/manifest.json\n", "\n+ ", `"1.18.1-1-bullseye-arm64v8": {}`} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("DiffGoImagesRepoUpdate() diff doesn't contain %q:\n%v", want, diff)
+		}
+	}
+
+	got, err := os.ReadFile(versionsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(originalVersions) {
+		t.Errorf("versions.json was modified by the diff")
+	}
+}
+
+func TestBumpRevision(t *testing.T) {
+	read := func(t *testing.T) dockerversions.Versions {
+		var versions dockerversions.Versions
+		if err := stringutil.ReadJSONFile(filepath.Join("testdata", "UpdateVersions", "versions.json"), &versions); err != nil {
+			t.Fatal(err)
+		}
+		return versions
+	}
+	tests := []struct {
+		name         string
+		channel      string
+		revision     string
+		wantRevision string
+		wantErr      error
+	}{
+		{"set", "1.18", "5", "5", nil},
+		{"increment", "1.18", "", "2", nil},
+		{"unknown channel", "1.99", "5", "", ErrUnknownChannel},
+		{"invalid revision", "1.18", "five", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := read(t)
+			oldVersion := versions["1.18"].Version
+			err := BumpRevision(versions, tt.channel, tt.revision)
+			if tt.wantRevision == "" {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Errorf("BumpRevision() error = %v, want error %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := versions[tt.channel]; got.Revision != tt.wantRevision || got.Version != oldVersion {
+				t.Errorf("BumpRevision() = %v-%v, want %v-%v", got.Version, got.Revision, oldVersion, tt.wantRevision)
+			}
+		})
+	}
+}
@@ -4,15 +4,21 @@
 package buildmodel
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/microsoft/go-infra/buildmodel/buildassets"
 	"github.com/microsoft/go-infra/buildmodel/dockermanifest"
@@ -60,7 +66,8 @@ type BuildAssetJSONFlags struct {
 	destinationManifest *string
 	sourceDir           *string
 
-	output *string
+	output  *string
+	signKey *string
 }
 
 // BindBuildAssetJSONFlags creates BuildAssetJSONFlags with the 'flag' package, globally registering
@@ -69,11 +76,12 @@ func BindBuildAssetJSONFlags() *BuildAssetJSONFlags {
 	return &BuildAssetJSONFlags{
 		artifactsDir:        flag.String("artifacts-dir", "eng/artifacts/bin", "The path of the directory to scan for artifacts."),
 		branch:              flag.String("branch", "unknown", "The name of the branch that produced these artifacts."),
-		destinationURL:      flag.String("destination-url", "https://example.org/default", "The base URL where all files in the source directory can be downloaded from, if one exists."),
+		destinationURL:      flag.String("destination-url", "https://example.org/default", "The base URL where all files in the source directory can be downloaded from, if one exists. May contain the placeholders {version}, {platform}, {os}, and {arch} to publish each platform under a different path."),
 		destinationManifest: flag.String("destination-manifest-file", "", "The path of a manifest file that lists where each artifact has been published to. Fails if a file doesn't match up. Causes destinationURL to be ignored."),
 		sourceDir:           flag.String("source-dir", "", "The path of the source code directory to scan for a VERSION file."),
 
-		output: flag.String("o", "assets.json", "The path of the build asset JSON file to create."),
+		output:  flag.String("o", "assets.json", "The path of the build asset JSON file to create."),
+		signKey: flag.String("sign-key", "", "The path of a PEM-encoded PKCS #8 Ed25519 private key. If set, also create a detached signature of the build asset JSON file at the output path + '"+SignatureFileSuffix+"'."),
 	}
 }
 
@@ -105,6 +113,12 @@ func GenerateBuildAssetJSON(f *BuildAssetJSONFlags) error {
 	if err := stringutil.WriteJSONFile(*f.output, m); err != nil {
 		return err
 	}
+	if *f.signKey != "" {
+		if err := SignFile(*f.output, *f.signKey); err != nil {
+			return fmt.Errorf("failed to sign build asset JSON file: %w", err)
+		}
+		fmt.Printf("Signed build asset JSON file: %v\n", *f.output+SignatureFileSuffix)
+	}
 	return nil
 }
 
@@ -167,23 +181,21 @@ func SubmitUpdatePR(f *PRFlags) error {
 		f.to = f.origin
 	}
 
-	var assets *buildassets.BuildAssets
-	if *f.buildAssetJSON != "" {
-		assets = new(buildassets.BuildAssets)
-		if err := stringutil.ReadJSONFile(*f.buildAssetJSON, &assets); err != nil {
-			return err
-		}
+	assets, err := f.readBuildAssets()
+	if err != nil {
+		return err
 	}
 
-	targetBranch := *f.manualBranch
-	if targetBranch == "" && assets != nil {
-		targetBranch = assets.GetDockerRepoTargetBranch()
+	targetBranch, reason, err := f.targetBranch(assets)
+	if err != nil {
+		return err
 	}
 	if targetBranch == "" {
+		fmt.Println(reason)
 		fmt.Println("This build assets JSON file isn't associated with any Docker image repo branch.\nSee the GetDockerRepoTargetBranch Go func in 'buildmodel/buildassets'.")
 		return nil
 	}
-	fmt.Printf("---- Target branch for PR: %v\n", targetBranch)
+	fmt.Printf("---- Target branch for PR: %v\n%v\n", targetBranch, reason)
 
 	b := gitpr.PRRefSet{
 		Name:    targetBranch,
@@ -237,6 +249,20 @@ func SubmitUpdatePR(f *PRFlags) error {
 				fmt.Printf("---- No PR found.\n")
 			}
 		}
+
+		// Without an existing PR, the update is based on the target branch. Check if it's already
+		// up to date through the GitHub API, which is much faster than cloning.
+		if parsedOrigin != nil && existingPR == nil {
+			fmt.Println("---- Checking whether versions.json and manifest.json are already up to date...")
+			commit, err := upToDateCommit(gitpr.DefaultClient, parsedOrigin.GetOwnerSlashRepo(), b.Name, assets, f.sharedTagRules(), *f.githubPAT)
+			if err != nil {
+				fmt.Printf("---- Quick check inconclusive, continuing with full update: %v\n", err)
+			} else if commit != "" {
+				f.SetAzDOVariables("nil", commit)
+				fmt.Printf("---- No updates to %v at %v. Skipping.\n", b.Name, commit)
+				return nil
+			}
+		}
 	}
 
 	// We're updating the target repo inside a clone of the go-infra repo, so we want a fresh clone.
@@ -259,13 +285,16 @@ func SubmitUpdatePR(f *PRFlags) error {
 	runOrPanic(newGitCmd("checkout", b.PRBranch()))
 
 	// Make changes to the files in the temp repo.
-	if err := UpdateGoImagesRepo(gitDir, assets); err != nil {
+	if err := UpdateGoImagesRepo(gitDir, assets, *f.additiveOnly, f.sharedTagRules()); err != nil {
 		return err
 	}
 	if !*f.skipDockerfiles {
 		if err := RunDockerfileGeneration(gitDir, *f.forcePrePatchReset, *f.skipSubmoduleUpdate); err != nil {
 			return err
 		}
+		if err := ValidateManifestFile(gitDir); err != nil {
+			return err
+		}
 	}
 
 	// Add changes to stage. If the submodule has changes (due to "git apply" during the Dockerfile
@@ -311,8 +340,12 @@ func SubmitUpdatePR(f *PRFlags) error {
 	}
 
 	commitMessage := "Update " + b.Name
-	if assets != nil {
-		commitMessage += " to " + assets.Version
+	if len(assets) > 0 {
+		versions := make([]string, 0, len(assets))
+		for _, a := range assets {
+			versions = append(versions, a.Version)
+		}
+		commitMessage += " to " + strings.Join(versions, ", ")
 	}
 
 	runOrPanic(newGitCmd("commit", "-m", commitMessage))
@@ -352,29 +385,33 @@ func SubmitUpdatePR(f *PRFlags) error {
 
 	fmt.Printf("---- PR for %v: Submitting...\n", b.Name)
 
-	if existingPR == nil {
-		// POST the PR. The call returns success if the PR is created or if we receive a specific error
-		// message back from GitHub saying the PR is already created.
-		p, err := gitpr.PostGitHub(
-			parsedOrigin.GetOwnerSlashRepo(),
-			request,
-			*f.githubPAT)
-		fmt.Printf("%+v\n", p)
-		if err != nil {
-			return err
-		}
+	// Submit the PR, or refresh the title and description of the existing one. An existing PR has
+	// already been approved.
+	p, created, err := gitpr.EnsurePR(
+		parsedOrigin,
+		parsedPRHeadRemote,
+		request,
+		gitpr.GetUsername(*f.githubPAT),
+		*f.githubPAT)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%+v\n", p)
 
-		// For the rest of the method, the PR now exists.
-		existingPR = &gitpr.ExistingPR{
-			ID:     p.NodeID,
-			Number: p.Number,
-		}
+	// For the rest of the method, the PR now exists.
+	existingPR = &gitpr.ExistingPR{
+		ID:     p.NodeID,
+		Number: p.Number,
+	}
+	if created {
 		fmt.Printf("---- Submitted brand new PR: %v\n", p.HTMLURL)
 
 		fmt.Printf("---- Approving with reviewer account...\n")
 		if err = gitpr.ApprovePR(existingPR.ID, *f.githubPATReviewer); err != nil {
 			return err
 		}
+	} else {
+		fmt.Printf("---- Updated existing PR: %v\n", p.HTMLURL)
 	}
 
 	fmt.Printf("---- Enabling auto-merge with reviewer account...\n")
@@ -389,25 +426,175 @@ func SubmitUpdatePR(f *PRFlags) error {
 	return nil
 }
 
+// upToDateCommit uses the GitHub API to check whether updating the model files in branch of
+// ownerRepo with assets, and recomputing shared tags if sharedTagRules is not nil, would be a
+// no-op. If so, returns the commit of branch that is already up to date. If an update is needed,
+// returns "". Returns an error if the check is inconclusive, in which case the caller should fall
+// back to the full update.
+//
+// This checks versions.json and manifest.json, the files the update writes directly. It doesn't
+// check the Dockerfiles: if versions.json and manifest.json are up to date, an update would only
+// change the Dockerfiles if their templates changed, and that is left to the repo's CI to catch.
+func upToDateCommit(c *gitpr.Client, ownerRepo, branch string, assets []*buildassets.BuildAssets, sharedTagRules *SharedTagRules, pat string) (string, error) {
+	commit, err := c.GetRefCommit(ownerRepo, branch, pat)
+	if err != nil {
+		return "", err
+	}
+	// Read the file at the commit rather than the branch, in case the branch moves in between.
+	content, err := c.GetFileContent(ownerRepo, commit, "src/microsoft/versions.json", pat)
+	if err != nil {
+		return "", err
+	}
+
+	// The update modifies the model in place, so parse a separate copy to compare against.
+	var versions, updated dockerversions.Versions
+	if err := json.Unmarshal(content, &versions); err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(content, &updated); err != nil {
+		return "", err
+	}
+	if len(assets) > 0 {
+		if err := UpdateVersionsMulti(assets, updated); err != nil {
+			return "", err
+		}
+	}
+	if sharedTagRules != nil {
+		UpdateSharedTags(updated, *sharedTagRules)
+	}
+	if !reflect.DeepEqual(versions, updated) {
+		return "", nil
+	}
+
+	// The update also regenerates manifest.json, which may be stale even if versions.json isn't.
+	manifestContent, err := c.GetFileContent(ownerRepo, commit, "manifest.json", pat)
+	if err != nil {
+		return "", err
+	}
+	var manifest dockermanifest.Manifest
+	if err := json.Unmarshal(manifestContent, &manifest); err != nil {
+		return "", err
+	}
+	UpdateManifest(&manifest, updated)
+	updatedManifest, err := stringutil.MarshalJSONFile(&manifest)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(manifestContent, updatedManifest) {
+		return "", nil
+	}
+	return commit, nil
+}
+
+// targetBranch returns the Go Docker images repo branch that a PR for assets targets, or empty
+// string if there is none, along with a description of how the branch was chosen.
+func (f *PRFlags) targetBranch(assets []*buildassets.BuildAssets) (branch, reason string, err error) {
+	if *f.manualBranch != "" {
+		return *f.manualBranch, "overridden by -manual-branch", nil
+	}
+	if len(assets) == 0 {
+		return "", "no build asset JSON file specified", nil
+	}
+	// A single PR can only target one branch. Assets that belong to other branches need separate
+	// PRs.
+	branches, err := buildassets.DockerRepoTargetBranches(assets)
+	if err != nil {
+		return "", err.Error(), nil
+	}
+	if len(branches) > 1 {
+		return "", "", fmt.Errorf("build assets target multiple Docker image repo branches, submit a separate PR for each: %v", strings.Join(branches, ", "))
+	}
+	reasons := make([]string, 0, len(assets))
+	for _, a := range assets {
+		_, r := a.DockerRepoTargetBranchReason()
+		reasons = append(reasons, fmt.Sprintf("%v (branch %q): %v", a.Version, a.Branch, r))
+	}
+	return branches[0], strings.Join(reasons, "\n"), nil
+}
+
+// ShowTargetBranch prints the Go Docker images repo branch that SubmitUpdatePR would target with
+// the given flags, and the rule that selected it. It doesn't do any Git work.
+func ShowTargetBranch(f *PRFlags) error {
+	assets, err := f.readBuildAssets()
+	if err != nil {
+		return err
+	}
+	branch, reason, err := f.targetBranch(assets)
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		fmt.Printf("No target branch.\n%v\n", reason)
+		return nil
+	}
+	fmt.Printf("Target branch: %v\n%v\n", branch, reason)
+	return nil
+}
+
 // UpdateFlags is a list of flags used for an update command.
 type UpdateFlags struct {
 	buildAssetJSON      *string
 	skipDockerfiles     *bool
 	forcePrePatchReset  *bool
 	skipSubmoduleUpdate *bool
+	additiveOnly        *bool
+
+	updateSharedTags            *bool
+	sharedTagsIncludePrerelease *bool
+	sharedTagsExclude           *string
 }
 
 // BindUpdateFlags creates UpdateFlags with the 'flag' package, globally registering them in
 // the flag package so ParseBoundFlags will find them.
 func BindUpdateFlags() *UpdateFlags {
 	return &UpdateFlags{
-		buildAssetJSON:      flag.String("build-asset-json", "", "The path of a build asset JSON file describing the Go build to update to."),
+		buildAssetJSON:      flag.String("build-asset-json", "", "The path of a build asset JSON file describing the Go build to update to.\nTo update multiple versions at once, separate paths with ','."),
 		skipDockerfiles:     flag.Bool("skip-dockerfiles", false, "If set, don't touch Dockerfiles.\nUpdating Dockerfiles requires bash/awk/jq, so when developing on Windows, skipping may be useful."),
 		forcePrePatchReset:  flag.Bool("f", false, "Force reset the submodule before applying patches."),
 		skipSubmoduleUpdate: flag.Bool("skip-submodule-update", false, "Skip updating the submodule before running the update.\nUseful for testing out WIP patches."),
+		additiveOnly:        flag.Bool("additive-only", false, "Fail the update if it would remove a version, variant, or image tag.\nOnly additions and in-place modifications, like a patch version update, are allowed."),
+
+		updateSharedTags:            flag.Bool("update-shared-tags", false, "Recompute which versions receive the shared major-only and 'latest' tags, based on the highest version of each.\nIf not set, the preferredMajor and preferredMinor values in versions.json are used as-is."),
+		sharedTagsIncludePrerelease: flag.Bool("shared-tags-include-prerelease", false, "With -update-shared-tags, allow beta and rc versions to receive shared tags."),
+		sharedTagsExclude:           flag.String("shared-tags-exclude", "", "With -update-shared-tags, a comma-separated list of versions.json keys that never receive shared tags."),
 	}
 }
 
+// sharedTagRules returns the rules to use to recompute shared tags, or nil if shared tags should
+// not be recomputed.
+func (f *UpdateFlags) sharedTagRules() *SharedTagRules {
+	if !*f.updateSharedTags {
+		return nil
+	}
+	rules := &SharedTagRules{
+		IncludePrerelease: *f.sharedTagsIncludePrerelease,
+	}
+	if *f.sharedTagsExclude != "" {
+		rules.ExcludeKeys = strings.Split(*f.sharedTagsExclude, ",")
+	}
+	return rules
+}
+
+// readBuildAssets reads and validates each build asset JSON file passed to -build-asset-json.
+// Returns nil if the flag isn't set.
+func (f *UpdateFlags) readBuildAssets() ([]*buildassets.BuildAssets, error) {
+	if *f.buildAssetJSON == "" {
+		return nil, nil
+	}
+	var assets []*buildassets.BuildAssets
+	for _, path := range strings.Split(*f.buildAssetJSON, ",") {
+		a := new(buildassets.BuildAssets)
+		if err := stringutil.ReadJSONFile(path, a); err != nil {
+			return nil, err
+		}
+		if err := a.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid build asset JSON file %q: %w", path, err)
+		}
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
 // RunUpdate updates the given Go Docker image repository with the provided flags.
 func RunUpdate(repoRoot string, f *UpdateFlags) error {
 	if !*f.skipDockerfiles {
@@ -416,15 +603,12 @@ func RunUpdate(repoRoot string, f *UpdateFlags) error {
 		}
 	}
 
-	var assets *buildassets.BuildAssets
-	if *f.buildAssetJSON != "" {
-		assets = new(buildassets.BuildAssets)
-		if err := stringutil.ReadJSONFile(*f.buildAssetJSON, &assets); err != nil {
-			return err
-		}
+	assets, err := f.readBuildAssets()
+	if err != nil {
+		return err
 	}
 
-	if err := UpdateGoImagesRepo(repoRoot, assets); err != nil {
+	if err := UpdateGoImagesRepo(repoRoot, assets, *f.additiveOnly, f.sharedTagRules()); err != nil {
 		return err
 	}
 
@@ -432,14 +616,38 @@ func RunUpdate(repoRoot string, f *UpdateFlags) error {
 		if err := RunDockerfileGeneration(repoRoot, *f.forcePrePatchReset, *f.skipSubmoduleUpdate); err != nil {
 			return err
 		}
+		if err := ValidateManifestFile(repoRoot); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 // UpdateGoImagesRepo runs an auto-update process in the given Go Docker images repository. It finds
 // the 'versions.json' and 'manifest.json' files and updates them based on the given build assets
-// struct. If the struct pointer is nil, only updates the 'manifest.json'.
-func UpdateGoImagesRepo(repoRoot string, b *buildassets.BuildAssets) error {
+// structs, applying all of them in one pass so a single update covers every version. See
+// UpdateVersionsMulti. If assets is empty, only updates the 'manifest.json'. If additiveOnly is
+// true, returns an error without writing any files if the update isn't additive. See CheckAdditive.
+// If sharedTagRules is not nil, recomputes which versions receive shared tags. See
+// UpdateSharedTags.
+func UpdateGoImagesRepo(repoRoot string, assets []*buildassets.BuildAssets, additiveOnly bool, sharedTagRules *SharedTagRules) error {
+	return updateGoImagesRepo(repoRoot, assets, additiveOnly, sharedTagRules, nil)
+}
+
+// DiffGoImagesRepoUpdate runs the same update as UpdateGoImagesRepo, but rather than writing the
+// 'versions.json' and 'manifest.json' files, returns a unified diff of the changes it would make.
+// Returns empty string if the update doesn't change either file.
+func DiffGoImagesRepoUpdate(repoRoot string, assets []*buildassets.BuildAssets, additiveOnly bool, sharedTagRules *SharedTagRules) (string, error) {
+	var diff strings.Builder
+	if err := updateGoImagesRepo(repoRoot, assets, additiveOnly, sharedTagRules, &diff); err != nil {
+		return "", err
+	}
+	return diff.String(), nil
+}
+
+// updateGoImagesRepo implements UpdateGoImagesRepo. If diff is not nil, writes a unified diff of
+// each file to diff instead of writing the files.
+func updateGoImagesRepo(repoRoot string, assets []*buildassets.BuildAssets, additiveOnly bool, sharedTagRules *SharedTagRules, diff io.Writer) error {
 	versionsJSONPath := filepath.Join(repoRoot, "src", "microsoft", "versions.json")
 	manifestJSONPath := filepath.Join(repoRoot, "manifest.json")
 
@@ -453,18 +661,56 @@ func UpdateGoImagesRepo(repoRoot string, b *buildassets.BuildAssets) error {
 		return err
 	}
 
-	if b != nil {
-		if err := UpdateVersions(b, versions); err != nil {
+	// The update modifies the models in place, so read a separate copy of the original state.
+	var oldVersions dockerversions.Versions
+	var oldManifest dockermanifest.Manifest
+	if additiveOnly {
+		if err := stringutil.ReadJSONFile(versionsJSONPath, &oldVersions); err != nil {
 			return err
 		}
-		if err := stringutil.WriteJSONFile(versionsJSONPath, &versions); err != nil {
+		if err := stringutil.ReadJSONFile(manifestJSONPath, &oldManifest); err != nil {
 			return err
 		}
 	}
 
-	fmt.Printf("Generating '%v' based on '%v'...\n", manifestJSONPath, versionsJSONPath)
+	if len(assets) > 0 {
+		if err := UpdateVersionsMulti(assets, versions); err != nil {
+			return err
+		}
+	}
+	if sharedTagRules != nil {
+		UpdateSharedTags(versions, *sharedTagRules)
+	}
+
+	if diff == nil {
+		fmt.Printf("Generating '%v' based on '%v'...\n", manifestJSONPath, versionsJSONPath)
+	}
 
 	UpdateManifest(&manifest, versions)
+
+	// The Dockerfiles for new versions haven't been generated yet, so don't check for them.
+	if err := ValidateManifest(&manifest, ""); err != nil {
+		return err
+	}
+
+	if additiveOnly {
+		if err := CheckAdditive(oldVersions, versions, &oldManifest, &manifest); err != nil {
+			return err
+		}
+	}
+
+	if diff != nil {
+		if err := writeJSONFileDiff(diff, repoRoot, versionsJSONPath, &versions); err != nil {
+			return err
+		}
+		return writeJSONFileDiff(diff, repoRoot, manifestJSONPath, &manifest)
+	}
+
+	if len(assets) > 0 || sharedTagRules != nil {
+		if err := stringutil.WriteJSONFile(versionsJSONPath, &versions); err != nil {
+			return err
+		}
+	}
 	if err := stringutil.WriteJSONFile(manifestJSONPath, &manifest); err != nil {
 		return err
 	}
@@ -472,6 +718,133 @@ func UpdateGoImagesRepo(repoRoot string, b *buildassets.BuildAssets) error {
 	return nil
 }
 
+// writeJSONFileDiff writes a unified diff between the file at path and the content
+// stringutil.WriteJSONFile would write for i. The diff labels the file with its path relative to
+// repoRoot.
+func writeJSONFileDiff(w io.Writer, repoRoot, path string, i interface{}) error {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	updated, err := stringutil.MarshalJSONFile(i)
+	if err != nil {
+		return err
+	}
+	name, err := filepath.Rel(repoRoot, path)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, stringutil.UnifiedDiff(filepath.ToSlash(name), old, updated))
+	return err
+}
+
+// RunPreview computes the update described by the flags in memory and prints the versions.json
+// channels and manifest.json tags it would change. Doesn't write any files or run Git.
+func RunPreview(repoRoot string, f *UpdateFlags) error {
+	assets, err := f.readBuildAssets()
+	if err != nil {
+		return err
+	}
+	changes, err := PreviewGoImagesRepoUpdate(repoRoot, assets, f.sharedTagRules())
+	if err != nil {
+		return err
+	}
+	fmt.Print(changes)
+	return nil
+}
+
+// RunBumpRevision sets the Microsoft revision of a single versions.json channel, then regenerates
+// the manifest and, unless the flags say to skip them, the Dockerfiles. See BumpRevision.
+func RunBumpRevision(repoRoot string, f *UpdateFlags, channel, revision string) error {
+	if !*f.skipDockerfiles {
+		if err := EnsureDockerfileGenerationPrerequisites(); err != nil {
+			return err
+		}
+	}
+
+	versionsJSONPath := filepath.Join(repoRoot, "src", "microsoft", "versions.json")
+	var versions dockerversions.Versions
+	if err := stringutil.ReadJSONFile(versionsJSONPath, &versions); err != nil {
+		return err
+	}
+	if err := BumpRevision(versions, channel, revision); err != nil {
+		return err
+	}
+	fmt.Printf("Setting revision of %v to %v...\n", channel, versions[channel].Revision)
+	if err := stringutil.WriteJSONFile(versionsJSONPath, &versions); err != nil {
+		return err
+	}
+
+	// Regenerate the manifest from the updated versions.json.
+	if err := UpdateGoImagesRepo(repoRoot, nil, false, nil); err != nil {
+		return err
+	}
+
+	if !*f.skipDockerfiles {
+		if err := RunDockerfileGeneration(repoRoot, *f.forcePrePatchReset, *f.skipSubmoduleUpdate); err != nil {
+			return err
+		}
+		if err := ValidateManifestFile(repoRoot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDiff computes the update described by the flags and prints a unified diff of the changes it
+// would make to 'versions.json' and 'manifest.json'. Doesn't write any files, generate Dockerfiles,
+// or run Git.
+func RunDiff(repoRoot string, f *UpdateFlags) error {
+	assets, err := f.readBuildAssets()
+	if err != nil {
+		return err
+	}
+	diff, err := DiffGoImagesRepoUpdate(repoRoot, assets, *f.additiveOnly, f.sharedTagRules())
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		fmt.Println("No changes.")
+		return nil
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+// PreviewGoImagesRepoUpdate applies the same update as UpdateGoImagesRepo to in-memory copies of
+// the 'versions.json' and 'manifest.json' files in the given Go Docker images repository and
+// returns the channels and tags that would change. See DiffUpdate.
+func PreviewGoImagesRepoUpdate(repoRoot string, assets []*buildassets.BuildAssets, sharedTagRules *SharedTagRules) (*UpdateChanges, error) {
+	versionsJSONPath := filepath.Join(repoRoot, "src", "microsoft", "versions.json")
+	manifestJSONPath := filepath.Join(repoRoot, "manifest.json")
+
+	// Read each file twice: the update modifies one copy in place.
+	var oldVersions, versions dockerversions.Versions
+	var oldManifest, manifest dockermanifest.Manifest
+	for _, v := range []*dockerversions.Versions{&oldVersions, &versions} {
+		if err := stringutil.ReadJSONFile(versionsJSONPath, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range []*dockermanifest.Manifest{&oldManifest, &manifest} {
+		if err := stringutil.ReadJSONFile(manifestJSONPath, m); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(assets) > 0 {
+		if err := UpdateVersionsMulti(assets, versions); err != nil {
+			return nil, err
+		}
+	}
+	if sharedTagRules != nil {
+		UpdateSharedTags(versions, *sharedTagRules)
+	}
+	UpdateManifest(&manifest, versions)
+
+	return DiffUpdate(oldVersions, versions, &oldManifest, &manifest), nil
+}
+
 // EnsureDockerfileGenerationPrerequisites checks if Dockerfile generation prerequisites are
 // satisfied and returns a descriptive error if not.
 func EnsureDockerfileGenerationPrerequisites() error {
@@ -558,6 +931,176 @@ func RunDockerfileGeneration(repoRoot string, forceSubmoduleReset, skipSubmodule
 	return run(cmd)
 }
 
+// ErrDockerfilesOutOfDate indicates that CheckDockerfileGeneration found that generating the
+// Dockerfiles changes them, so the checked-in Dockerfiles are out of date.
+var ErrDockerfilesOutOfDate = errors.New("generated Dockerfiles differ from checked-in Dockerfiles")
+
+// RunCheck regenerates the Dockerfiles in the given Go Docker image repository using the provided
+// flags and checks that the checked-in Dockerfiles were already up to date. See
+// CheckDockerfileGeneration.
+func RunCheck(repoRoot string, f *UpdateFlags) error {
+	if err := EnsureDockerfileGenerationPrerequisites(); err != nil {
+		return err
+	}
+	return CheckDockerfileGeneration(repoRoot, *f.forcePrePatchReset, *f.skipSubmoduleUpdate)
+}
+
+// CheckDockerfileGeneration runs RunDockerfileGeneration in the given go-images repo root and uses
+// Git to check that it didn't change any files in "src/microsoft". If it did, prints the diff and
+// returns an error wrapping ErrDockerfilesOutOfDate that lists the changed files. The changes are
+// left in the working tree for inspection.
+//
+// Returns an error without generating Dockerfiles if "src/microsoft" already has uncommitted
+// changes, because they would be indistinguishable from changes made by the generator.
+func CheckDockerfileGeneration(repoRoot string, forceSubmoduleReset, skipSubmoduleUpdate bool) error {
+	changed, err := changedDockerfileGenerationFiles(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("unable to check Dockerfile generation, uncommitted changes found: %v", strings.Join(changed, ", "))
+	}
+
+	if err := RunDockerfileGeneration(repoRoot, forceSubmoduleReset, skipSubmoduleUpdate); err != nil {
+		return err
+	}
+
+	changed, err = changedDockerfileGenerationFiles(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Println("Check ok: generated Dockerfiles match checked-in Dockerfiles.")
+		return nil
+	}
+	// Show the diff to help the dev figure out what happened. Untracked files aren't included, but
+	// they are in the list of changed files.
+	if err := run(executil.Dir(repoRoot, "git", "--no-pager", "diff", "--", dockerfileGenerationPath)); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrDockerfilesOutOfDate, strings.Join(changed, ", "))
+}
+
+// RunDockerfileDiff regenerates the Dockerfiles in a scratch copy of the given Go Docker image
+// repository and prints a unified diff of each file generation would change. The repository itself
+// isn't modified. See DiffDockerfileGeneration.
+func RunDockerfileDiff(repoRoot string, f *UpdateFlags) error {
+	if err := EnsureDockerfileGenerationPrerequisites(); err != nil {
+		return err
+	}
+	diff, err := DiffDockerfileGeneration(repoRoot, *f.forcePrePatchReset, *f.skipSubmoduleUpdate)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		fmt.Println("No changes: generated Dockerfiles match checked-in Dockerfiles.")
+		return nil
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+// DiffDockerfileGeneration copies the given go-images repo root into a temporary directory, runs
+// RunDockerfileGeneration in the copy, and returns a unified diff of each file in "src/microsoft"
+// that generation changed, added, or removed. Returns empty string if nothing changed.
+//
+// Unlike CheckDockerfileGeneration, this doesn't modify the repo, so uncommitted changes are
+// allowed: they're part of the comparison.
+func DiffDockerfileGeneration(repoRoot string, forceSubmoduleReset, skipSubmoduleUpdate bool) (string, error) {
+	scratch, err := os.MkdirTemp("", "go-images-dockerfile-diff-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(scratch)
+
+	// Copy the whole repo, including ".git": a submodule-based repo uses Git to reset and patch
+	// the submodule before generation.
+	fmt.Printf("---- Copying %q to scratch directory %q...\n", repoRoot, scratch)
+	if err := copyDir(repoRoot, scratch); err != nil {
+		return "", err
+	}
+	if err := RunDockerfileGeneration(scratch, forceSubmoduleReset, skipSubmoduleUpdate); err != nil {
+		return "", err
+	}
+
+	dir := filepath.FromSlash(dockerfileGenerationPath)
+	old, err := readDockerfileGenerationFiles(filepath.Join(repoRoot, dir))
+	if err != nil {
+		return "", err
+	}
+	generated, err := readDockerfileGenerationFiles(filepath.Join(scratch, dir))
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(old)+len(generated))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range generated {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(stringutil.UnifiedDiff(dockerfileGenerationPath+"/"+name, old[name], generated[name]))
+	}
+	return b.String(), nil
+}
+
+// readDockerfileGenerationFiles returns the content of each file in dir, keyed by its slash-separated
+// path relative to dir. Templates are skipped: generation copies them into dir temporarily.
+func readDockerfileGenerationFiles(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".template") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	return files, err
+}
+
+// dockerfileGenerationPath is the path, relative to the go-images repo root, where Dockerfile
+// generation writes files.
+const dockerfileGenerationPath = "src/microsoft"
+
+// changedDockerfileGenerationFiles returns the files in the Dockerfile generation directory that
+// Git reports as modified, added, deleted, or untracked.
+func changedDockerfileGenerationFiles(repoRoot string) ([]string, error) {
+	out, err := executil.CombinedOutput(executil.Dir(
+		repoRoot,
+		"git", "status", "--porcelain=v1", "--untracked-files=all", "--", dockerfileGenerationPath))
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		// Dockerfile generation copies the templates into the directory temporarily. They aren't
+		// checked in, so they may show up as untracked.
+		if strings.HasSuffix(path, ".template") {
+			continue
+		}
+		changed = append(changed, path)
+	}
+	return changed, nil
+}
+
 // getwd gets the current working dir or panics, for easy use in expressions.
 func getwd() string {
 	wd, err := os.Getwd()
@@ -598,14 +1141,50 @@ func copyDockerfileTemplates(srcDir, dstDir string) error {
 	return nil
 }
 
+// copyDir copies the files, dirs, and symlinks in src into dst, preserving permissions.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target)
+		}
+	})
+}
+
+// copyFile copies the content of src to dst. If dst doesn't exist, it's created with the same
+// permissions as src.
 func copyFile(src, dst string) (err error) {
 	s, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer s.Close()
+	info, err := s.Stat()
+	if err != nil {
+		return err
+	}
 
-	d, err := os.Create(dst)
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package buildmodel
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignatureFileSuffix is appended to the path of a file to get the path of its detached signature.
+const SignatureFileSuffix = ".sig"
+
+// ErrInvalidSignature indicates that a detached signature doesn't match the signed file.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// SignFile signs the content of the file at path with the Ed25519 private key in the PEM-encoded
+// PKCS #8 file at keyPath. Writes the base64-encoded signature to path + SignatureFileSuffix.
+func SignFile(path, keyPath string) error {
+	key, err := readSigningKey(keyPath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, content))
+	return os.WriteFile(path+SignatureFileSuffix, []byte(sig+"\n"), 0o666)
+}
+
+// VerifyFileSignature checks the detached signature of the file at path, created by SignFile,
+// against the given public key. Returns an error wrapping ErrInvalidSignature if it doesn't match.
+func VerifyFileSignature(path string, publicKey ed25519.PublicKey) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sigContent, err := os.ReadFile(path + SignatureFileSuffix)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigContent)))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature of %v: %w", path, err)
+	}
+	if !ed25519.Verify(publicKey, content, sig) {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, path)
+	}
+	return nil
+}
+
+func readSigningKey(keyPath string) (ed25519.PrivateKey, error) {
+	keyContent, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyContent)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in signing key file %v", keyPath)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key file %v: %w", keyPath, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key file %v contains a %T, not an Ed25519 private key", keyPath, key)
+	}
+	return edKey, nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package buildmodel
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignFile(t *testing.T) {
+	dir := t.TempDir()
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "assets.json")
+	if err := os.WriteFile(path, []byte(`{"version": "1.22.1-1"}`+"\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SignFile(path, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFileSignature(path, publicKey); err != nil {
+		t.Errorf("VerifyFileSignature() unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": "1.22.2-1"}`+"\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFileSignature(path, publicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyFileSignature() of modified file error = %v, want ErrInvalidSignature", err)
+	}
+}
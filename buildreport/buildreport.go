@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/google/go-github/v65/github"
+	"github.com/microsoft/go-infra/azdo"
 	"github.com/microsoft/go-infra/gitcmd"
 	"github.com/microsoft/go-infra/githubutil"
 	"github.com/microsoft/go-infra/goversion"
@@ -50,11 +51,19 @@ const (
 	endDataMarker   = " DATA -->"
 )
 
-const (
-	reportRetryTimeoutDuration = time.Minute
-	reportRetryDelayDuration   = 3 * time.Second
+// Retry durations for UpdateIssueBody. These may be increased to handle more concurrent updates,
+// for example on a busy release day.
+var (
+	// RetryTimeout is how long to keep retrying a failed update before giving up.
+	RetryTimeout = time.Minute
+	// RetryDelay is how long to wait after a failed update before trying again.
+	RetryDelay = 3 * time.Second
 )
 
+// maxIssueEditAttempts limits how many times UpdateIssueBody edits the issue to catch up with
+// report data that another update pushed while the issue was being edited.
+const maxIssueEditAttempts = 3
+
 const (
 	githubWikiDefaultBranch = "refs/heads/master"
 	localTempBranch         = "refs/heads/buildreport-temp"
@@ -71,16 +80,51 @@ var pipelinesWithRetryInstructions = map[string]struct{}{
 }
 
 // Update updates the report then sends a notification comment if necessary.
-func Update(ctx context.Context, owner, repoName, pat string, issue int, s State) error {
-	if err := UpdateIssueBody(ctx, owner, repoName, pat, issue, s); err != nil {
+func Update(ctx context.Context, owner, repoName, pat string, issue int, s State, backup *Backup) error {
+	if err := UpdateIssueBody(ctx, owner, repoName, pat, issue, s, backup); err != nil {
 		return err
 	}
 	return Notify(ctx, owner, repoName, pat, issue, s)
 }
 
+// Backup configures where to save a copy of the report data file after it is successfully pushed
+// to the wiki. The wiki's Git history is the only record of a release's status history, so a
+// backup in a more durable location protects it if the wiki is lost.
+type Backup struct {
+	// Dir is the directory to write a copy of the data file to. If empty, no backup is made.
+	Dir string
+	// AzDOArtifactName is the name of an AzDO pipeline artifact to upload the copy to. If empty,
+	// the copy is only written to Dir.
+	AzDOArtifactName string
+}
+
+// save writes body to a new file in b.Dir and uploads it if configured. The file name includes
+// the time to keep each backup made by one build. No-op if b is nil or b.Dir is empty.
+func (b *Backup) save(pageName, body string, now time.Time) error {
+	if b == nil || b.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(b.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	path, err := filepath.Abs(filepath.Join(b.Dir, pageName+"_"+now.UTC().Format("2006-01-02_15-04-05")+".md"))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(body), 0o666); err != nil {
+		return err
+	}
+	log.Printf("Saved report data backup to %v\n", path)
+	if b.AzDOArtifactName != "" {
+		azdo.LogCmdUploadArtifact(pageName, b.AzDOArtifactName, path)
+	}
+	return nil
+}
+
 // UpdateIssueBody updates the given issue with new state. Requires the target GitHub repo to have
 // the wiki activated to perform safer concurrent updates than a simple issue description edit.
-func UpdateIssueBody(ctx context.Context, owner, repoName, pat string, issue int, s State) error {
+// After the data is pushed to the wiki, saves a copy according to backup, which may be nil.
+func UpdateIssueBody(ctx context.Context, owner, repoName, pat string, issue int, s State, backup *Backup) error {
 	client, err := githubutil.NewClient(ctx, pat)
 	if err != nil {
 		return err
@@ -108,49 +152,139 @@ func UpdateIssueBody(ctx context.Context, owner, repoName, pat string, issue int
 
 	pageName := fmt.Sprintf("releasego-report-for-issue-%v", issue)
 	dataFilename := fmt.Sprintf("%v.md", pageName)
-	dataPath := filepath.Join(gitDir, dataFilename)
 
 	var body string
 
-	startTime := time.Now()
-	for {
-		elapsed := time.Since(startTime)
-		if elapsed > reportRetryTimeoutDuration {
-			return fmt.Errorf("retry timeout %v expended", reportRetryTimeoutDuration)
+	err = updateWikiFile(gitDir, auther.InsertAuth(url), dataFilename, func(existingBody string, found bool) (string, error) {
+		if !found {
+			log.Printf("No existing %q, fetching issue content for initial commit", dataFilename)
+			githubIssue, _, err := client.Issues.Get(ctx, owner, repoName, issue)
+			if err != nil {
+				return "", err
+			}
+			existingBody = githubIssue.GetBody()
 		}
 
-		// githubutil.Retry is designed to handle infra flakiness and rate limiting. We want this,
-		// but we also want to handle potential concurrency issues. So: use two layers of retry.
-		err := githubutil.Retry(func() error {
-			if err := gitcmd.Run(gitDir, "fetch", "--depth", "1", auther.InsertAuth(url), githubWikiDefaultBranch+":"+localTempBranch, "-f"); err != nil {
-				return err
-			}
-			if err := gitcmd.Run(gitDir, "checkout", "-f", "--detach", localTempBranch); err != nil {
-				return err
-			}
+		rc := parseReportComment(existingBody)
+		rc.update(s)
+
+		// Tweak body generation fields that only apply to the issue body, not notifications.
+		rc.wikiURL = "https://github.com/" + owner + "/" + repoName + "/wiki/" + pageName
+		rc.key = true
 
-			var existingBody string
-			if existingBodyBytes, err := os.ReadFile(dataPath); err != nil {
-				log.Printf("Failed to read %q (%v), fetching issue content for initial commit", dataFilename, err)
-				githubIssue, _, err := client.Issues.Get(ctx, owner, repoName, issue)
+		var err error
+		body, err = rc.body()
+		if err != nil {
+			return "", fmt.Errorf("unable to generate issue body: %v", err)
+		}
+		return body, nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := backup.save(pageName, body, time.Now()); err != nil {
+		return fmt.Errorf("failed to back up report data: %v", err)
+	}
+	// Now that we've successfully pushed, the data is saved, and we know it's the latest
+	// available data. Update the issue.
+	//
+	// There is potential for a conflict with another simultaneous UpdateIssueBody call here: if
+	// there is a delay between "push" and Edit, and another UpdateIssueBody call starts and
+	// finishes during that delay, this Edit will revert the issue to show old data! To catch this,
+	// check the wiki again after the edit and edit again if the data has changed.
+	log.Printf("Copying report to https://github.com/%v/%v/issues/%v description...", owner, repoName, issue)
+	return editIssueUntilCurrent(
+		body,
+		func(body string) error {
+			return githubutil.Retry(func() error {
+				edit, _, err := client.Issues.Edit(ctx, owner, repoName, issue, &github.IssueRequest{Body: &body})
 				if err != nil {
 					return err
 				}
-				existingBody = githubIssue.GetBody()
-			} else {
-				existingBody = string(existingBodyBytes)
-			}
+				log.Printf("Edit successful:\n%v\n", edit.ID)
+				return nil
+			})
+		},
+		func() (string, error) {
+			return latestWikiFile(gitDir, auther.InsertAuth(url), dataFilename)
+		})
+}
 
-			rc := parseReportComment(existingBody)
-			rc.update(s)
+// editIssueUntilCurrent calls edit to set the issue body to body, then calls latest to get the
+// latest report data. If the data has changed, another update may have edited the issue before
+// this one, so this edit reverted the issue to old data. Edits again with the latest data, up to
+// maxIssueEditAttempts times in total.
+//
+// If the data is still changing after the last attempt, or the latest data can't be read, the
+// issue may be out of date, but the data itself is safe in the wiki. Logs a warning and returns
+// nil: the update that changed the data also edits the issue, and the report links to the wiki.
+func editIssueUntilCurrent(body string, edit func(body string) error, latest func() (string, error)) error {
+	for attempt := 1; ; attempt++ {
+		if err := edit(body); err != nil {
+			return err
+		}
+		current, err := latest()
+		if err != nil {
+			log.Printf("Warning: unable to check that the issue shows the latest report data: %v", err)
+			return nil
+		}
+		if current == body {
+			return nil
+		}
+		if attempt >= maxIssueEditAttempts {
+			log.Printf("Warning: report data is still changing after %v issue edits. The issue may be out of date until the next update.", attempt)
+			return nil
+		}
+		log.Printf("Report data changed during the issue edit. Editing again with the latest data...")
+		body = current
+	}
+}
+
+// latestWikiFile fetches the latest commit of the wiki Git repository at url into gitDir and
+// returns the content of dataFilename.
+func latestWikiFile(gitDir, url, dataFilename string) (string, error) {
+	var content string
+	err := githubutil.Retry(func() error {
+		if err := gitcmd.Run(gitDir, "fetch", "--depth", "1", url, githubWikiDefaultBranch+":"+localTempBranch, "-f"); err != nil {
+			return err
+		}
+		var err error
+		content, err = gitcmd.Show(gitDir, localTempBranch+":"+dataFilename)
+		return err
+	})
+	return content, err
+}
 
-			// Tweak body generation fields that only apply to the issue body, not notifications.
-			rc.wikiURL = "https://github.com/" + owner + "/" + repoName + "/wiki/" + pageName
-			rc.key = true
+// updateWikiFile updates the file dataFilename in the wiki Git repository at url, using gitDir as
+// a scratch repository. update is called with the current content of the file, or found=false if
+// the file doesn't exist yet, and returns the new content. The change is committed on top of the
+// latest wiki commit and pushed.
+//
+// Each issue's report has its own file, so concurrent updates for different issues never edit the
+// same data. They only race to push to githubWikiDefaultBranch. If another update pushes first,
+// the push is rejected as a non-fast-forward, and the whole fetch-update-push sequence is retried
+// on top of the new tip, preserving the other update. Only the tip commit is fetched, so a retry
+// is cheap even if the wiki has a long history. Retries continue until RetryTimeout.
+func updateWikiFile(gitDir, url, dataFilename string, update func(existing string, found bool) (string, error)) error {
+	dataPath := filepath.Join(gitDir, dataFilename)
+	// githubutil.Retry is designed to handle infra flakiness and rate limiting. We want this, but we
+	// also want to handle potential concurrency issues. So: use two layers of retry.
+	return retryUntilTimeout(RetryTimeout, RetryDelay, func() error {
+		return githubutil.Retry(func() error {
+			if err := gitcmd.Run(gitDir, "fetch", "--depth", "1", url, githubWikiDefaultBranch+":"+localTempBranch, "-f"); err != nil {
+				return err
+			}
+			if err := gitcmd.Run(gitDir, "checkout", "-f", "--detach", localTempBranch); err != nil {
+				return err
+			}
 
-			body, err = rc.body()
+			existing, err := os.ReadFile(dataPath)
 			if err != nil {
-				return fmt.Errorf("unable to generate issue body: %v", err)
+				log.Printf("Failed to read %q: %v", dataFilename, err)
+			}
+			body, err := update(string(existing), err == nil)
+			if err != nil {
+				return err
 			}
 
 			if err := os.WriteFile(dataPath, []byte(body), 0o666); err != nil {
@@ -162,9 +296,21 @@ func UpdateIssueBody(ctx context.Context, owner, repoName, pat string, issue int
 			if err := gitcmd.Run(gitDir, "commit", "-m", "Update "+dataFilename); err != nil {
 				return err
 			}
-			return gitcmd.Run(gitDir, "push", auther.InsertAuth(url), "HEAD:"+githubWikiDefaultBranch)
+			return gitcmd.Run(gitDir, "push", url, "HEAD:"+githubWikiDefaultBranch)
 		})
-		if err != nil {
+	})
+}
+
+// retryUntilTimeout calls f until it succeeds, waiting delay after each failure. Returns an error
+// if f is still failing after timeout has elapsed.
+func retryUntilTimeout(timeout, delay time.Duration, f func() error) error {
+	startTime := time.Now()
+	for {
+		elapsed := time.Since(startTime)
+		if elapsed > timeout {
+			return fmt.Errorf("retry timeout %v expended", timeout)
+		}
+		if err := f(); err != nil {
 			// Inner retry wasn't able to get the update done. This may be due to concurrency: N
 			// builds trying to update the issue at the same time. Try again after a short delay.
 			// (Nothing fancy: even in the worst case of N updates happening simultaneously,
@@ -172,34 +318,14 @@ func UpdateIssueBody(ctx context.Context, owner, repoName, pat string, issue int
 			// update out of N succeeds each time.)
 			log.Printf(
 				"Inner GitHub retry loop failed. Waiting %v then trying again. Will give up after %v. Error: %v\n",
-				reportRetryDelayDuration,
-				reportRetryDelayDuration-elapsed,
+				delay,
+				timeout-elapsed,
 				err)
-			time.Sleep(reportRetryDelayDuration)
+			time.Sleep(delay)
 			continue
 		}
-		break // Success.
-	}
-	// Now that we've successfully pushed, the data is saved, and we know it's the latest
-	// available data. Update the issue.
-	//
-	// There is potential for a conflict with another simultaneous UpdateIssueBody call here: if
-	// there is a delay between "push" and Edit, and another UpdateIssueBody call starts and
-	// finishes during that delay, this Edit will revert the issue to show old data!
-	//
-	// Mitigations: there isn't any known reason we'd have a long delay right here. The data
-	// itself is safe: the next UpdateIssueBody call will fix the issue and post the correct
-	// data. The report includes a link to the actual data in case it's important to get the
-	// real data during a release.
-	log.Printf("Copying report to https://github.com/%v/%v/issues/%v description...", owner, repoName, issue)
-	return githubutil.Retry(func() error {
-		edit, _, err := client.Issues.Edit(ctx, owner, repoName, issue, &github.IssueRequest{Body: &body})
-		if err != nil {
-			return err
-		}
-		log.Printf("Edit successful:\n%v\n", edit.ID)
 		return nil
-	})
+	}
 }
 
 // Notify determines if a notification is necessary for the given status update and sends it.
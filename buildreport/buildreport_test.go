@@ -1,10 +1,17 @@
 package buildreport
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/microsoft/go-infra/gitcmd"
 	"github.com/microsoft/go-infra/goldentest"
 )
 
@@ -163,3 +170,221 @@ func Test_State_notificationPreamble(t *testing.T) {
 		})
 	}
 }
+
+func TestBackup_save(t *testing.T) {
+	now := time.Date(2022, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	var nilBackup *Backup
+	if err := nilBackup.save("page", "body", now); err != nil {
+		t.Errorf("nil backup: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backup")
+	b := &Backup{Dir: dir}
+	if err := b.save("page", "body", now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "page_2022-05-06_07-08-09.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "body" {
+		t.Errorf("backup content = %q, want %q", got, "body")
+	}
+}
+
+func Test_retryUntilTimeout(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		var calls int
+		err := retryUntilTimeout(20*time.Millisecond, 5*time.Millisecond, func() error {
+			calls++
+			return errors.New("concurrent update")
+		})
+		if err == nil || !strings.Contains(err.Error(), "retry timeout") || !strings.Contains(err.Error(), "expended") {
+			t.Errorf("retryUntilTimeout() error = %v, want retry timeout expended error", err)
+		}
+		if calls < 2 {
+			t.Errorf("retryUntilTimeout() called f %v times, want at least 2", calls)
+		}
+	})
+	t.Run("eventual success", func(t *testing.T) {
+		var calls int
+		err := retryUntilTimeout(time.Minute, time.Millisecond, func() error {
+			calls++
+			if calls < 3 {
+				return errors.New("concurrent update")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("retryUntilTimeout() unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("retryUntilTimeout() called f %v times, want 3", calls)
+		}
+	})
+}
+
+func Test_updateWikiFile_Concurrent(t *testing.T) {
+	// Retry quickly: the updates are expected to collide.
+	oldTimeout, oldDelay := RetryTimeout, RetryDelay
+	RetryTimeout, RetryDelay = time.Minute, 10*time.Millisecond
+	t.Cleanup(func() { RetryTimeout, RetryDelay = oldTimeout, oldDelay })
+
+	// Set up a wiki repo with an initial commit.
+	d := t.TempDir()
+	wiki := filepath.Join(d, "wiki.git")
+	if err := gitcmd.Run(d, "init", "-q", "--bare", wiki); err != nil {
+		t.Fatal(err)
+	}
+	initial, err := gitcmd.NewTempGitRepo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gitcmd.AttemptDelete(initial)
+	if err := gitcmd.Run(initial, "commit", "-q", "--allow-empty", "-m", "Initial"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitcmd.Run(initial, "push", "-q", wiki, "HEAD:"+githubWikiDefaultBranch); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update two issues' files at the same time, several times each. Every update must land in
+	// its own file without being lost or affecting the other file.
+	const updates = 3
+	files := []string{"issue-1.md", "issue-2.md"}
+	var wg sync.WaitGroup
+	errs := make([]error, len(files))
+	for i, file := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gitDir, err := gitcmd.NewTempGitRepo()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer gitcmd.AttemptDelete(gitDir)
+			for u := 0; u < updates; u++ {
+				err := updateWikiFile(gitDir, wiki, file, func(existing string, found bool) (string, error) {
+					if found != (u > 0) {
+						return "", fmt.Errorf("update %v: found = %v", u, found)
+					}
+					return existing + fmt.Sprintf("%v update %v\n", file, u), nil
+				})
+				if err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		got, err := gitcmd.Show(wiki, githubWikiDefaultBranch+":"+file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want strings.Builder
+		for u := 0; u < updates; u++ {
+			fmt.Fprintf(&want, "%v update %v\n", file, u)
+		}
+		if got != want.String() {
+			t.Errorf("%v content = %q, want %q", file, got, want.String())
+		}
+	}
+}
+
+func Test_editIssueUntilCurrent(t *testing.T) {
+	t.Run("concurrent update", func(t *testing.T) {
+		// Set up a wiki repo with our pushed data.
+		d := t.TempDir()
+		wiki := filepath.Join(d, "wiki.git")
+		if err := gitcmd.Run(d, "init", "-q", "--bare", wiki); err != nil {
+			t.Fatal(err)
+		}
+		newWikiClone := func() string {
+			gitDir, err := gitcmd.NewTempGitRepo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { gitcmd.AttemptDelete(gitDir) })
+			return gitDir
+		}
+		initial := newWikiClone()
+		if err := os.WriteFile(filepath.Join(initial, "issue.md"), []byte("ours"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if err := gitcmd.Run(initial, "add", "--", "issue.md"); err != nil {
+			t.Fatal(err)
+		}
+		if err := gitcmd.Run(initial, "commit", "-q", "-m", "Initial"); err != nil {
+			t.Fatal(err)
+		}
+		if err := gitcmd.Run(initial, "push", "-q", wiki, "HEAD:"+githubWikiDefaultBranch); err != nil {
+			t.Fatal(err)
+		}
+
+		// Simulate another update that pushes and edits the issue between our push and our edit.
+		// Our edit lands last, so it reverts the issue to our old data.
+		var issue []string
+		edit := func(body string) error {
+			if len(issue) == 0 {
+				err := updateWikiFile(newWikiClone(), wiki, "issue.md", func(string, bool) (string, error) {
+					return "theirs", nil
+				})
+				if err != nil {
+					return err
+				}
+				issue = append(issue, "theirs")
+			}
+			issue = append(issue, body)
+			return nil
+		}
+		gitDir := newWikiClone()
+		latest := func() (string, error) {
+			return latestWikiFile(gitDir, wiki, "issue.md")
+		}
+		if err := editIssueUntilCurrent("ours", edit, latest); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"theirs", "ours", "theirs"}; !reflect.DeepEqual(issue, want) {
+			t.Errorf("issue edits = %q, want %q", issue, want)
+		}
+	})
+	t.Run("bounded", func(t *testing.T) {
+		var edits, reads int
+		err := editIssueUntilCurrent(
+			"body",
+			func(string) error {
+				edits++
+				return nil
+			},
+			func() (string, error) {
+				reads++
+				return fmt.Sprintf("newer %v", reads), nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edits != maxIssueEditAttempts {
+			t.Errorf("edits = %v, want %v", edits, maxIssueEditAttempts)
+		}
+	})
+	t.Run("edit error", func(t *testing.T) {
+		err := editIssueUntilCurrent(
+			"body",
+			func(string) error { return errors.New("edit failed") },
+			func() (string, error) {
+				t.Fatal("latest called after a failed edit")
+				return "", nil
+			})
+		if err == nil {
+			t.Error("editIssueUntilCurrent() succeeded, want edit error")
+		}
+	})
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -23,11 +24,54 @@ assets.json file that has been downloaded to the local machine:
 This command is useful to update the Dockerfile contents e.g. when adding Dockerfiles for a new
 branch or changing the Dockerfile templates. The 'dockerupdatepr' command could be used to do this,
 but it has dev cycle overhead that is good to avoid.
+
+Example: In CI, check that the checked-in Dockerfiles are up to date with the templates and the
+versions.json file. Exits with code 2 and lists the changed files if they aren't:
+
+  go run ./cmd/dockerupdate -d ~/git/go-images -check
+
+Use -diff-dockerfiles instead of -check to print a unified diff of each Dockerfile that
+regeneration would change. It generates the Dockerfiles in a scratch copy of the repository, so it
+doesn't modify any files.
+
+Example: Check that manifest.json is well-formed, has no duplicate tags, and only refers to
+Dockerfiles that exist. Exits with code 2 and lists the problems if not:
+
+  go run ./cmd/dockerupdate -d ~/git/go-images -validate-manifest
+
+Example: Before submitting an update, list the versions.json channels and manifest.json tags it
+would add, remove, or change, without modifying any files:
+
+  go run ./cmd/dockerupdate -d ~/git/go-images -build-asset-json ~/downloads/assets.json -preview
+
+Use -diff instead of -preview to print a unified diff of the versions.json and manifest.json
+changes.
+
+Example: For a respin, set the Microsoft revision of the 1.25 channel to 3 without changing its
+upstream Go version, then regenerate manifest.json and the Dockerfiles:
+
+  go run ./cmd/dockerupdate -d ~/git/go-images -bump-revision 1.25 -revision 3
 `
 
 func main() {
 	f := buildmodel.BindUpdateFlags()
 	d := flag.String("d", "", "The directory containing the Go Docker repository to update. If empty, uses the current directory.")
+	check := flag.Bool("check", false,
+		"Don't update versions.json or manifest.json, just regenerate Dockerfiles and check that Git sees no changes.\n"+
+			"Exit code 2 if the Dockerfiles changed.")
+	diffDockerfiles := flag.Bool("diff-dockerfiles", false,
+		"Don't update anything, just regenerate Dockerfiles in a scratch copy of the repository and print a unified diff of each file that changed.")
+	validateManifest := flag.Bool("validate-manifest", false,
+		"Don't update anything, just check that manifest.json is valid.\n"+
+			"Exit code 2 if it isn't.")
+	preview := flag.Bool("preview", false,
+		"Don't update anything, just list the versions.json channels and manifest.json tags the update would change.")
+	bumpRevision := flag.String("bump-revision", "",
+		"Don't apply build assets, just change the Microsoft revision of this versions.json channel, such as '1.25'.\n"+
+			"Keeps the upstream version. See -revision.")
+	revision := flag.String("revision", "", "With -bump-revision, the revision to set. If not set, increments the current revision.")
+	diff := flag.Bool("diff", false,
+		"Don't update anything, just print a unified diff of the changes the update would make to versions.json and manifest.json.")
 
 	buildmodel.ParseBoundFlags(description)
 
@@ -39,6 +83,57 @@ func main() {
 		d = &w
 	}
 
+	if *validateManifest {
+		if err := buildmodel.ValidateManifestFile(*d); err != nil {
+			if errors.Is(err, buildmodel.ErrInvalidManifest) {
+				fmt.Printf("Check failed: %v\n", err)
+				os.Exit(2)
+			}
+			panic(err)
+		}
+		return
+	}
+
+	if *preview {
+		if err := buildmodel.RunPreview(*d, f); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *bumpRevision != "" {
+		if err := buildmodel.RunBumpRevision(*d, f, *bumpRevision, *revision); err != nil {
+			panic(err)
+		}
+		fmt.Println("\nSuccess.")
+		return
+	}
+
+	if *diff {
+		if err := buildmodel.RunDiff(*d, f); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *diffDockerfiles {
+		if err := buildmodel.RunDockerfileDiff(*d, f); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *check {
+		if err := buildmodel.RunCheck(*d, f); err != nil {
+			if errors.Is(err, buildmodel.ErrDockerfilesOutOfDate) {
+				fmt.Printf("Check failed: %v\n", err)
+				os.Exit(2)
+			}
+			panic(err)
+		}
+		return
+	}
+
 	if err := buildmodel.RunUpdate(*d, f); err != nil {
 		panic(err)
 	}
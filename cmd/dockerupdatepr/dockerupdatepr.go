@@ -4,6 +4,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 
@@ -20,6 +21,14 @@ Example dry run that prepares the update locally:
 
 The "-n" is the dry run arg. Removing that arg makes the command submit the change as a GitHub PR.
 
+To check which Go Docker image repository branch the PR would target, and why, without doing any
+Git work:
+
+  go run ./cmd/dockerupdatepr -build-asset-json /home/me/downloads/assets.json -show-target-branch
+
+For a multi-version release, pass every build asset JSON file separated by ',' to update all the
+versions in a single PR. The assets must all belong to the same Docker image repository branch.
+
 This command creates a temporary copy of the Go Docker repository in 'eng/artifacts/' by default.
 
 To run this command locally, it may be useful to specify Git addresses like
@@ -28,9 +37,19 @@ To run this command locally, it may be useful to specify Git addresses like
 
 func main() {
 	f := buildmodel.BindPRFlags()
+	showTargetBranch := flag.Bool("show-target-branch", false,
+		"Print the branch the PR would target and the rule that selected it, then exit without doing any Git work.\n"+
+			"Reflects -manual-branch if set.")
 
 	buildmodel.ParseBoundFlags(description)
 
+	if *showTargetBranch {
+		if err := buildmodel.ShowTargetBranch(f); err != nil {
+			log.Panic(err)
+		}
+		return
+	}
+
 	if err := buildmodel.SubmitUpdatePR(f); err != nil {
 		log.Panic(err)
 	}
@@ -8,6 +8,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path"
@@ -41,7 +42,9 @@ set of targets and added to the bucket with the lowest sum of weights until the
 empty. If -run and -bucket are both specified, -run is applied first.
 
 The targets are specified in 'targets.go'. The order of targets in that file is respected by the
--run and -bucket behavior.`
+-run and -bucket behavior. Pass -shuffle to run the selected targets in a random order instead. The
+time split among targets is the same either way, and the seed is printed so the order can be
+reproduced by passing it back, for example -shuffle 1700000000.`
 
 const helpFuzztime = `Run enough iterations of all the fuzz targets during fuzzing to take t,
 specified as a time.Duration (for example, -fuzztime 1h30s).
@@ -53,6 +56,17 @@ const helpBucket = "The 1-indexed bucket B out of total buckets N, passed as a s
 
 const helpRun = `Run only those fuzz targets matching the regular expression.`
 
+const helpCPU = `Limit each fuzz target to N CPUs by passing -parallel N to 'go test' and setting
+GOMAXPROCS=N for it. The default, 0, leaves both unset.
+	Targets run sequentially, so N is also the overall limit. If targets are
+ever run in parallel, the total CPU usage is N times the number of targets
+running at once.`
+
+const helpShuffle = `Randomize the execution order of the selected targets. Set to 'off' (default),
+'on' to use a seed based on the current time, or an integer seed.
+	Filtering and bucketing happen before shuffling, so the set of targets
+in each bucket doesn't depend on the seed.`
+
 const defaultFuzzTime = 5 * time.Minute
 
 func main() {
@@ -61,12 +75,17 @@ func main() {
 	flag.Var(&fuzzDuration, "fuzztime", helpFuzztime)
 	run := flagRegex("run", helpRun)
 	bucket, bucketCount := flagBucket("bucket", helpBucket)
+	cpu := flag.Int("cpu", 0, helpCPU)
+	shuffle := flagShuffle("shuffle", helpShuffle)
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUsage:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(flag.CommandLine.Output(), "%s\n\n", description)
 	}
 	flag.Parse()
+	if *cpu < 0 {
+		log.Fatalf("-cpu must not be negative, got %v", *cpu)
+	}
 	if fuzzDuration.d == 0 && fuzzDuration.n == 0 {
 		fuzzDuration.d = defaultFuzzTime
 	}
@@ -79,6 +98,12 @@ func main() {
 		}
 	}
 
+	if *shuffle != nil {
+		seed := **shuffle
+		log.Printf("Shuffling targets with seed %d\n", seed)
+		targets = shuffleTargets(targets, seed)
+	}
+
 	log.Printf("Running targets: %v\n", targetNames(targets))
 
 	var sumweights float64
@@ -96,7 +121,7 @@ func main() {
 		}
 		log.Printf("Running fuzz target %s for %v. %d/%d completed\n", t.name, targetDuration, i, len(targets))
 
-		err := fuzz(t.name, targetDuration, *verbose)
+		err := fuzz(t.name, targetDuration, *cpu, *verbose)
 		if err != nil {
 			if _, ok := err.(*exec.ExitError); !ok {
 				errs = append(errs, fmt.Sprintf("fuzz target %q can't be executed: %v", t.name, err))
@@ -148,15 +173,43 @@ func flagBucket(name, usage string) (bucket, bucketCount *int) {
 	return &b, &c
 }
 
+// flagShuffle defines a flag that is "off", "on", or an integer seed. The returned pointer points to
+// nil if shuffling is off, otherwise to the seed to use.
+func flagShuffle(name, usage string) **int64 {
+	var seed *int64
+	flag.Func(name, usage, func(s string) error {
+		switch s {
+		case "off":
+			seed = nil
+		case "on":
+			n := time.Now().UnixNano()
+			seed = &n
+		default:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("must be 'off', 'on', or an integer seed: %v", err)
+			}
+			seed = &n
+		}
+		return nil
+	})
+	return &seed
+}
+
 // fuzz executes the named fuzz test.
+// If cpu is greater than 0, the test is limited to that many CPUs.
 // It only returns an error if the test binary could not be executed.
-func fuzz(name string, d durationOrCountFlag, verbose bool) error {
+func fuzz(name string, d durationOrCountFlag, cpu int, verbose bool) error {
 	dir, fuzzname := path.Split(name)
 	cmd := exec.Command("go", "test",
 		"-run", "-", // don't run any normal test
 		"-fuzztime", d.String(),
 		"-fuzz", "^"+fuzzname+"$", // ensure we are strictly matching name
 	)
+	if cpu > 0 {
+		cmd.Args = append(cmd.Args, "-parallel", strconv.Itoa(cpu))
+		cmd.Env = append(os.Environ(), "GOMAXPROCS="+strconv.Itoa(cpu))
+	}
 	cmd.Dir = filepath.Join(".", dir)
 	if verbose {
 		cmd.Stdout = os.Stdout
@@ -222,6 +275,18 @@ func bucketTargets(all []target, bucket, bucketCount int) []target {
 	return targets
 }
 
+// shuffleTargets returns a copy of targets in a random order determined by seed. The same seed and
+// input order always produce the same result.
+func shuffleTargets(all []target, seed int64) []target {
+	// Copy all slice so we can shuffle it without affecting the original order.
+	targets := append([]target(nil), all...)
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+	return targets
+}
+
 // targetNames returns each target's name in a slice. Useful for debug/print purposes.
 func targetNames(targets []target) []string {
 	var targetNames []string
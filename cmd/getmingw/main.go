@@ -13,6 +13,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -44,6 +45,9 @@ const (
 	winlibsPrefix = "https://github.com/brechtsanders/winlibs_mingw/releases/download/"
 )
 
+// maxErrorOutputBytes is the most output of a failed command to include in an error message.
+const maxErrorOutputBytes = 64 * 1024
+
 var subcommands []subcmd.Option
 
 func main() {
@@ -73,6 +77,22 @@ func initFilterFlags() {
 	flag.Var(&llvms, "llvm", "llvm build present: llvm, no")
 }
 
+// mirrorBase is a URL prefix that replaces the scheme and host of each build's URL when
+// downloading, or empty string to download from the original URL.
+var mirrorBase string
+
+// mirrorEnvVar is the environment variable used as the default value of the -mirror-base flag.
+const mirrorEnvVar = "GETMINGW_MIRROR"
+
+func initMirrorFlag() {
+	flag.StringVar(
+		&mirrorBase,
+		"mirror-base", os.Getenv(mirrorEnvVar),
+		"Download from this URL prefix instead of the original host, preserving the URL path.\n"+
+			"For example, 'https://mirror.example.org/mingw'. The SHA512 checksum is still verified.\n"+
+			"Defaults to the value of the "+mirrorEnvVar+" environment variable.")
+}
+
 func unmarshal() (r map[string]build, err error) {
 	err = json.Unmarshal(data, &r)
 	if err != nil {
@@ -161,6 +181,9 @@ type build struct {
 	URL string
 	// SHA512 is the checksum of the download. Not included in filtering.
 	SHA512 string
+
+	// verified is true if the cache has been checked by -verify-cache during this run.
+	verified bool
 }
 
 func (b *build) CreateFreshChecksum() error {
@@ -183,6 +206,23 @@ func (b *build) CreateFreshChecksum() error {
 	return nil
 }
 
+// DownloadURL returns the URL to download the build from. This is URL, or if a mirror is
+// configured, URL with the scheme and host replaced by the mirror prefix.
+func (b *build) DownloadURL() (string, error) {
+	if mirrorBase == "" {
+		return b.URL, nil
+	}
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL %#q to apply mirror: %v", b.URL, err)
+	}
+	mirrored := strings.TrimSuffix(mirrorBase, "/") + u.EscapedPath()
+	if u.RawQuery != "" {
+		mirrored += "?" + u.RawQuery
+	}
+	return mirrored, nil
+}
+
 func (b *build) GetOrCreateCacheBinDir() (string, error) {
 	mingwCacheDir, err := cacheDir()
 	if err != nil {
@@ -207,6 +247,21 @@ func (b *build) GetOrCreateCacheBinDir() (string, error) {
 		return "", fmt.Errorf("unknown arch %#q", b.Arch)
 	}
 
+	cache := &buildCache{
+		downloadFile:        downloadFile,
+		downloadedIndicator: downloadedIndicator,
+		extractDir:          extractDir,
+		extractedBinDir:     extractedBinDir,
+		extractedIndicator:  extractedIndicator,
+		extractedSums:       filepath.Join(buildDir, ".extracted-sums"),
+	}
+	if verifyCache && !b.verified {
+		if err := cache.verify(b.SHA512); err != nil {
+			return "", err
+		}
+		b.verified = true
+	}
+
 	if cachedHash, err := os.ReadFile(downloadedIndicator); err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			return "", fmt.Errorf("unexpected error while reading %#q: %v", downloadedIndicator, err)
@@ -214,10 +269,14 @@ func (b *build) GetOrCreateCacheBinDir() (string, error) {
 		// Best effort to delete old downloaded file, in case it failed in a weird way.
 		_ = os.Remove(downloadFile)
 
-		log.Printf("Downloading %v...", b.URL)
+		downloadURL, err := b.DownloadURL()
+		if err != nil {
+			return "", err
+		}
+		log.Printf("Downloading %v...", downloadURL)
 		// Download the URL and compute the SHA512:
 		var client http.Client
-		resp, err := client.Get(b.URL)
+		resp, err := client.Get(downloadURL)
 		if err != nil {
 			return "", err
 		}
@@ -265,10 +324,13 @@ func (b *build) GetOrCreateCacheBinDir() (string, error) {
 		// If the user cancels, or one 7z processes of many fails, make sure
 		// all others are canceled. Otherwise, they may keep running in the
 		// background.
-		if out, err := executil.CombinedOutput(cmd); err != nil {
+		if out, _, err := executil.LimitedCombinedOutput(cmd, maxErrorOutputBytes); err != nil {
 			return "", fmt.Errorf("failed to extract: %v, output: %v", err, out)
 		}
-		// Write the extraction complete indicator:
+		// Record key binary sums for -verify-cache, then write the extraction complete indicator:
+		if err := cache.writeExtractedSums(); err != nil {
+			return "", err
+		}
 		if err := os.WriteFile(extractedIndicator, []byte(b.SHA512), 0o666); err != nil {
 			return "", err
 		}
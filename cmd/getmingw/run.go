@@ -28,6 +28,8 @@ func init() {
 
 func run(p subcmd.ParseFunc) error {
 	initFilterFlags()
+	initMirrorFlag()
+	initVerifyCacheFlag()
 	multi := flag.Bool("multi", false, "Run the command once per matching MinGW version rather than only match one version.")
 	ciType := flag.String("ci", "", "In addition to the command, prepend to PATH in a CI-specific way. 'github-actions-env', 'azdo', or none.")
 	if err := p(); err != nil {
@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/microsoft/go-infra/subcmd"
+	"golang.org/x/sync/errgroup"
+)
+
+func init() {
+	subcommands = append(subcommands, subcmd.Option{
+		Name:    "update-checksums",
+		Summary: "Compute missing SHA512 checksums in the embedded JSON data and write out the new version.",
+		Description: `
+
+Downloads each build that doesn't have a SHA512 checksum, or every build if -force is specified, and
+computes its checksum. Downloads run in parallel. Builds that return 404 are reported and keep their
+existing checksum.
+`,
+		Handle: updateChecksums,
+	})
+}
+
+func updateChecksums(p subcmd.ParseFunc) error {
+	out := flag.String("out", "", "Write the updated JSON to this file instead of stdout.")
+	force := flag.Bool("force", false, "Recompute the checksum of every build, not only those missing one.")
+	parallel := flag.Int("parallel", 4, "The maximum number of builds to download at the same time.")
+	if err := p(); err != nil {
+		return err
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("parallel must be at least 1, got %v", *parallel)
+	}
+	builds, err := unmarshal()
+	if err != nil {
+		return err
+	}
+
+	var pending []*build
+	for _, b := range builds {
+		if *force || b.SHA512 == "" {
+			b := b
+			pending = append(pending, &b)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].URL < pending[j].URL
+	})
+	log.Printf("Creating checksums for %v builds", len(pending))
+
+	// notFound is indexed the same as pending, so each goroutine only writes its own element.
+	notFound := make([]bool, len(pending))
+	var eg errgroup.Group
+	eg.SetLimit(*parallel)
+	for i, b := range pending {
+		eg.Go(func() error {
+			log.Printf("Creating checksum for %v", b.URL)
+			if err := b.CreateFreshChecksum(); err != nil {
+				if errors.Is(err, errBuildNotFound) {
+					notFound[i] = true
+					return nil
+				}
+				return fmt.Errorf("failed to create checksum for %v: %w", b.URL, err)
+			}
+			log.Printf("Downloaded %v, generated checksum %v...", b.URL, b.SHA512[:16])
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	for i, b := range pending {
+		if notFound[i] {
+			log.Printf("Build not found (404), keeping existing checksum %q: %v", b.SHA512, b.URL)
+			continue
+		}
+		builds[b.URL] = *b
+	}
+
+	result, err := marshal(builds)
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		return os.WriteFile(*out, result, 0o666)
+	}
+	fmt.Println(string(result))
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// verifyCache enables re-verification of a cached build each time it's used.
+var verifyCache bool
+
+func initVerifyCacheFlag() {
+	flag.BoolVar(
+		&verifyCache,
+		"verify-cache", false,
+		"Before using a cached MinGW build, re-hash the downloaded archive and spot-check key extracted binaries.\n"+
+			"If verification fails, the build is purged from the cache and downloaded or extracted again.")
+}
+
+// keyBinaries are the files in the extracted bin dir that are hashed after extraction and checked
+// by -verify-cache. A toolchain missing one of these is broken, but not every build includes all
+// of them, so only those present after extraction are recorded.
+var keyBinaries = []string{"gcc.exe", "g++.exe", "as.exe", "ld.exe"}
+
+// buildCache is the set of paths used to cache a single build.
+type buildCache struct {
+	downloadFile        string
+	downloadedIndicator string
+	extractDir          string
+	extractedBinDir     string
+	extractedIndicator  string
+	// extractedSums is a JSON file mapping each key binary in extractedBinDir to its SHA512.
+	extractedSums string
+}
+
+// verify checks the cached files against the expected SHA512 of the archive and the key binary
+// sums recorded at extraction time. If the archive is corrupt, the whole cache entry is purged. If
+// only the extracted files are corrupt, the extraction is purged. Either way, the caller then
+// recreates what's missing as if it had never been cached.
+func (c *buildCache) verify(sha512Sum string) error {
+	if _, err := os.Stat(c.downloadedIndicator); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Nothing cached yet.
+			return nil
+		}
+		return err
+	}
+	if sum, err := fileSHA512(c.downloadFile); err != nil || sum != sha512Sum {
+		log.Printf("Cached download %#q failed verification, purging: sum %v, err %v", c.downloadFile, sum, err)
+		return c.purge(c.downloadedIndicator, c.downloadFile, c.extractedIndicator, c.extractedSums, c.extractDir)
+	}
+
+	if _, err := os.Stat(c.extractedIndicator); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := c.checkExtractedSums(); err != nil {
+		log.Printf("Cached extraction %#q failed verification, purging: %v", c.extractDir, err)
+		return c.purge(c.extractedIndicator, c.extractedSums, c.extractDir)
+	}
+	return nil
+}
+
+// writeExtractedSums records the SHA512 of each key binary present in the extracted bin dir.
+func (c *buildCache) writeExtractedSums() error {
+	sums := make(map[string]string, len(keyBinaries))
+	for _, name := range keyBinaries {
+		sum, err := fileSHA512(filepath.Join(c.extractedBinDir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		sums[name] = sum
+	}
+	if len(sums) == 0 {
+		return fmt.Errorf("none of the key binaries %v found in %#q", keyBinaries, c.extractedBinDir)
+	}
+	content, err := json.Marshal(sums)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.extractedSums, content, 0o666)
+}
+
+// checkExtractedSums returns an error if a key binary recorded by writeExtractedSums is missing or
+// has changed. An extraction made before sums were recorded fails the check, so it is redone once.
+func (c *buildCache) checkExtractedSums() error {
+	content, err := os.ReadFile(c.extractedSums)
+	if err != nil {
+		return fmt.Errorf("failed to read key binary sums: %v", err)
+	}
+	var sums map[string]string
+	if err := json.Unmarshal(content, &sums); err != nil {
+		return fmt.Errorf("failed to parse key binary sums: %v", err)
+	}
+	if len(sums) == 0 {
+		return errors.New("no key binary sums recorded")
+	}
+	for name, want := range sums {
+		sum, err := fileSHA512(filepath.Join(c.extractedBinDir, name))
+		if err != nil {
+			return err
+		}
+		if sum != want {
+			return fmt.Errorf("SHA512 mismatch for %v.\n  Expected: %v\n  Found: %v", name, want, sum)
+		}
+	}
+	return nil
+}
+
+// purge removes the given cache files and directories. Indicators should be listed first, so an
+// interrupted purge never leaves an indicator pointing at missing files.
+func (c *buildCache) purge(paths ...string) error {
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("failed to purge %#q: %v", p, err)
+		}
+	}
+	return nil
+}
+
+func fileSHA512(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha512.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
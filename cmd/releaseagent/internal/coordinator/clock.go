@@ -0,0 +1,152 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package coordinator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time used by StepRunner to enforce step timeouts. Tests can use a
+// FakeClock to control the passage of time and exercise timeout handling deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is a Clock that uses the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time                         { return time.Now() }
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// withTimeout returns a context that expires after d according to the given clock. For RealClock,
+// this is simply context.WithTimeout.
+func withTimeout(ctx context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(RealClock); ok {
+		return context.WithTimeout(ctx, d)
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	c := &clockTimeoutCtx{
+		Context:  cancelCtx,
+		deadline: clock.Now().Add(d),
+	}
+	expired := clock.After(d)
+	go func() {
+		select {
+		case <-expired:
+			c.expired.Store(true)
+			cancel()
+		case <-cancelCtx.Done():
+		}
+	}()
+	return c, cancel
+}
+
+// clockTimeoutCtx is a context that is canceled when its Clock reaches the deadline. It reports
+// context.DeadlineExceeded in that case, like a context created by context.WithTimeout.
+type clockTimeoutCtx struct {
+	context.Context
+	deadline time.Time
+	expired  atomic.Bool
+}
+
+func (c *clockTimeoutCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockTimeoutCtx) Err() error {
+	if c.expired.Load() {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
+
+// FakeClock is a Clock that only moves forward when Advance is called.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+	// changed is closed and replaced whenever the set of waiters changes.
+	changed chan struct{}
+}
+
+type fakeClockWaiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+// NewFakeClock creates a FakeClock that starts at the given time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{
+		now:     start,
+		changed: make(chan struct{}),
+	}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan time.Time, 1)
+	until := f.now.Add(d)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.waiters = append(f.waiters, fakeClockWaiter{until, c})
+	f.notify()
+	return c
+}
+
+// Advance moves the clock forward by d, firing every timer that expires on or before the new time.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	remaining := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.until.After(f.now) {
+			remaining = append(remaining, w)
+			continue
+		}
+		w.c <- f.now
+	}
+	f.waiters = remaining
+	f.notify()
+}
+
+// BlockUntil waits until at least n timers are waiting for the clock to advance, or ctx is done.
+// Tests use this to make sure a step has started waiting on the clock before calling Advance.
+func (f *FakeClock) BlockUntil(ctx context.Context, n int) error {
+	for {
+		f.mu.Lock()
+		waiting := len(f.waiters)
+		changed := f.changed
+		f.mu.Unlock()
+		if waiting >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up BlockUntil callers. Must be called with f.mu held.
+func (f *FakeClock) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
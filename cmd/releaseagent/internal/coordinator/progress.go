@@ -0,0 +1,39 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package coordinator
+
+import (
+	"context"
+)
+
+// progressKey is the context key for the progressFunc of the running step.
+type progressKey struct{}
+
+type progressFunc func(message string)
+
+// ReportProgress records a progress message for the step that ctx was passed to, such as "still
+// waiting, 10 minutes elapsed". This lets a long-running step show that it's still alive before it
+// completes. The most recent message is included in the step's Snapshot and passed to
+// StepRunner.OnProgress.
+//
+// Does nothing if ctx doesn't belong to a step run by a StepRunner, so step implementations can
+// call it unconditionally.
+func ReportProgress(ctx context.Context, message string) {
+	if f, ok := ctx.Value(progressKey{}).(progressFunc); ok {
+		f(message)
+	}
+}
+
+// withProgress returns a context that records progress reported by the step of s.
+func (s *stepState) withProgress(ctx context.Context, clock Clock, onProgress func(step *Step, message string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, progressFunc(func(message string) {
+		s.mu.Lock()
+		s.progress = message
+		s.progressTime = clock.Now()
+		s.mu.Unlock()
+		if onProgress != nil {
+			onProgress(s.step, message)
+		}
+	}))
+}
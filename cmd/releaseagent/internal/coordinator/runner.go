@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -21,9 +23,37 @@ const (
 	StepStatusFailed
 )
 
+func (s StepStatus) String() string {
+	switch s {
+	case StepStatusWaiting:
+		return "waiting"
+	case StepStatusRunning:
+		return "running"
+	case StepStatusSucceeded:
+		return "succeeded"
+	case StepStatusFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("StepStatus(%d)", int(s))
+}
+
+// MarshalText encodes the status as its name, so it's readable in a JSON snapshot.
+func (s StepStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
 var stepPanicErr = errors.New("panic while executing step")
 
 type StepRunner struct {
+	// Clock is used to enforce step timeouts. If nil, RealClock is used.
+	Clock Clock
+	// OnProgress, if not nil, is called each time a step calls ReportProgress. It is called from
+	// the step's goroutine, so it must be safe to call concurrently and should return quickly.
+	OnProgress func(step *Step, message string)
+
+	// mu protects steps and states, so Snapshot can be called while steps are running.
+	mu     sync.Mutex
+	steps  []*Step
 	states map[*Step]*stepState
 }
 
@@ -32,15 +62,16 @@ type StepRunner struct {
 // If any step fails, returns the first error that occurred. If a step panics, it is recovered,
 // wrapped as a stepPanicErr, and treated as an error.
 //
-// If any step depends on a step that doesn't exist in steps, returns an error without executing.
+// If any step depends on a step that doesn't exist in steps, or steps contain a dependency cycle,
+// returns an error without executing.
 func (r *StepRunner) Execute(ctx context.Context, steps []*Step) error {
 	// Create the run state for each step.
-	r.states = make(map[*Step]*stepState, len(steps))
+	states := make(map[*Step]*stepState, len(steps))
 	for _, step := range steps {
-		if _, ok := r.states[step]; ok {
+		if _, ok := states[step]; ok {
 			return fmt.Errorf("step %q in provided steps is a duplicate", step.Name)
 		}
-		r.states[step] = &stepState{
+		states[step] = &stepState{
 			step:     step,
 			status:   StepStatusWaiting,
 			complete: make(chan struct{}),
@@ -49,35 +80,242 @@ func (r *StepRunner) Execute(ctx context.Context, steps []*Step) error {
 
 	// Check that all dependencies can be resolved properly before letting anything start (even for
 	// an instant.)
-	for _, state := range r.states {
-		_, err := state.allDependencyStepStates(r.states)
+	for _, state := range states {
+		_, err := state.allDependencyStepStates(states)
 		if err != nil {
 			return err
 		}
 	}
+	// A cycle would make the steps in it wait for each other forever.
+	if _, err := transitiveDependencies(steps); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.steps = steps
+	r.states = states
+	r.mu.Unlock()
+
+	return r.Resume(ctx)
+}
+
+// Resume runs every step from the most recent Execute that is still waiting, blocking until all are
+// complete. Steps that already succeeded or failed are not run again: use ResetGroup first to make
+// a group of steps eligible to run again.
+//
+// Returns an error if Execute hasn't been called, or if any step is still failed once all steps
+// are complete, even if that step didn't run during this call.
+func (r *StepRunner) Resume(ctx context.Context) error {
+	if r.states == nil {
+		return errors.New("no steps to resume: Execute hasn't been called")
+	}
 
 	// Wait for all steps to complete. Use an ErrGroup to attempt to cancel, but note that it's
 	// cooperative, and a step may not cancel immediately e.g. if it's in the middle of an
 	// operation that can't easily be resumed.
 	eg, egCtx := errgroup.WithContext(ctx)
 	for _, state := range r.states {
+		if state.currentStatus() != StepStatusWaiting {
+			continue
+		}
 		eg.Go(func() error {
-			return state.run(egCtx, r.states)
+			return state.run(egCtx, r.clock(), r.OnProgress, r.states)
 		})
 	}
-	return eg.Wait()
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	// A step that failed in an earlier run and wasn't reset is still failed, so the steps as a
+	// whole haven't completed successfully.
+	var failed []error
+	for _, step := range r.steps {
+		if err := r.states[step].currentErr(); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// Status returns the status of the given step as of the most recent Execute or Resume. Returns an
+// error if the step isn't known to the runner.
+func (r *StepRunner) Status(step *Step) (StepStatus, error) {
+	r.mu.Lock()
+	state, ok := r.states[step]
+	r.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("step %q is unknown", step.Name)
+	}
+	return state.currentStatus(), nil
+}
+
+// ResetGroup sets every step in the named group back to waiting, so the next call to Resume runs
+// them again. Steps that transitively depend on a reset step and didn't succeed are also reset, so
+// they are able to run once the group completes. Steps that failed only because another step's
+// failure canceled them are reset too, along with their dependents. Returns the number of steps
+// reset.
+//
+// Returns an error if no step is in the group. Must not be called while steps are running.
+func (r *StepRunner) ResetGroup(group string) (int, error) {
+	groupStates := r.groupStates(group)
+	if len(groupStates) == 0 {
+		return 0, fmt.Errorf("no steps in group %q", group)
+	}
+	for _, state := range groupStates {
+		state.reset()
+	}
+	n := len(groupStates) + r.resetDependents(groupStates)
+	return n + r.resetCanceled(), nil
+}
+
+// SkipGroup marks every step in the named group that hasn't succeeded as succeeded without running
+// it. This lets steps that depend on the group run after the group's work has been done some other
+// way, such as manually. Like ResetGroup, dependents that didn't succeed are reset. Returns the
+// number of steps skipped.
+//
+// Returns an error if no step is in the group. Must not be called while steps are running.
+func (r *StepRunner) SkipGroup(group string) (int, error) {
+	groupStates := r.groupStates(group)
+	if len(groupStates) == 0 {
+		return 0, fmt.Errorf("no steps in group %q", group)
+	}
+	var n int
+	for _, state := range groupStates {
+		if state.skip() {
+			n++
+		}
+	}
+	r.resetDependents(groupStates)
+	r.resetCanceled()
+	return n, nil
+}
+
+// resetCanceled resets every failed step whose error is context.Canceled, and the steps that
+// depend on them. When one step fails, the rest of the run is canceled, so these steps didn't fail
+// on their own and would otherwise never get another chance to run. Returns the number of steps
+// reset.
+func (r *StepRunner) resetCanceled() int {
+	var canceled []*stepState
+	for _, state := range r.states {
+		if errors.Is(state.currentErr(), context.Canceled) {
+			state.reset()
+			canceled = append(canceled, state)
+		}
+	}
+	if len(canceled) == 0 {
+		return 0
+	}
+	return len(canceled) + r.resetDependents(canceled)
+}
+
+// resetDependents resets every step that transitively depends on one of the given states and
+// didn't succeed. Returns the number of steps reset.
+func (r *StepRunner) resetDependents(states []*stepState) int {
+	visited := make(map[*Step]struct{}, len(states))
+	for _, state := range states {
+		visited[state.step] = struct{}{}
+	}
+	var n int
+	// Keep looking for dependents until no new steps are found. The graph is small enough that
+	// repeated passes are simpler than building a reverse dependency index.
+	for changed := true; changed; {
+		changed = false
+		for _, state := range r.states {
+			if _, ok := visited[state.step]; ok || state.currentStatus() == StepStatusSucceeded {
+				continue
+			}
+			for _, d := range state.step.DependsOn {
+				if _, ok := visited[d]; ok {
+					if state.reset() {
+						n++
+					}
+					visited[state.step] = struct{}{}
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return n
+}
+
+func (r *StepRunner) clock() Clock {
+	if r.Clock == nil {
+		return RealClock{}
+	}
+	return r.Clock
+}
+
+func (r *StepRunner) groupStates(group string) []*stepState {
+	var states []*stepState
+	for _, state := range r.states {
+		if state.step.Group == group {
+			states = append(states, state)
+		}
+	}
+	return states
 }
 
 type stepState struct {
 	step *Step
 
-	err    error
-	status StepStatus
+	// mu protects err, status, started, and progress, which are updated by the goroutine running
+	// the step and may be read concurrently by Status and Snapshot.
+	mu      sync.Mutex
+	err     error
+	status  StepStatus
+	started time.Time
+	// progress is the most recent message passed to ReportProgress, reported at progressTime.
+	progress     string
+	progressTime time.Time
 	// complete is closed when the step is done after err and status are updated.
 	complete chan struct{}
 }
 
-func (s *stepState) run(ctx context.Context, states map[*Step]*stepState) (err error) {
+func (s *stepState) currentStatus() StepStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// currentErr returns the error the step failed with, or nil if it hasn't failed.
+func (s *stepState) currentErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// skip marks the step as succeeded without running it. Returns false if it already succeeded.
+func (s *stepState) skip() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == StepStatusSucceeded {
+		return false
+	}
+	if s.status == StepStatusWaiting {
+		close(s.complete)
+	}
+	s.err = nil
+	s.status = StepStatusSucceeded
+	return true
+}
+
+// reset makes the step eligible to run again. Returns false if it was already waiting.
+func (s *stepState) reset() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == StepStatusWaiting {
+		return false
+	}
+	s.err = nil
+	s.status = StepStatusWaiting
+	s.started = time.Time{}
+	s.progress = ""
+	s.progressTime = time.Time{}
+	s.complete = make(chan struct{})
+	return true
+}
+
+func (s *stepState) run(ctx context.Context, clock Clock, onProgress func(step *Step, message string), states map[*Step]*stepState) (err error) {
 	defer func() {
 		// Capture a panic and return it as an error. The caller wants other steps to have a chance
 		// to clean up via context cancellation rather than terminating immediately.
@@ -86,6 +324,7 @@ func (s *stepState) run(ctx context.Context, states map[*Step]*stepState) (err e
 		}
 
 		// Update status on the way out, for reporting to the release runner.
+		s.mu.Lock()
 		if err != nil {
 			// Wrap error with the step name for context.
 			err = fmt.Errorf("step %q failed: %w", s.step.Name, err)
@@ -94,6 +333,7 @@ func (s *stepState) run(ctx context.Context, states map[*Step]*stepState) (err e
 		} else {
 			s.status = StepStatusSucceeded
 		}
+		s.mu.Unlock()
 
 		// Signal step completion to any steps waiting on this one.
 		close(s.complete)
@@ -102,12 +342,20 @@ func (s *stepState) run(ctx context.Context, states map[*Step]*stepState) (err e
 	if err := s.waitForDependencies(ctx, states); err != nil {
 		return err
 	}
+	if s.step.SkipReason != "" {
+		return nil
+	}
+	s.mu.Lock()
 	s.status = StepStatusRunning
+	s.started = clock.Now()
+	s.mu.Unlock()
+
+	ctx = s.withProgress(ctx, clock, onProgress)
 
 	if s.step.Timeout == NoTimeout {
 		return s.step.Func(ctx)
 	}
-	deadlineCtx, cancel := context.WithTimeout(ctx, s.step.Timeout)
+	deadlineCtx, cancel := withTimeout(ctx, clock, s.step.Timeout)
 	defer cancel()
 	return s.step.Func(deadlineCtx)
 }
@@ -5,9 +5,14 @@ package coordinator
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func execute(t *testing.T, step *Step) error {
@@ -54,3 +59,414 @@ func TestStepRunner_Execute_PanicToError(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+func TestStepRunner_ResetGroup(t *testing.T) {
+	// Test that a failed group can be reset and retried without re-running succeeded steps.
+	var rootRuns, publishRuns, finalRuns int
+	failPublish := true
+	root := NewRootStep("root", NoTimeout, func(ctx context.Context) error {
+		rootRuns++
+		return nil
+	})
+	publish := root.Then("publish", NoTimeout, func(ctx context.Context) error {
+		publishRuns++
+		if failPublish {
+			return fmt.Errorf("intentional failure")
+		}
+		return nil
+	}).InGroup("publish-group")
+	final := publish.Then("final", NoTimeout, func(ctx context.Context) error {
+		finalRuns++
+		return nil
+	})
+
+	steps, err := final.TransitiveDependencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr StepRunner
+	if err := sr.Execute(context.Background(), steps); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if _, err := sr.ResetGroup("unknown"); err == nil {
+		t.Fatal("expected error resetting unknown group")
+	}
+	n, err := sr.ResetGroup("publish-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The publish step and its failed dependent.
+	if n != 2 {
+		t.Errorf("ResetGroup() = %v, want 2", n)
+	}
+
+	failPublish = false
+	if err := sr.Resume(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rootRuns != 1 || publishRuns != 2 || finalRuns != 1 {
+		t.Errorf("runs: root %v, publish %v, final %v; want 1, 2, 1", rootRuns, publishRuns, finalRuns)
+	}
+	if s, err := sr.Status(final); err != nil || s != StepStatusSucceeded {
+		t.Errorf("Status(final) = %v, %v; want succeeded", s, err)
+	}
+}
+
+func TestStepRunner_ResetGroup_Canceled(t *testing.T) {
+	// Test that a step canceled by another step's failure is reset along with the failed group.
+	var otherRuns, finalRuns int
+	failPublish := true
+	publish := NewRootStep("publish", NoTimeout, func(ctx context.Context) error {
+		if failPublish {
+			return fmt.Errorf("intentional failure")
+		}
+		return nil
+	}).InGroup("publish-group")
+	other := NewRootStep("other", NoTimeout, func(ctx context.Context) error {
+		otherRuns++
+		if otherRuns == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	final := NewStep("final", NoTimeout, func(ctx context.Context) error {
+		finalRuns++
+		return nil
+	}, publish, other)
+
+	steps, err := final.TransitiveDependencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr StepRunner
+	if err := sr.Execute(context.Background(), steps); err == nil {
+		t.Fatal("expected error")
+	}
+	if s, err := sr.Status(other); err != nil || s != StepStatusFailed {
+		t.Fatalf("Status(other) = %v, %v; want failed", s, err)
+	}
+
+	n, err := sr.ResetGroup("publish-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The publish step, the canceled step, and their failed dependent.
+	if n != 3 {
+		t.Errorf("ResetGroup() = %v, want 3", n)
+	}
+
+	failPublish = false
+	if err := sr.Resume(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if otherRuns != 2 || finalRuns != 1 {
+		t.Errorf("runs: other %v, final %v; want 2, 1", otherRuns, finalRuns)
+	}
+}
+
+func TestStepRunner_Resume_StillFailed(t *testing.T) {
+	// Test that Resume reports a step that failed earlier and wasn't reset.
+	failPublish := true
+	publish := NewRootStep("publish", NoTimeout, func(ctx context.Context) error {
+		if failPublish {
+			return fmt.Errorf("intentional failure")
+		}
+		return nil
+	}).InGroup("publish-group")
+	other := NewRootStep("other", NoTimeout, func(ctx context.Context) error {
+		return fmt.Errorf("intentional failure")
+	})
+
+	var sr StepRunner
+	if err := sr.Execute(context.Background(), []*Step{publish, other}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := sr.ResetGroup("publish-group"); err != nil {
+		t.Fatal(err)
+	}
+	failPublish = false
+	err := sr.Resume(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `step "other" failed`) {
+		t.Errorf("Resume() error = %v, want error about step other", err)
+	}
+	if s, err := sr.Status(publish); err != nil || s != StepStatusSucceeded {
+		t.Errorf("Status(publish) = %v, %v; want succeeded", s, err)
+	}
+}
+
+func TestStepRunner_SkipGroup(t *testing.T) {
+	// Test that skipping a failed group lets its dependents run.
+	publish := NewRootStep("publish", NoTimeout, func(ctx context.Context) error {
+		return fmt.Errorf("intentional failure")
+	}).InGroup("publish-group")
+	var finalRuns int
+	final := publish.Then("final", NoTimeout, func(ctx context.Context) error {
+		finalRuns++
+		return nil
+	})
+
+	steps, err := final.TransitiveDependencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr StepRunner
+	if err := sr.Execute(context.Background(), steps); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := sr.SkipGroup("publish-group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.Resume(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if finalRuns != 1 {
+		t.Errorf("final ran %v times, want 1", finalRuns)
+	}
+}
+
+func TestStepRunner_Execute_SkipIf(t *testing.T) {
+	var ran []string
+	var mu sync.Mutex
+	record := func(name string) StepFunc {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	first := NewRootStep("first", NoTimeout, record("first"))
+	skipped := first.Then("skipped", NoTimeout, func(context.Context) error {
+		return errors.New("skipped step ran")
+	}).SkipIf(true, "not needed")
+	notSkipped := first.Then("not skipped", NoTimeout, record("not skipped")).SkipIf(false, "unused")
+	last := NewStep("last", NoTimeout, record("last"), skipped, notSkipped)
+
+	steps, err := last.TransitiveDependencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr StepRunner
+	if err := sr.Execute(context.Background(), steps); err != nil {
+		t.Fatal(err)
+	}
+	// The skipped step's dependents still run, after the skipped step's own dependencies.
+	if len(ran) != 3 || ran[0] != "first" || ran[2] != "last" {
+		t.Errorf("ran %v, want first, not skipped, last", ran)
+	}
+
+	snap, err := sr.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range snap.Steps {
+		wantReason := ""
+		if s.Name == "skipped" {
+			wantReason = "not needed"
+		}
+		if s.Status != StepStatusSucceeded || s.SkipReason != wantReason {
+			t.Errorf("step %+v, want succeeded with skip reason %q", s, wantReason)
+		}
+	}
+}
+
+func TestStepRunner_Execute_Cycle(t *testing.T) {
+	// Test that a dependency cycle is reported before any step runs, rather than deadlocking.
+	f := func(ctx context.Context) error {
+		t.Fatal("step ran")
+		return nil
+	}
+	a := NewRootStep("a", NoTimeout, f)
+	b := a.Then("b", NoTimeout, f)
+	a.DependsOn = append(a.DependsOn, b)
+
+	var sr StepRunner
+	err := sr.Execute(context.Background(), []*Step{a, b})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "a <- b <- a") && !strings.Contains(err.Error(), "b <- a <- b") {
+		t.Errorf("error doesn't name the steps in the cycle: %v", err)
+	}
+}
+
+func TestStepRunner_Execute_FakeClockTimeout(t *testing.T) {
+	// Test that a step's timeout is enforced by the runner's clock, not real time.
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	a := NewRootStep(
+		"slow", 10*time.Minute,
+		func(ctx context.Context) error {
+			deadline, ok := ctx.Deadline()
+			if !ok || !deadline.Equal(clock.Now().Add(10*time.Minute)) {
+				t.Errorf("unexpected deadline %v, %v", deadline, ok)
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	)
+	go func() {
+		if err := clock.BlockUntil(context.Background(), 1); err != nil {
+			t.Error(err)
+			return
+		}
+		// Not yet expired: the step must still be waiting afterward.
+		clock.Advance(9 * time.Minute)
+		clock.Advance(time.Minute)
+	}()
+
+	sr := StepRunner{Clock: clock}
+	err := sr.Execute(context.Background(), []*Step{a})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got: %v", err)
+	}
+	if status, _ := sr.Status(a); status != StepStatusFailed {
+		t.Errorf("expected step to fail, got status %v", status)
+	}
+}
+
+func TestStepRunner_Execute_FakeClockNoTimeout(t *testing.T) {
+	// Test that a step that finishes before the fake clock advances isn't affected by its timeout.
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	a := NewRootStep(
+		"fast", 10*time.Minute,
+		func(ctx context.Context) error {
+			clock.Advance(9 * time.Minute)
+			return ctx.Err()
+		},
+	)
+	sr := StepRunner{Clock: clock}
+	if err := sr.Execute(context.Background(), []*Step{a}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStepRunner_Snapshot(t *testing.T) {
+	// Test that a snapshot can be taken while steps are running and reflects each step's state.
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	running := make(chan struct{})
+	release := make(chan struct{})
+	first := NewRootStep("first", NoTimeout, func(ctx context.Context) error {
+		return nil
+	})
+	blocked := first.Then("blocked", NoTimeout, func(ctx context.Context) error {
+		close(running)
+		<-release
+		return errors.New("intentional failure")
+	}).InGroup("group")
+	waiting := blocked.Then("waiting", NoTimeout, func(ctx context.Context) error {
+		return nil
+	})
+
+	sr := StepRunner{Clock: clock}
+	if _, err := sr.Snapshot(); err == nil {
+		t.Error("expected error before Execute")
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- sr.Execute(context.Background(), []*Step{first, blocked, waiting})
+	}()
+	<-running
+
+	snap, err := sr.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err == nil {
+		t.Fatal("expected error")
+	}
+
+	if len(snap.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %+v", snap.Steps)
+	}
+	for i, want := range []struct {
+		name    string
+		status  StepStatus
+		started bool
+	}{
+		{"first", StepStatusSucceeded, true},
+		{"blocked", StepStatusRunning, true},
+		{"waiting", StepStatusWaiting, false},
+	} {
+		got := snap.Steps[i]
+		if got.Name != want.name || got.Status != want.status || (got.Start != nil) != want.started {
+			t.Errorf("step %v: got %+v, want name %v, status %v, started %v", i, got, want.name, want.status, want.started)
+		}
+		if got.Start != nil && !got.Start.Equal(start) {
+			t.Errorf("step %v: start %v, want %v", i, got.Start, start)
+		}
+	}
+
+	b, err := json.Marshal(snap.Steps[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"blocked","group":"group","status":"running","start":"2024-01-01T00:00:00Z"}`; string(b) != want {
+		t.Errorf("JSON = %s, want %s", b, want)
+	}
+
+	// After the run, the snapshot includes the failure.
+	snap, err = sr.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := snap.Steps[1]; got.Status != StepStatusFailed || !strings.Contains(got.Error, "intentional failure") {
+		t.Errorf("expected blocked to fail with intentional failure, got %+v", got)
+	}
+}
+
+func TestStepRunner_ReportProgress(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	poll := NewRootStep("poll", NoTimeout, func(ctx context.Context) error {
+		ReportProgress(ctx, "still waiting, 0 minutes elapsed")
+		close(reported)
+		<-release
+		return nil
+	})
+
+	var mu sync.Mutex
+	var messages []string
+	sr := StepRunner{
+		Clock: clock,
+		OnProgress: func(step *Step, message string) {
+			mu.Lock()
+			defer mu.Unlock()
+			messages = append(messages, step.Name+": "+message)
+		},
+	}
+
+	// Reporting progress outside a step does nothing.
+	ReportProgress(context.Background(), "ignored")
+
+	done := make(chan error)
+	go func() {
+		done <- sr.Execute(context.Background(), []*Step{poll})
+	}()
+	<-reported
+
+	snap, err := sr.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := snap.Steps[0]
+	if got.Progress != "still waiting, 0 minutes elapsed" || got.ProgressTime == nil || !got.ProgressTime.Equal(start) {
+		t.Errorf("expected progress reported at %v, got %+v", start, got)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"poll: still waiting, 0 minutes elapsed"}; !slices.Equal(messages, want) {
+		t.Errorf("OnProgress got %v, want %v", messages, want)
+	}
+}
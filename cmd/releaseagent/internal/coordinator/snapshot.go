@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package coordinator
+
+import (
+	"errors"
+	"time"
+)
+
+// Snapshot is the state of every step in a StepRunner at a point in time. It is intended to be
+// serialized as JSON and rendered elsewhere, for example in the release tracking issue to show
+// live progress.
+type Snapshot struct {
+	// Time is when the snapshot was taken, according to the runner's Clock.
+	Time  time.Time      `json:"time"`
+	Steps []StepSnapshot `json:"steps"`
+}
+
+// StepSnapshot is the state of a single step at the time of a Snapshot.
+type StepSnapshot struct {
+	Name   string     `json:"name"`
+	Group  string     `json:"group,omitempty"`
+	Status StepStatus `json:"status"`
+	// SkipReason is the reason the step doesn't run, if it's skipped. See Step.SkipReason.
+	SkipReason string `json:"skipReason,omitempty"`
+	// Start is when the step started running, or nil if it hasn't started since the most recent
+	// Execute or reset.
+	Start *time.Time `json:"start,omitempty"`
+	// Progress is the most recent message the step reported using ReportProgress, if any.
+	Progress string `json:"progress,omitempty"`
+	// ProgressTime is when Progress was reported, or nil if there is no Progress.
+	ProgressTime *time.Time `json:"progressTime,omitempty"`
+	// Error is the error message if the step failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Snapshot returns the current state of each step from the most recent Execute, in the order the
+// steps were passed to Execute.
+//
+// Unlike the State that the step implementations modify, the snapshot is safe to take from another
+// goroutine while steps are running. It only reads the runner's own bookkeeping, and the result
+// doesn't share memory with the runner.
+//
+// Returns an error if Execute hasn't been called.
+func (r *StepRunner) Snapshot() (*Snapshot, error) {
+	r.mu.Lock()
+	steps, states := r.steps, r.states
+	r.mu.Unlock()
+	if states == nil {
+		return nil, errors.New("no steps to snapshot: Execute hasn't been called")
+	}
+
+	snap := &Snapshot{
+		Time:  r.clock().Now(),
+		Steps: make([]StepSnapshot, 0, len(steps)),
+	}
+	for _, step := range steps {
+		snap.Steps = append(snap.Steps, states[step].snapshot())
+	}
+	return snap, nil
+}
+
+func (s *stepState) snapshot() StepSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss := StepSnapshot{
+		Name:       s.step.Name,
+		Group:      s.step.Group,
+		Status:     s.status,
+		SkipReason: s.step.SkipReason,
+	}
+	if !s.started.IsZero() {
+		start := s.started
+		ss.Start = &start
+	}
+	if s.progress != "" {
+		ss.Progress = s.progress
+		progressTime := s.progressTime
+		ss.ProgressTime = &progressTime
+	}
+	if s.err != nil {
+		ss.Error = s.err.Error()
+	}
+	return ss
+}
@@ -31,6 +31,14 @@ type Step struct {
 	Func StepFunc
 	// DependsOn is a list of steps that must all complete before Func is run.
 	DependsOn []*Step
+	// Group is the name of the group this step belongs to, or empty if none. A group lets the
+	// release runner address a set of related steps as a unit, for example to retry them together
+	// using StepRunner.ResetGroup.
+	Group string
+	// SkipReason, if not empty, means the step isn't needed in this release. The runner still waits
+	// for the step's dependencies, then marks the step succeeded without running Func, so steps
+	// that depend on it run as usual. Set it while creating the step graph, using SkipIf.
+	SkipReason string
 }
 
 // NewRootStep creates a new step with the given name, implementation, and no dependencies.
@@ -82,6 +90,30 @@ func (s *Step) Then(name string, timeout time.Duration, f StepFunc, dependsOnAdd
 	}
 }
 
+// InGroup sets the group of s and returns s. This can be used when defining a step graph to tag
+// a step without breaking up a chain of Then calls.
+func (s *Step) InGroup(group string) *Step {
+	s.Group = group
+	return s
+}
+
+// SkipIf marks s as skipped for the given reason if skip is true, and returns s. This lets a step
+// graph leave out work that the release inputs don't call for without changing the graph's shape.
+// See Step.SkipReason.
+func (s *Step) SkipIf(skip bool, reason string) *Step {
+	if skip {
+		s.SkipReason = reason
+	}
+	return s
+}
+
+// SetGroup sets the group of each step in steps.
+func SetGroup(group string, steps ...*Step) {
+	for _, s := range steps {
+		s.Group = group
+	}
+}
+
 // TransitiveDependencies returns all the steps s transitively depends on. Returns an error if a
 // cycle is detected.
 //
@@ -93,6 +125,13 @@ func (s *Step) Then(name string, timeout time.Duration, f StepFunc, dependsOnAdd
 //
 // The result is reproducible for a given slice of steps and their dependency slices.
 func (s *Step) TransitiveDependencies() ([]*Step, error) {
+	return transitiveDependencies([]*Step{s})
+}
+
+// transitiveDependencies returns all the steps in roots and all the steps they transitively depend
+// on, topologically sorted. See [Step.TransitiveDependencies]. Returns an error naming the steps in
+// the cycle if a cycle is detected.
+func transitiveDependencies(roots []*Step) ([]*Step, error) {
 	type visitState int
 	v := make(map[*Step]visitState)
 	const (
@@ -136,8 +175,10 @@ func (s *Step) TransitiveDependencies() ([]*Step, error) {
 
 		return nil
 	}
-	if cycle := visit(s); cycle != nil {
-		return nil, fmt.Errorf("encountered cycle: %v", strings.Join(cycle, " <- "))
+	for _, s := range roots {
+		if cycle := visit(s); cycle != nil {
+			return nil, fmt.Errorf("encountered cycle: %v", strings.Join(cycle, " <- "))
+		}
 	}
 	return sortedSteps, nil
 }
@@ -154,7 +195,11 @@ func CreateMermaidStepFlowchart(steps []*Step) string {
 	fmt.Fprintf(&sb, "---\nconfig:\n  layout: elk\n---\n")
 	fmt.Fprintf(&sb, "flowchart RL\n")
 	for i, step := range steps {
-		fmt.Fprintf(&sb, "  %v(%v)", i, step.Name)
+		if step.SkipReason != "" {
+			fmt.Fprintf(&sb, "  %v(%v, skipped)", i, step.Name)
+		} else {
+			fmt.Fprintf(&sb, "  %v(%v)", i, step.Name)
+		}
 		if len(step.DependsOn) != 0 {
 			fmt.Fprintf(&sb, " --> ")
 			for i, dep := range step.DependsOn {
@@ -48,7 +48,9 @@ type Input struct {
 	MicrosoftGoInnerloopPipeline int
 	MicrosoftGoImagesPipeline    int
 	MicrosoftGoAkaMSPipeline     int
-	AzureLinuxCreatePRPipeline   int
+	// AzureLinuxCreatePRPipeline is the pipeline that submits the Azure Linux update PR. If 0, the
+	// release skips that step.
+	AzureLinuxCreatePRPipeline int
 }
 
 func (i *Input) checksum() (uint32, error) {
@@ -124,6 +126,9 @@ type VersionState struct {
 // mocked for testing.
 //
 // If a method returns an error, other returned values must be zero. Retry logic depends on this.
+//
+// Methods that poll for a long time should use coordinator.ReportProgress with ctx to periodically
+// report that they are still waiting, so the release doesn't appear stuck.
 type ServiceBundle interface {
 	CreateReleaseDayTrackingIssue(ctx context.Context, repo, runner string, versions []string, secret *Secret) (int, error)
 	PollUpstreamTagCommit(ctx context.Context, version string) (string, error)
@@ -182,7 +187,8 @@ const (
 // used to resume a future release.
 //
 // While any step is running, it may modify State, so it is not safe to access the returned State.
-// When all steps are complete (success or fail), State can then be safely used.
+// When all steps are complete (success or fail), State can then be safely used. To report progress
+// while steps are running, use coordinator.StepRunner.Snapshot instead.
 //
 // Implementation note: this function should only contain coordination code (moving inputs/outputs
 // between steps through the State and synchronizing). All work involving external resources should
@@ -487,7 +493,7 @@ func CreateStepGraph(ri *Input, secret *Secret, rs *State, sb ServiceBundle) ([]
 				return nil
 			},
 			readyForPublish,
-		)
+		).SkipIf(ri.AzureLinuxCreatePRPipeline == 0, "no Azure Linux PR creation pipeline is configured")
 
 		versionSpecificPublishSteps = append(versionSpecificPublishSteps, coordinator.NewIndicatorStep(
 			name("✅ External publish complete"),
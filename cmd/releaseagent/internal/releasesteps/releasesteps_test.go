@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/microsoft/go-infra/cmd/releaseagent/internal/coordinator"
 	"github.com/microsoft/go-infra/goldentest"
@@ -128,7 +129,10 @@ func TestRunFakeRelease(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	var runner coordinator.StepRunner
+	// Use a fake clock so no step can time out, no matter how slow the test machine is.
+	runner := coordinator.StepRunner{
+		Clock: coordinator.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
 	if err := runner.Execute(context.Background(), steps); err != nil {
 		t.Fatal(err)
 	}
@@ -114,19 +114,13 @@ func createAkaMSLinks(assetFilePath, assetManifestPath string) error {
 	if err := stringutil.ReadJSONFile(assetFilePath, &b); err != nil {
 		return err
 	}
+	if err := b.Validate(); err != nil {
+		return err
+	}
 
-	var assetJSONUrl string
-	if assetManifestPath != "" {
-		var m publishmanifest.Manifest
-		if err := stringutil.ReadJSONFile(assetManifestPath, &m); err != nil {
-			return fmt.Errorf("failed to read publish manifest: %v", err)
-		}
-		for _, p := range m.Published {
-			if p.Filename == "assets.json" {
-				assetJSONUrl = p.URL
-				break
-			}
-		}
+	assetJSONUrl, err := readPublishedAssetJSONURL(assetManifestPath)
+	if err != nil {
+		return err
 	}
 
 	linkPairs, err := createLinkPairs(b, assetJSONUrl)
@@ -190,6 +184,25 @@ func createAkaMSLinks(assetFilePath, assetManifestPath string) error {
 	return nil
 }
 
+// readPublishedAssetJSONURL reads the publish manifest at path and returns the URL where the build
+// asset JSON file was published. Returns empty string if path is empty or the manifest doesn't
+// include the build asset JSON file.
+func readPublishedAssetJSONURL(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	var m publishmanifest.Manifest
+	if err := stringutil.ReadJSONFile(path, &m); err != nil {
+		return "", fmt.Errorf("failed to read publish manifest: %v", err)
+	}
+	for _, p := range m.Published {
+		if p.Filename == "assets.json" {
+			return p.URL, nil
+		}
+	}
+	return "", nil
+}
+
 type akaMSLinkPair struct {
 	Short  string
 	Target string
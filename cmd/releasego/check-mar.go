@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os/exec"
+
+	"github.com/microsoft/go-infra/executil"
+	"github.com/microsoft/go-infra/subcmd"
+)
+
+func init() {
+	subcommands = append(subcommands, subcmd.Option{
+		Name:    "check-mar",
+		Summary: "Check once that the latest Microsoft Go image on MAR matches a version, and print its digest.",
+		Description: `
+
+Constructs the tag name for the version's major version (1.20.3-1 -> 1.20), pulls it, and checks
+that it contains the expected major+minor+patch Go version, the same way as
+wait-latest-mar-go-version. If it does, prints the digest the tag resolves to.
+
+This is intended for manual verification outside a full release. Unlike
+wait-latest-mar-go-version, it doesn't poll: it exits nonzero if the version isn't found.
+`,
+		Handle: handleCheckMAR,
+	})
+}
+
+func handleCheckMAR(p subcmd.ParseFunc) error {
+	version := flag.String("version", "", "[Required] A full or partial microsoft/go version number (major.minor.patch[-revision[-suffix]]).")
+
+	if err := p(); err != nil {
+		return err
+	}
+
+	if *version == "" {
+		return errors.New("no version specified")
+	}
+
+	// Make our logs stand out from Docker's.
+	log.Default().SetPrefix("---- ")
+
+	tag := marGoVersionTag(*version)
+	found, err := checkMARGoVersion(*version)
+	if err != nil {
+		return fmt.Errorf("failed to check %v: %w", tag, err)
+	}
+	if !found {
+		return fmt.Errorf(
+			"%v doesn't contain Go %v. "+
+				"Images may take some time to propagate to MAR after publishing: "+
+				"if it was published recently, wait and try again, or use wait-latest-mar-go-version",
+			tag, *version)
+	}
+
+	digest, err := executil.SpaceTrimmedCombinedOutput(exec.Command(
+		"docker", "image", "inspect", "--format", "{{index .RepoDigests 0}}", tag))
+	if err != nil {
+		return fmt.Errorf("failed to find digest of %v: %w", tag, err)
+	}
+	log.Printf("%v contains Go %v: %v\n", tag, *version, digest)
+	return nil
+}
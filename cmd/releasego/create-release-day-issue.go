@@ -8,6 +8,7 @@ import (
 	"errors"
 	"flag"
 	"log"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -34,6 +35,25 @@ const docPointerMarkdown = "For more information about the microsoft/go release
 
 var releaseIssueLabels = []string{"Area-Release"}
 
+// releaseDayIssueContent returns the title and markdown body of the tracking issue for the given
+// releases happening on day. If notify isn't empty, that GitHub user is tagged in the body.
+func releaseDayIssueContent(releases []string, notify string, day time.Time) (title, body string) {
+	releases = slices.Clone(releases)
+	sort.Strings(releases)
+
+	title = day.UTC().Format("2006-01-02") + " releases: " + strings.Join(releases, ", ")
+	body = "This issue tracks the status of ongoing microsoft/go releases and the image release " +
+		"from [microsoft/go-images](https://github.com/microsoft/go-images). " +
+		"I am a bot, and I'll keep the issue up to date and add a comment when I notice " +
+		"something happen that likely requires the release runner to take some manual action." +
+		"\n\n" + docPointerMarkdown
+
+	if notify != "" {
+		body += "\n\n/cc @" + notify
+	}
+	return title, body
+}
+
 func handleCreateReleaseDayIssue(p subcmd.ParseFunc) error {
 	repo := githubutil.BindRepoFlag()
 	pat := githubutil.BindPATFlag()
@@ -60,19 +80,7 @@ func handleCreateReleaseDayIssue(p subcmd.ParseFunc) error {
 		return err
 	}
 
-	releases := strings.Split(*releasesFlag, ",")
-	sort.Strings(releases)
-
-	title := time.Now().UTC().Format("2006-01-02") + " releases: " + strings.Join(releases, ", ")
-	desc := "This issue tracks the status of ongoing microsoft/go releases and the image release " +
-		"from [microsoft/go-images](https://github.com/microsoft/go-images). " +
-		"I am a bot, and I'll keep the issue up to date and add a comment when I notice " +
-		"something happen that likely requires the release runner to take some manual action." +
-		"\n\n" + docPointerMarkdown
-
-	if *notify != "" {
-		desc += "\n\n/cc @" + *notify
-	}
+	title, desc := releaseDayIssueContent(strings.Split(*releasesFlag, ","), *notify, time.Now())
 
 	ctx := context.Background()
 	client, err := githubutil.NewClient(ctx, *pat)
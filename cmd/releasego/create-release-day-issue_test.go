@@ -0,0 +1,33 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_releaseDayIssueContent(t *testing.T) {
+	day := time.Date(2024, 11, 6, 23, 0, 0, 0, time.UTC)
+	releases := []string{"1.23.3-1", "1.22.9-1"}
+
+	title, body := releaseDayIssueContent(releases, "runner", day)
+	if want := "2024-11-06 releases: 1.22.9-1, 1.23.3-1"; title != want {
+		t.Errorf("title = %q, want %q", title, want)
+	}
+	if !strings.Contains(body, docPointerMarkdown) {
+		t.Errorf("body doesn't point to the release docs:\n%v", body)
+	}
+	if !strings.HasSuffix(body, "\n\n/cc @runner") {
+		t.Errorf("body doesn't notify the runner:\n%v", body)
+	}
+	if releases[0] != "1.23.3-1" {
+		t.Errorf("releases slice was modified: %v", releases)
+	}
+
+	if _, body := releaseDayIssueContent(releases, "", day); strings.Contains(body, "/cc") {
+		t.Errorf("body notifies a user even though none was given:\n%v", body)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/go-infra/buildmodel/buildassets"
+	"github.com/microsoft/go-infra/stringutil"
+	"github.com/microsoft/go-infra/subcmd"
+)
+
+func init() {
+	subcommands = append(subcommands, subcmd.Option{
+		Name:    "diff-urls",
+		Summary: "Compare the arches and files of two releases to check that nothing was dropped.",
+		Description: `
+
+Builds the list of release URLs for each build asset JSON file, the same way url-manifest does, and
+reports the arches and files that are present in one release but not the other. File names are
+compared with the release version replaced by a placeholder, so "go1.22.1-1.linux-amd64.tar.gz"
+matches "go1.22.2-1.linux-amd64.tar.gz".
+
+Exits with an error if the new release is missing anything the old release had. Additions are only
+reported.
+
+Example:
+
+  go run ./cmd/releasego diff-urls -old /downloads/old/assets.json -new /downloads/new/assets.json
+`,
+		Handle: handleDiffURLs,
+	})
+}
+
+func handleDiffURLs(p subcmd.ParseFunc) error {
+	oldPath := flag.String("old", "", "[Required] The path of the build asset JSON file of the prior release.")
+	newPath := flag.String("new", "", "[Required] The path of the build asset JSON file of the release to check.")
+
+	if err := p(); err != nil {
+		return err
+	}
+
+	if *oldPath == "" || *newPath == "" {
+		flag.Usage()
+		return errors.New("both -old and -new must be specified")
+	}
+
+	var oldAssets, newAssets buildassets.BuildAssets
+	if err := stringutil.ReadJSONFile(*oldPath, &oldAssets); err != nil {
+		return err
+	}
+	if err := stringutil.ReadJSONFile(*newPath, &newAssets); err != nil {
+		return err
+	}
+
+	d := diffReleases(&oldAssets, &newAssets)
+	fmt.Printf("Comparing %v (old) to %v (new)\n", oldAssets.Version, newAssets.Version)
+	for _, s := range []struct {
+		name  string
+		items []string
+	}{
+		{"Arches missing from new release", d.missingArches},
+		{"Arches added in new release", d.addedArches},
+		{"Files missing from new release", d.missingFiles},
+		{"Files added in new release", d.addedFiles},
+	} {
+		if len(s.items) == 0 {
+			continue
+		}
+		fmt.Printf("%v:\n", s.name)
+		for _, item := range s.items {
+			fmt.Printf("  %v\n", item)
+		}
+	}
+
+	if len(d.missingArches) > 0 || len(d.missingFiles) > 0 {
+		return fmt.Errorf("new release is missing %v arches and %v files that the old release had", len(d.missingArches), len(d.missingFiles))
+	}
+	fmt.Println("New release has every arch and file the old release had.")
+	return nil
+}
+
+// releaseDiff is the difference between the arches and files of two releases.
+type releaseDiff struct {
+	missingArches, addedArches []string
+	missingFiles, addedFiles   []string
+}
+
+// diffReleases compares the arches and the release URL file names of two releases.
+func diffReleases(oldAssets, newAssets *buildassets.BuildAssets) releaseDiff {
+	var d releaseDiff
+	d.missingArches, d.addedArches = diffSets(releaseArches(oldAssets), releaseArches(newAssets))
+	d.missingFiles, d.addedFiles = diffSets(releaseFiles(oldAssets), releaseFiles(newAssets))
+	return d
+}
+
+// releaseArches returns the OS/arch key of each arch in the release, and "src" for the source
+// archive.
+func releaseArches(assets *buildassets.BuildAssets) []string {
+	arches := make([]string, 0, len(assets.Arches))
+	for _, a := range assets.Arches {
+		if a.Env == nil {
+			arches = append(arches, "src")
+			continue
+		}
+		arches = append(arches, a.Env.GOOS+"/"+a.Env.GoImageArchKey())
+	}
+	return arches
+}
+
+// releaseFiles returns the file name of each release URL, with the version replaced by a
+// placeholder so files can be matched across releases.
+func releaseFiles(assets *buildassets.BuildAssets) []string {
+	urls := appendReleaseURLs(nil, assets, "")
+	files := make([]string, 0, len(urls))
+	for _, u := range urls {
+		name := path.Base(u)
+		if assets.Version != "" {
+			name = strings.ReplaceAll(name, assets.Version, "{version}")
+		}
+		files = append(files, name)
+	}
+	return files
+}
+
+// diffSets returns the sorted, deduplicated items that are only in a, and those only in b.
+func diffSets(a, b []string) (onlyA, onlyB []string) {
+	inA := make(map[string]struct{}, len(a))
+	for _, s := range a {
+		inA[s] = struct{}{}
+	}
+	inB := make(map[string]struct{}, len(b))
+	for _, s := range b {
+		inB[s] = struct{}{}
+	}
+	for s := range inA {
+		if _, ok := inB[s]; !ok {
+			onlyA = append(onlyA, s)
+		}
+	}
+	for s := range inB {
+		if _, ok := inA[s]; !ok {
+			onlyB = append(onlyB, s)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	return onlyA, onlyB
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-infra/buildmodel/buildassets"
+	"github.com/microsoft/go-infra/buildmodel/dockerversions"
+)
+
+func Test_diffReleases(t *testing.T) {
+	release := func(version string, platforms ...[2]string) *buildassets.BuildAssets {
+		base := "https://example.org/golang/" + version + "/go" + version
+		b := &buildassets.BuildAssets{
+			Version:  version,
+			GoSrcURL: base + ".src.tar.gz",
+		}
+		for _, p := range platforms {
+			ext := ".tar.gz"
+			if p[0] == "windows" {
+				ext = ".zip"
+			}
+			b.Arches = append(b.Arches, &dockerversions.Arch{
+				Env: &dockerversions.ArchEnv{GOOS: p[0], GOARCH: p[1]},
+				URL: base + "." + p[0] + "-" + p[1] + ext,
+			})
+		}
+		return b
+	}
+	linux := [2]string{"linux", "amd64"}
+	windows := [2]string{"windows", "amd64"}
+	arm64 := [2]string{"linux", "arm64"}
+
+	tests := []struct {
+		name     string
+		old, new *buildassets.BuildAssets
+		want     releaseDiff
+	}{
+		{
+			"same platforms",
+			release("1.22.1-1", linux, windows),
+			release("1.22.2-1", windows, linux),
+			releaseDiff{},
+		},
+		{
+			"dropped and added",
+			release("1.22.1-1", linux, windows),
+			release("1.22.2-1", linux, arm64),
+			releaseDiff{
+				missingArches: []string{"windows/amd64"},
+				addedArches:   []string{"linux/arm64v8"},
+				missingFiles:  []string{"go{version}.windows-amd64.zip", "go{version}.windows-amd64.zip.sha256"},
+				addedFiles:    []string{"go{version}.linux-arm64.tar.gz", "go{version}.linux-arm64.tar.gz.sha256", "go{version}.linux-arm64.tar.gz.sig"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffReleases(tt.old, tt.new)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffReleases() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
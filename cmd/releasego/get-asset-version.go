@@ -60,6 +60,9 @@ func handleAssetVersion(p subcmd.ParseFunc) error {
 	if err := stringutil.ReadJSONFile(*buildAssetJSON, &b); err != nil {
 		return err
 	}
+	if err := b.Validate(); err != nil {
+		return err
+	}
 
 	assetVersion := b.GoVersion().Full()
 	log.Printf("Found version: %v\n", assetVersion)
@@ -6,7 +6,6 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
 	"log"
 
 	"github.com/microsoft/azure-devops-go-api/azuredevops/build"
@@ -67,7 +66,7 @@ func handleGetBuildInfo(p subcmd.ParseFunc) error {
 			return
 		}
 		log.Printf("Found %v value: %q\n", name, *value)
-		fmt.Printf("##vso[task.setvariable variable=%v]%v\n", *prefix+name, *value)
+		azdo.LogCmdSetVariable(*prefix+name, *value)
 	}
 	set("BuildNumber", b.BuildNumber)
 	set("SourceVersion", b.SourceVersion)
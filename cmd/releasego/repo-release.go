@@ -62,6 +62,9 @@ func handleRepoRelease(p subcmd.ParseFunc) error {
 	if err := stringutil.ReadJSONFile(*buildAssetJSON, &assets); err != nil {
 		return err
 	}
+	if err := assets.Validate(); err != nil {
+		return err
+	}
 	uploadPaths := assetPaths(*buildDir, assets.GoSrcURL)
 	uploadPaths = append(uploadPaths, *buildAssetJSON)
 	log.Println("First, creating draft release. Then, attaching these files before marking release ready:")
@@ -49,6 +49,23 @@ func handleReport(p subcmd.ParseFunc) error {
 		"A full microsoft/go version number (major.minor.patch-revision[-suffix]), if one applies.\n"+
 			"This is used to categorize the list of builds in a release issue.")
 
+	backupDir := flag.String(
+		"backup-dir", "",
+		"A directory to save a copy of the report data to after it is successfully updated.\n"+
+			"If not specified, no backup is made.")
+	backupArtifact := flag.String(
+		"backup-azdo-artifact", "",
+		"The name of an AzDO pipeline artifact to upload the backup copy to. Requires -backup-dir.")
+
+	flag.DurationVar(
+		&buildreport.RetryTimeout,
+		"retry-timeout", buildreport.RetryTimeout,
+		"How long to keep retrying the report update, for example if there are many concurrent updates.")
+	flag.DurationVar(
+		&buildreport.RetryDelay,
+		"retry-delay", buildreport.RetryDelay,
+		"How long to wait between report update attempts.")
+
 	if err := p(); err != nil {
 		return err
 	}
@@ -65,6 +82,9 @@ func handleReport(p subcmd.ParseFunc) error {
 	if *buildID == "" {
 		return errors.New("no build-id specified")
 	}
+	if *backupArtifact != "" && *backupDir == "" {
+		return errors.New("backup-azdo-artifact specified without backup-dir")
+	}
 
 	owner, name, err := githubutil.ParseRepoFlag(repo)
 	if err != nil {
@@ -105,5 +125,9 @@ func handleReport(p subcmd.ParseFunc) error {
 
 	log.Printf("Reporting %#v\n", s)
 	ctx := context.Background()
-	return buildreport.Update(ctx, owner, name, *pat, *issue, s)
+	backup := &buildreport.Backup{
+		Dir:              *backupDir,
+		AzDOArtifactName: *backupArtifact,
+	}
+	return buildreport.Update(ctx, owner, name, *pat, *issue, s, backup)
 }
@@ -58,6 +58,7 @@ func handleSync(p subcmd.ParseFunc) error {
 	if err := p(); err != nil {
 		return err
 	}
+	syncFlags.ConfigureLogging()
 
 	if *version == "" {
 		return errors.New("no version specified")
@@ -91,6 +92,10 @@ func handleSync(p subcmd.ParseFunc) error {
 	foundEntry.GoVersionFileContent = v.UpstreamFormatGitTag()
 	foundEntry.GoMicrosoftRevisionFileContent = v.Revision
 
+	if err := syncFlags.CheckTempGitDir([]sync.ConfigEntry{*foundEntry}); err != nil {
+		return err
+	}
+
 	dir, err := syncFlags.MakeGitWorkDir()
 	if err != nil {
 		return err
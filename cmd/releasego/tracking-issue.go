@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/microsoft/go-infra/subcmd"
+)
+
+func init() {
+	subcommands = append(subcommands, subcmd.Option{
+		Name:    "tracking-issue",
+		Summary: "Print the body of the release day tracking issue without creating it",
+		Description: `
+
+Renders the same issue body that create-release-day-issue submits and writes the markdown to
+stdout. Use this to proofread the issue before release day, or to recreate it manually if the
+original issue is lost. The issue title is logged to stderr.
+`,
+		Handle: handleTrackingIssue,
+	})
+}
+
+func handleTrackingIssue(p subcmd.ParseFunc) error {
+	versions := flag.String(
+		"version", "",
+		"[Required] The release numbers to track releasing during this day, separated by ','.")
+	runner := flag.String(
+		"runner", "",
+		"The GitHub user running the release, tagged in the issue body so they are notified of future updates.")
+	date := flag.String(
+		"date", "",
+		"The release day, in YYYY-MM-DD form. Defaults to today (UTC).")
+
+	if err := p(); err != nil {
+		return err
+	}
+
+	if *versions == "" {
+		return errors.New("no version specified")
+	}
+
+	day := time.Now()
+	if *date != "" {
+		var err error
+		if day, err = time.Parse(time.DateOnly, *date); err != nil {
+			return fmt.Errorf("failed to parse date: %w", err)
+		}
+	}
+
+	title, body := releaseDayIssueContent(strings.Split(*versions, ","), *runner, day)
+	log.Printf("Issue title: %v\n", title)
+	fmt.Println(body)
+	return nil
+}
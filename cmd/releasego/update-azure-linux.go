@@ -44,6 +44,9 @@ If the user already has a fork of the repo with a different name, fork creation
 example, if the fork was created before the cbl-mariner repository was renamed to azurelinux, it
 may still have the old name.
 
+Use -diff to preview the update: the command prints a unified diff of the changes to the spec,
+signatures, and cgmanifest files, then exits without creating a fork, branch, or PR.
+
 Note: the PAT must have "repo" scope, and if using a fork, it must also have "workflow" scope.
 Otherwise, GitHub will return "404" when attempting to update the fork if the upstream repo has
 modified any GitHub workflows.
@@ -68,6 +71,7 @@ func updateAzureLinux(p subcmd.ParseFunc) error {
 		latestMajor    bool
 		notify         string
 		security       bool
+		diff           bool
 	)
 	flag.StringVar(&buildAssetJSON, "build-asset-json", "assets.json", "The path of a build asset JSON file describing the Go build to update to.")
 	flag.StringVar(&upstream, "upstream", "microsoft", "The owner of the Azure Linux repository.")
@@ -78,6 +82,7 @@ func updateAzureLinux(p subcmd.ParseFunc) error {
 	flag.BoolVar(&latestMajor, "latest-major", false, "This is the latest major version, so update 'golang.spec' instead of 'golang-1.<N>.spec'.")
 	flag.StringVar(&notify, "notify", "", "A GitHub user to tag in the PR body and request that they finalize the PR, or empty. The value 'ghost' is also treated as empty.")
 	flag.BoolVar(&security, "security", false, "Whether to indicate in the PR title and description that this is a security release.")
+	flag.BoolVar(&diff, "diff", false, "Print a unified diff of the file changes instead of creating a branch and PR.")
 
 	pat := githubutil.BindPATFlag()
 
@@ -104,7 +109,7 @@ func updateAzureLinux(p subcmd.ParseFunc) error {
 		return fmt.Errorf("the PAT must have 'repo' scope, but not found in list: %v", patScopes)
 	}
 
-	if upstream != owner {
+	if upstream != owner && !diff {
 		// Submitting PR via fork. Try to make sure it'll work.
 		if !slices.Contains(patScopes, "workflow") {
 			return fmt.Errorf("the PAT must have 'workflow' scope, but not found in list: %v", patScopes)
@@ -179,6 +184,7 @@ func updateAzureLinux(p subcmd.ParseFunc) error {
 			return err
 		}
 
+		oldGolangSignaturesFileBytes := golangSignaturesFileBytes
 		golangSignaturesFileBytes, err = updateSignatureFile(golangSignaturesFileBytes, prevGoArchiveName, path.Base(assets.GoSrcURL), assets.GoSrcSHA256)
 		if err != nil {
 			return err
@@ -189,11 +195,19 @@ func updateAzureLinux(p subcmd.ParseFunc) error {
 			return err
 		}
 
+		oldCGManifestBytes := cgManifestBytes
 		cgManifestBytes, err = updateCGManifest(assets, cgManifestBytes)
 		if err != nil {
 			return err
 		}
 
+		if diff {
+			fmt.Print(stringutil.UnifiedDiff(specPath, golangSpecFileBytes, []byte(golangSpecFileContent)))
+			fmt.Print(stringutil.UnifiedDiff(signaturesPath, oldGolangSignaturesFileBytes, golangSignaturesFileBytes))
+			fmt.Print(stringutil.UnifiedDiff(cgManifestFilepath, oldCGManifestBytes, cgManifestBytes))
+			return nil
+		}
+
 		tree := []*github.TreeEntry{
 			{
 				Path:    github.String(specPath),
@@ -240,6 +254,10 @@ func updateAzureLinux(p subcmd.ParseFunc) error {
 		return err
 	}
 
+	if diff {
+		return nil
+	}
+
 	var pr *github.PullRequest
 
 	if err := githubutil.Retry(func() error {
@@ -363,6 +381,9 @@ func loadBuildAssets(assetFilePath string) (*buildassets.BuildAssets, error) {
 	if err := stringutil.ReadJSONFile(assetFilePath, assets); err != nil {
 		return nil, fmt.Errorf("error loading build assets: %w", err)
 	}
+	if err := assets.Validate(); err != nil {
+		return nil, err
+	}
 
 	return assets, nil
 }
@@ -410,16 +431,68 @@ func updateVersionInSpecFile(content string, newVersion string) string {
 
 var specFileReleaseRegex = regexp.MustCompile(`(Release: +)(.+)(%\{\?dist\})`)
 
-func extractReleaseFromSpecFile(content string) (int, error) {
+func extractReleaseFromSpecFile(content string) (*specRelease, error) {
 	matches := specFileReleaseRegex.FindStringSubmatch(content)
 	if matches == nil {
-		return 0, fmt.Errorf("no Release declaration found in spec content")
+		return nil, fmt.Errorf("no Release declaration found in spec content")
 	}
-	releaseInt, err := strconv.Atoi(matches[2])
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse old Release number: %v", err)
+	return parseSpecRelease(matches[2])
+}
+
+// specRelease is the value of a spec file's Release tag, without the "%{?dist}" suffix. It's a
+// plain integer like "3" or dot-separated integers like "1.1", optionally followed by macros like
+// "%{?with_check}" that are kept as-is.
+type specRelease struct {
+	// parts are the dot-separated integer components.
+	parts []int
+	// macros is the macro text following the numeric components, or empty.
+	macros string
+}
+
+var specReleaseRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)*)((?:%\{[^{}]*\})*)$`)
+
+// parseSpecRelease parses a Release tag value. Returns an error describing the supported formats
+// if s isn't one of them, for example if a macro comes before the number.
+func parseSpecRelease(s string) (*specRelease, error) {
+	s = strings.TrimSpace(s)
+	matches := specReleaseRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, fmt.Errorf(
+			"unsupported Release format %q in spec file: expected an integer like \"3\" or dot-separated integers like \"1.1\", "+
+				"optionally followed by macros like \"%%{?with_check}\", before \"%%{?dist}\". "+
+				"Update the spec file manually, or extend parseSpecRelease to support this format", s)
+	}
+	r := &specRelease{macros: matches[2]}
+	for _, part := range strings.Split(matches[1], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Release component %q in %q: %v", part, s, err)
+		}
+		r.parts = append(r.parts, n)
+	}
+	return r, nil
+}
+
+// String returns the Release tag value, without "%{?dist}".
+func (r *specRelease) String() string {
+	parts := make([]string, len(r.parts))
+	for i, p := range r.parts {
+		parts[i] = strconv.Itoa(p)
 	}
-	return releaseInt, nil
+	return strings.Join(parts, ".") + r.macros
+}
+
+// increment returns a release with the last numeric component incremented, for example "1.1" to
+// "1.2". macros are kept.
+func (r *specRelease) increment() *specRelease {
+	parts := slices.Clone(r.parts)
+	parts[len(parts)-1]++
+	return &specRelease{parts: parts, macros: r.macros}
+}
+
+// reset returns the first release of a new version: "1", keeping macros.
+func (r *specRelease) reset() *specRelease {
+	return &specRelease{parts: []int{1}, macros: r.macros}
 }
 
 func updateReleaseInSpecFile(content string, newRelease string) string {
@@ -624,22 +697,22 @@ func githubReleaseDownloadURL(assets *buildassets.BuildAssets) string {
 	)
 }
 
-func updateSpecVersion(assets *buildassets.BuildAssets, oldVersion *goversion.GoVersion, oldRelease int) (version, release string) {
+func updateSpecVersion(assets *buildassets.BuildAssets, oldVersion *goversion.GoVersion, oldRelease *specRelease) (version, release string) {
 	// Decide on the new Azure Linux golang package release number.
 	//
 	// We don't use assets.GoVersion().Revision because Azure Linux may have incremented the
 	// release version manually for an Azure-Linux-specific fix.
-	var newRelease int
+	var newRelease *specRelease
 	if assets.GoVersion().MajorMinorPatch() != oldVersion.MajorMinorPatch() {
 		// When updating to a new upstream Go version, reset release number to 1.
-		newRelease = 1
+		newRelease = oldRelease.reset()
 	} else {
 		// When the upstream Go version didn't change, increment the release number. This means
 		// there has been a patch specific to Microsoft Go.
-		newRelease = oldRelease + 1
+		newRelease = oldRelease.increment()
 	}
 
-	return assets.GoVersion().MajorMinorPatch(), strconv.Itoa(newRelease)
+	return assets.GoVersion().MajorMinorPatch(), newRelease.String()
 }
 
 // escapeRegexReplacementValue returns s where all "$" signs are replaced with with "$$" for the
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -96,7 +97,7 @@ func TestAzLUpdateSpecVersion(t *testing.T) {
 	type args struct {
 		newGoVersion string
 		oldVersion   string
-		oldRelease   int
+		oldRelease   string
 	}
 	tests := []struct {
 		name        string
@@ -106,39 +107,63 @@ func TestAzLUpdateSpecVersion(t *testing.T) {
 	}{
 		{
 			"patch",
-			args{"1.22.4-1", "1.22.3", 1},
+			args{"1.22.4-1", "1.22.3", "1"},
 			"1.22.4", "1",
 		},
 		{
 			"patch-modified-package",
-			args{"1.22.4-1", "1.22.3", 2},
+			args{"1.22.4-1", "1.22.3", "2"},
 			"1.22.4", "1",
 		},
 		{
 			"patch-msft-go-release",
-			args{"1.22.3-2", "1.22.3", 1},
+			args{"1.22.3-2", "1.22.3", "1"},
 			"1.22.3", "2",
 		},
 		{
 			"patch-msft-go-release-modified",
-			args{"1.22.3-2", "1.22.3", 4},
+			args{"1.22.3-2", "1.22.3", "4"},
 			"1.22.3", "5",
 		},
 		{
 			"go-major",
-			args{"1.23.0-1", "1.22.8", 1},
+			args{"1.23.0-1", "1.22.8", "1"},
 			"1.23.0", "1",
 		},
+		{
+			"decimal-release",
+			args{"1.22.3-2", "1.22.3", "1.1"},
+			"1.22.3", "1.2",
+		},
+		{
+			"decimal-release-new-version",
+			args{"1.22.4-1", "1.22.3", "1.1"},
+			"1.22.4", "1",
+		},
+		{
+			"macro-release",
+			args{"1.22.3-2", "1.22.3", "3%{?with_check}"},
+			"1.22.3", "4%{?with_check}",
+		},
+		{
+			"macro-release-new-version",
+			args{"1.22.4-1", "1.22.3", "3%{?with_check}"},
+			"1.22.4", "1%{?with_check}",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			assets := &buildassets.BuildAssets{
 				Version: tt.args.newGoVersion,
 			}
+			oldRelease, err := parseSpecRelease(tt.args.oldRelease)
+			if err != nil {
+				t.Fatal(err)
+			}
 			gotVersion, gotRelease := updateSpecVersion(
 				assets,
 				goversion.New(tt.args.oldVersion),
-				tt.args.oldRelease,
+				oldRelease,
 			)
 			if gotVersion != tt.wantVersion {
 				t.Errorf("updateSpecVersion() gotVersion = %v, want %v", gotVersion, tt.wantVersion)
@@ -150,6 +175,39 @@ func TestAzLUpdateSpecVersion(t *testing.T) {
 	}
 }
 
+func Test_parseSpecRelease(t *testing.T) {
+	tests := []struct {
+		release string
+		wantErr bool
+	}{
+		{"3", false},
+		{"1.1", false},
+		{"2%{?with_check}", false},
+		{"1.1%{?a}%{?b}", false},
+		{"%{release_prefix}1", true},
+		{"1.", true},
+		{"one", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			got, err := parseSpecRelease(tt.release)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "unsupported Release format") {
+					t.Errorf("parseSpecRelease() = %v, %v; want unsupported format error", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.release {
+				t.Errorf("parseSpecRelease().String() = %q, want %q", got.String(), tt.release)
+			}
+		})
+	}
+}
+
 func TestAzLPRBody(t *testing.T) {
 	assets, err := loadBuildAssets(assetsJsonPath)
 	if err != nil {
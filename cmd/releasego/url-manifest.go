@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/go-infra/buildmodel/buildassets"
+	"github.com/microsoft/go-infra/stringutil"
+	"github.com/microsoft/go-infra/subcmd"
+)
+
+func init() {
+	subcommands = append(subcommands, subcmd.Option{
+		Name:    "url-manifest",
+		Summary: "Write the list of every URL associated with a release to a file.",
+		Description: `
+
+Writes a sorted list of the URLs of every artifact, checksum, and signature in the given build asset
+JSON file, along with the URL of the build asset JSON file itself, one per line. These are the same
+URLs that the akams command creates links for. The list can be used to warm a CDN or seed a mirror.
+
+Example:
+
+  go run ./cmd/releasego url-manifest -build-asset-json /downloads/assets.json -o urls.txt
+`,
+		Handle: handleURLManifest,
+	})
+}
+
+func handleURLManifest(p subcmd.ParseFunc) error {
+	buildAssetJSON := flag.String("build-asset-json", "", "[Required] The path of a build asset JSON file describing the release.")
+	buildAssetJSONPublishManifest := flag.String(
+		"build-asset-json-publish-manifest", "",
+		"The path of a publish manifest describing where the build asset JSON file is available.\n"+
+			"If not specified, the build asset JSON URL is assumed to be next to the source archive.")
+	out := flag.String("o", "", "[Required] The path of the file to write the URL list to.")
+
+	if err := p(); err != nil {
+		return err
+	}
+
+	if *buildAssetJSON == "" {
+		flag.Usage()
+		log.Fatal("No build asset JSON specified.\n")
+	}
+	if *out == "" {
+		flag.Usage()
+		log.Fatal("No output file specified.\n")
+	}
+
+	var b buildassets.BuildAssets
+	if err := stringutil.ReadJSONFile(*buildAssetJSON, &b); err != nil {
+		return err
+	}
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	assetJSONUrl, err := readPublishedAssetJSONURL(*buildAssetJSONPublishManifest)
+	if err != nil {
+		return err
+	}
+
+	urls := releaseURLManifest(&b, assetJSONUrl)
+	log.Printf("Writing %v URLs to %v\n", len(urls), *out)
+	return os.WriteFile(*out, []byte(strings.Join(urls, "\n")+"\n"), 0o666)
+}
+
+// releaseURLManifest returns the sorted, deduplicated list of URLs associated with the release.
+func releaseURLManifest(assets *buildassets.BuildAssets, assetJSONUrl string) []string {
+	urls := appendReleaseURLs(nil, assets, assetJSONUrl)
+	sort.Strings(urls)
+	deduped := urls[:0]
+	for i, u := range urls {
+		if i > 0 && u == urls[i-1] {
+			continue
+		}
+		deduped = append(deduped, u)
+	}
+	return deduped
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-infra/buildmodel/buildassets"
+	"github.com/microsoft/go-infra/buildmodel/dockerversions"
+)
+
+func Test_releaseURLManifest(t *testing.T) {
+	tests := []struct {
+		name         string
+		assets       *buildassets.BuildAssets
+		assetJSONUrl string
+		want         []string
+	}{
+		{
+			"convention",
+			&buildassets.BuildAssets{
+				Arches: []*dockerversions.Arch{
+					{
+						Env: &dockerversions.ArchEnv{GOOS: "linux", GOARCH: "amd64"},
+						URL: "https://example.org/golang/1234.10/go.1234.10.linux-amd64.tar.gz",
+					},
+					{
+						Env: &dockerversions.ArchEnv{GOOS: "windows", GOARCH: "amd64"},
+						URL: "https://example.org/golang/1234.10/go.1234.10.windows-amd64.zip",
+					},
+				},
+				GoSrcURL: "https://example.org/golang/1234.10/go.1234.10.src.tar.gz",
+			},
+			"",
+			[]string{
+				"https://example.org/golang/1234.10/assets.json",
+				"https://example.org/golang/1234.10/go.1234.10.linux-amd64.tar.gz",
+				"https://example.org/golang/1234.10/go.1234.10.linux-amd64.tar.gz.sha256",
+				"https://example.org/golang/1234.10/go.1234.10.linux-amd64.tar.gz.sig",
+				"https://example.org/golang/1234.10/go.1234.10.src.tar.gz",
+				"https://example.org/golang/1234.10/go.1234.10.src.tar.gz.sha256",
+				"https://example.org/golang/1234.10/go.1234.10.src.tar.gz.sig",
+				"https://example.org/golang/1234.10/go.1234.10.windows-amd64.zip",
+				"https://example.org/golang/1234.10/go.1234.10.windows-amd64.zip.sha256",
+			},
+		},
+		{
+			"explicit",
+			&buildassets.BuildAssets{
+				Arches: []*dockerversions.Arch{
+					{
+						Env:               &dockerversions.ArchEnv{GOOS: "linux", GOARCH: "amd64"},
+						URL:               "https://example.org/b/go.linux-amd64.tar.gz",
+						SHA256ChecksumURL: "https://example.org/c/go.linux-amd64.tar.gz.sha256",
+						PGPSignatureURL:   "https://example.org/a/go.linux-amd64.tar.gz.sig",
+					},
+					{
+						URL:               "https://example.org/d/go.src.tar.gz",
+						SHA256ChecksumURL: "https://example.org/e/go.src.tar.gz.sha256",
+						PGPSignatureURL:   "https://example.org/f/go.src.tar.gz.sig",
+					},
+				},
+				GoSrcURL: "https://example.org/d/go.src.tar.gz",
+			},
+			"https://example.org/g/assets.json",
+			[]string{
+				"https://example.org/a/go.linux-amd64.tar.gz.sig",
+				"https://example.org/b/go.linux-amd64.tar.gz",
+				"https://example.org/c/go.linux-amd64.tar.gz.sha256",
+				"https://example.org/d/go.src.tar.gz",
+				"https://example.org/e/go.src.tar.gz.sha256",
+				"https://example.org/f/go.src.tar.gz.sig",
+				"https://example.org/g/assets.json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseURLManifest(tt.assets, tt.assetJSONUrl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("releaseURLManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
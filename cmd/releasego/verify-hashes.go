@@ -0,0 +1,133 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/go-infra/subcmd"
+)
+
+func init() {
+	subcommands = append(subcommands, subcmd.Option{
+		Name:    "verify-hashes",
+		Summary: "Verify the SHA256 checksum of each artifact in a directory against its .sha256 file.",
+		Description: `
+
+For every file in the given directory (recursively) that has a sibling file with the same name plus
+".sha256", computes the SHA256 checksum of the file and compares it to the content of the ".sha256"
+file. This is the same checksum file convention used to determine the URLs of a release.
+
+The ".sha256" file may contain only the hex checksum, or the checksum followed by the file name, as
+written by sha256sum. Exits nonzero if any checksum doesn't match, or if a ".sha256" file has no
+corresponding artifact.
+`,
+		Handle: handleVerifyHashes,
+	})
+}
+
+func handleVerifyHashes(p subcmd.ParseFunc) error {
+	dir := flag.String("dir", "", "[Required] The directory containing artifacts and their .sha256 files.")
+
+	if err := p(); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		flag.Usage()
+		log.Fatal("No dir specified.\n")
+	}
+
+	mismatches, err := verifyHashes(*dir)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		for _, m := range mismatches {
+			log.Printf("Mismatch: %v\n", m)
+		}
+		return fmt.Errorf("found %v checksum mismatches", len(mismatches))
+	}
+	log.Printf("All checksums match.\n")
+	return nil
+}
+
+// verifyHashes checks each ".sha256" file in dir against the file it describes. Returns a
+// description of each mismatch. Returns an error if the check couldn't be completed.
+func verifyHashes(dir string) ([]string, error) {
+	var mismatches []string
+	var checked int
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		artifactPath, ok := strings.CutSuffix(path, ".sha256")
+		if !ok {
+			return nil
+		}
+		want, err := readChecksumFile(path)
+		if err != nil {
+			return err
+		}
+		got, err := fileSHA256(artifactPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				mismatches = append(mismatches, fmt.Sprintf("%v: artifact not found", artifactPath))
+				return nil
+			}
+			return err
+		}
+		checked++
+		if !strings.EqualFold(got, want) {
+			mismatches = append(mismatches, fmt.Sprintf("%v: expected %v, got %v", artifactPath, want, got))
+			return nil
+		}
+		log.Printf("Verified %v\n", artifactPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Checked %v artifacts.\n", checked)
+	return mismatches, nil
+}
+
+// readChecksumFile reads the hex checksum from a ".sha256" file. Accepts only the checksum, or the
+// checksum followed by a file name.
+func readChecksumFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file %#q is empty", path)
+	}
+	return fields[0], nil
+}
+
+// fileSHA256 computes the hex SHA256 checksum of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// helloSHA256 is the SHA256 checksum of "hello".
+const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func Test_verifyHashes(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          map[string]string
+		wantMismatches int
+	}{
+		{
+			"match",
+			map[string]string{
+				"go.tar.gz":        "hello",
+				"go.tar.gz.sha256": helloSHA256 + "\n",
+			},
+			0,
+		},
+		{
+			"match sha256sum format",
+			map[string]string{
+				"sub/go.zip":        "hello",
+				"sub/go.zip.sha256": helloSHA256 + "  go.zip\n",
+			},
+			0,
+		},
+		{
+			"mismatch",
+			map[string]string{
+				"go.tar.gz":        "goodbye",
+				"go.tar.gz.sha256": helloSHA256,
+				"go.zip":           "hello",
+				"go.zip.sha256":    helloSHA256,
+			},
+			1,
+		},
+		{
+			"missing artifact",
+			map[string]string{
+				"go.tar.gz.sha256": helloSHA256,
+			},
+			1,
+		},
+		{
+			"no checksum file",
+			map[string]string{
+				"go.tar.gz": "hello",
+			},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := verifyHashes(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.wantMismatches {
+				t.Errorf("verifyHashes() = %v, want %v mismatches", got, tt.wantMismatches)
+			}
+		})
+	}
+}
@@ -51,24 +51,8 @@ func waitMarGoVersion(p subcmd.ParseFunc) error {
 
 	var checkers []func() (bool, error)
 	for _, version := range strings.Split(*versionList, ",") {
-		v := goversion.New(version)
-		tag := marRepo + ":" + v.MajorMinor()
-
-		expect := "go" + v.MajorMinorPatchPrerelease() + " "
-
 		checkers = append(checkers, func() (bool, error) {
-			pullCmd := exec.Command("docker", "pull", tag)
-			if err := executil.Run(pullCmd); err != nil {
-				return false, err
-			}
-			versionCmd := exec.Command("docker", "run", "--rm", tag, "go", "version")
-			out, err := executil.SpaceTrimmedCombinedOutput(versionCmd)
-			if err != nil {
-				return false, err
-			}
-			found := strings.Contains(out, expect)
-			log.Printf("Finding %q in %q: %v\n", expect, out, found)
-			return found, nil
+			return checkMARGoVersion(version)
 		})
 	}
 
@@ -104,3 +88,29 @@ func waitMarGoVersion(p subcmd.ParseFunc) error {
 	}
 	return fmt.Errorf("exceeded timeout (%v) waiting for versions", *timeout)
 }
+
+// marGoVersionTag returns the MAR image tag that should contain the latest build of the given
+// version's major.minor release.
+func marGoVersionTag(version string) string {
+	return marRepo + ":" + goversion.New(version).MajorMinor()
+}
+
+// checkMARGoVersion pulls the MAR image tag for the given version and probes it using
+// "docker run ... go version" to see if it contains the expected major+minor+patch Go version.
+func checkMARGoVersion(version string) (bool, error) {
+	tag := marGoVersionTag(version)
+	expect := "go" + goversion.New(version).MajorMinorPatchPrerelease() + " "
+
+	pullCmd := exec.Command("docker", "pull", tag)
+	if err := executil.Run(pullCmd); err != nil {
+		return false, err
+	}
+	versionCmd := exec.Command("docker", "run", "--rm", tag, "go", "version")
+	out, err := executil.SpaceTrimmedCombinedOutput(versionCmd)
+	if err != nil {
+		return false, err
+	}
+	found := strings.Contains(out, expect)
+	log.Printf("Finding %q in %q: %v\n", expect, out, found)
+	return found, nil
+}
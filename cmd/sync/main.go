@@ -4,6 +4,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -33,6 +34,11 @@ This script creates the temporary repository in 'eng/artifacts/' by default.
 
 To run a subset of the syncs specified in the config file, or to swap out URLs for development
 purposes, create a copy of the configuration file and point at it using a '-c' argument.
+
+To preview the commit message of a submodule update to an upstream commit that has already been
+fetched, without syncing:
+
+  go run ./cmd/sync -preview-submodule-message origin/master -preview-repo ../go
 `
 
 func main() {
@@ -41,9 +47,24 @@ func main() {
 		log.Panic(err)
 	}
 	f := sync.BindFlags(wd)
+	previewRef := flag.String(
+		"preview-submodule-message", "",
+		"Print the commit message sync would create to update a submodule to this upstream ref, then exit without syncing.\n"+
+			"The ref must already be fetched into the Git repo at 'preview-repo'.")
+	previewRepo := flag.String("preview-repo", ".", "The Git repo to find the 'preview-submodule-message' ref in.")
+	previewBranch := flag.String("preview-upstream-branch", "", "The upstream branch name to show in the previewed message. Defaults to the ref.")
 
 	buildmodel.ParseBoundFlags(description)
 
+	if *previewRef != "" {
+		message, err := sync.PreviewSubmoduleCommitMessage(*previewRepo, *previewBranch, *previewRef)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(message)
+		return
+	}
+
 	if err := sync.MakePRs(f); err != nil {
 		panic(err)
 	}
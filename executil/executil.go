@@ -5,6 +5,7 @@
 package executil
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
@@ -35,6 +36,53 @@ func CombinedOutput(c *exec.Cmd) (string, error) {
 	return string(out), nil
 }
 
+// LimitedCombinedOutput runs a command and returns its combined output, even if the command fails.
+// If the output is longer than limit bytes, keeps only the first and last limit/2 bytes, joined by
+// a "[truncated]" marker, and returns truncated true. This is useful to include a command's output
+// in an error message or PR body without risking a huge result.
+func LimitedCombinedOutput(c *exec.Cmd, limit int) (out string, truncated bool, err error) {
+	fmt.Printf("---- Running command: %v %v\n", c.Path, c.Args)
+	b := &limitedBuffer{
+		headLimit: limit / 2,
+		tailLimit: limit - limit/2,
+	}
+	c.Stdout = b
+	c.Stderr = b
+	err = c.Run()
+	return b.String(), b.dropped > 0, err
+}
+
+// limitedBuffer is an io.Writer that keeps the first headLimit and last tailLimit bytes written to
+// it and counts the bytes it drops in between.
+type limitedBuffer struct {
+	headLimit, tailLimit int
+
+	head, tail bytes.Buffer
+	dropped    int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.headLimit - b.head.Len(); room > 0 {
+		room = min(room, len(p))
+		b.head.Write(p[:room])
+		p = p[room:]
+	}
+	b.tail.Write(p)
+	if over := b.tail.Len() - b.tailLimit; over > 0 {
+		b.tail.Next(over)
+		b.dropped += over
+	}
+	return n, nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.dropped == 0 {
+		return b.head.String() + b.tail.String()
+	}
+	return fmt.Sprintf("%s\n[truncated %d bytes]\n%s", b.head.String(), b.dropped, b.tail.String())
+}
+
 // SpaceTrimmedCombinedOutput runs CombinedOutput and trims leading/trailing spaces from the result.
 func SpaceTrimmedCombinedOutput(c *exec.Cmd) (string, error) {
 	out, err := CombinedOutput(c)
@@ -55,13 +103,17 @@ func Dir(dir, name string, args ...string) *exec.Cmd {
 }
 
 // MakeWorkDir creates a unique path inside the given root dir to use as a workspace. The name
-// starts with the local time in a sortable format to help with browsing multiple workspaces. This
-// function allows a command to run multiple times in sequence without overwriting or deleting the
-// old data, for diagnostic purposes. This function uses os.MkdirAll to ensure the root dir exists.
+// starts with the local time in a sortable format to help with browsing multiple workspaces,
+// followed by the process ID to help correlate logs with workspaces. This function allows a command
+// to run multiple times in sequence without overwriting or deleting the old data, for diagnostic
+// purposes. This function uses os.MkdirAll to ensure the root dir exists.
+//
+// MakeWorkDir is safe to call concurrently: os.MkdirTemp adds a random suffix and retries if the
+// dir already exists, so calls in the same second by the same process get different dirs.
 func MakeWorkDir(rootDir string) (string, error) {
 	pathDate := time.Now().Format("2006-01-02_15-04-05")
 	if err := os.MkdirAll(rootDir, os.ModePerm); err != nil {
 		return "", err
 	}
-	return os.MkdirTemp(rootDir, fmt.Sprintf("%s_*", pathDate))
+	return os.MkdirTemp(rootDir, fmt.Sprintf("%s_pid%d_*", pathDate, os.Getpid()))
 }
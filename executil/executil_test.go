@@ -4,7 +4,9 @@
 package executil
 
 import (
+	"os/exec"
 	"path"
+	"sync"
 	"testing"
 )
 
@@ -26,3 +28,61 @@ func TestMakeWorkDir(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeWorkDirConcurrent(t *testing.T) {
+	rootDir := t.TempDir()
+	const n = 50
+	dirs := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dirs[i], errs[i] = MakeWorkDir(rootDir)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if _, ok := seen[dirs[i]]; ok {
+			t.Errorf("MakeWorkDir returned %v more than once", dirs[i])
+		}
+		seen[dirs[i]] = struct{}{}
+	}
+}
+
+func TestLimitedCombinedOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	tests := []struct {
+		name          string
+		script        string
+		limit         int
+		want          string
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{"short", "printf abc", 10, "abc", false, false},
+		{"exact", "printf 0123456789", 10, "0123456789", false, false},
+		{"long", "printf 0123456789abcdef", 10, "01234\n[truncated 6 bytes]\nbcdef", true, false},
+		{"stderr", "printf out; printf err >&2", 10, "outerr", false, false},
+		{"failed", "printf 0123456789abcdef; exit 1", 4, "01\n[truncated 12 bytes]\nef", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, truncated, err := LimitedCombinedOutput(exec.Command("sh", "-c", tt.script), tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LimitedCombinedOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out != tt.want || truncated != tt.wantTruncated {
+				t.Errorf("LimitedCombinedOutput() = %q, %v; want %q, %v", out, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
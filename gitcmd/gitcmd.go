@@ -153,6 +153,24 @@ func FetchRefCommit(dir, remote, ref string) (string, error) {
 	return fields[2], nil
 }
 
+// RemoteRefExists runs "git ls-remote" in dir to check whether ref (e.g. "refs/heads/main") exists
+// in the remote repository at url. If auther is not nil, it is used to add auth to url.
+func RemoteRefExists(dir, url, ref string, auther URLAuther) (bool, error) {
+	if auther != nil {
+		url = auther.InsertAuth(url)
+	}
+	err := executil.Run(executil.Dir(dir, "git", "ls-remote", "--exit-code", url, ref))
+	if err != nil {
+		// https://git-scm.com/docs/git-ls-remote#Documentation/git-ls-remote.txt---exit-code
+		// Exit code 2 means no matching refs were found. Other codes are real errors.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // Run runs "git <args>" in the given directory, showing the command to the user in logs for
 // diagnosability. Using this func helps make one-line Git commands readable.
 func Run(dir string, args ...string) error {
@@ -172,6 +190,40 @@ func NewTempGitRepo() (string, error) {
 	return gitDir, nil
 }
 
+// EnsureBareRepo initializes a bare repo at bareDir if bareDir doesn't exist. This lets a caller
+// keep a bare repo between runs and fetch into it, rather than cloning each time.
+func EnsureBareRepo(bareDir string) error {
+	if _, err := os.Stat(bareDir); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	return executil.Run(exec.Command("git", "init", "--bare", bareDir))
+}
+
+// NewWorktree creates a detached worktree of the bare repo at bareDir, checked out at ref, in a new
+// temp dir. The caller must fetch ref into bareDir first. Call cleanup to delete the worktree and
+// prune it from the bare repo. Along with EnsureBareRepo, this is a faster alternative to
+// NewTempGitRepo for callers that check out the same remote frequently.
+func NewWorktree(bareDir, ref string) (dir string, cleanup func(), err error) {
+	dir, err = os.MkdirTemp("", "worktree-*")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := Run(bareDir, "worktree", "add", "--detach", dir, ref); err != nil {
+		AttemptDelete(dir)
+		return "", nil, err
+	}
+	cleanup = func() {
+		if err := Run(bareDir, "worktree", "remove", "--force", dir); err != nil {
+			log.Printf("Unable to remove worktree %#q: %v\n", dir, err)
+			AttemptDelete(dir)
+		}
+		if err := Run(bareDir, "worktree", "prune"); err != nil {
+			log.Printf("Unable to prune worktrees of %#q: %v\n", bareDir, err)
+		}
+	}
+	return dir, cleanup, nil
+}
+
 func NewTempCloneRepo(src string) (string, error) {
 	absSrc, err := filepath.Abs(src)
 	if err != nil {
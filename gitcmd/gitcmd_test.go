@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package gitcmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteRefExists(t *testing.T) {
+	remote := t.TempDir()
+	if err := Run(remote, "init", "-b", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remote, "README.md"), []byte("Hello"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(remote, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(remote, "commit", "-m", "Initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	if err := Run(local, "init"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		ref  string
+		want bool
+	}{
+		{"exists", remote, "refs/heads/main", true},
+		{"missing", remote, "refs/heads/release-branch.go1.18", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemoteRefExists(local, tt.url, tt.ref, NoAuther{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RemoteRefExists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing remote", func(t *testing.T) {
+		if _, err := RemoteRefExists(local, filepath.Join(t.TempDir(), "nonexistent"), "refs/heads/main", nil); err == nil {
+			t.Error("expected error for nonexistent remote, got nil")
+		}
+	})
+}
+
+func TestNewWorktree(t *testing.T) {
+	remote := t.TempDir()
+	if err := Run(remote, "init", "-b", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remote, "README.md"), []byte("Hello"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(remote, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(remote, "commit", "-m", "Initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	bareDir := filepath.Join(t.TempDir(), "bare")
+	// Create a worktree twice to check that an existing bare repo is reused.
+	for i := 0; i < 2; i++ {
+		if err := EnsureBareRepo(bareDir); err != nil {
+			t.Fatal(err)
+		}
+		if err := Run(bareDir, "fetch", remote, "main:main"); err != nil {
+			t.Fatal(err)
+		}
+		dir, cleanup, err := NewWorktree(bareDir, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, "README.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "Hello" {
+			t.Errorf("README.md content = %q, want %q", content, "Hello")
+		}
+		cleanup()
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected worktree dir to be deleted, got %v", err)
+		}
+		out, err := CombinedOutput(bareDir, "worktree", "list", "--porcelain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(out, dir) {
+			t.Errorf("expected worktree to be pruned, got list:\n%v", out)
+		}
+	}
+}
@@ -5,18 +5,154 @@ package gitpr
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var client = http.Client{
-	Timeout: time.Second * 30,
+// logger receives the log messages of this package. Full request and response bodies are logged at
+// debug level, and a summary of each request at info level.
+var logger = NewLogger(slog.LevelDebug)
+
+// SetLogger sets the logger that receives the log messages of this package. Use a logger with a
+// higher minimum level to reduce verbosity, or a handler with ReplaceAttr to redact content. PATs
+// are only sent in request headers, which are never logged.
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// NewLogger creates a logger that writes messages with at least the given level to stdout. The
+// default logger is NewLogger(slog.LevelDebug), which logs everything.
+func NewLogger(level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Keep the output focused on the message. CI logs already include the time.
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// DefaultAPIURL is the base URL of the public GitHub API.
+const DefaultAPIURL = "https://api.github.com"
+
+// DefaultHTTPTimeout is the HTTP request timeout NewClient uses.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// Client sends requests to a GitHub API. The zero value is not usable: use NewClient or
+// DefaultClient.
+type Client struct {
+	// BaseURL is the base URL of the GitHub REST API, without a trailing slash. For example,
+	// "https://api.github.com" or, for GitHub Enterprise Server, "https://github.example.com/api/v3".
+	BaseURL string
+	// HTTPClient sends the requests. NewClient uses DefaultHTTPTimeout and the default transport.
+	// Set its Timeout to allow more time for large GraphQL responses, or its Transport to send
+	// requests somewhere other than the network, such as a test double.
+	HTTPClient *http.Client
+	// RateLimitRetries is the number of times to retry a GraphQL request that GitHub rejected
+	// because of a rate limit. RateLimitRetryDelay is the delay before the first retry, and it
+	// doubles with each retry after that.
+	RateLimitRetries    int
+	RateLimitRetryDelay time.Duration
+	// Retry makes the client retry requests that failed for a reason that is likely to be
+	// temporary. If nil (default), only GraphQL rate limit errors are retried, according to
+	// RateLimitRetries, and other failures are returned to the caller right away.
+	Retry *RetryPolicy
+	// DisableUsernameCache makes GetUsername query GitHub every time it's called rather than
+	// reusing the username it found for the same PAT earlier.
+	DisableUsernameCache bool
+	// DisableETagCache makes GET requests always fetch a full response. By default, the client
+	// remembers the ETag and body of each GET response and sends a conditional request the next
+	// time the same URL is requested with the same credentials. GitHub responds with 304 Not
+	// Modified if the resource hasn't changed, which doesn't count against the rate limit. This
+	// makes polling a resource that rarely changes much cheaper.
+	DisableETagCache bool
+	// MergeablePollDelay is the delay between checks of a PR's mergeable state in
+	// WaitForMergeableState.
+	MergeablePollDelay time.Duration
+
+	// usernames maps the SHA256 hash of a PAT to the username GitHub returned for it.
+	usernames sync.Map
+	// etags maps a GET request's URL and the SHA256 hash of its credentials to an etagResponse.
+	etags sync.Map
+}
+
+// etagResponse is a cached GET response body and the ETag GitHub sent with it.
+type etagResponse struct {
+	etag string
+	body []byte
+}
+
+// RetryPolicy configures how a Client retries requests that fail because of a secondary rate limit
+// ([ErrSecondaryRateLimit]), a GraphQL rate limit ([ErrGraphQLRateLimited]), or a server error
+// (HTTP 5xx). If GitHub sends a Retry-After header, the client waits that long. Otherwise, the
+// delay starts at InitialDelay and doubles with each retry.
+type RetryPolicy struct {
+	// MaxRetries is the number of times to retry a request after the first attempt.
+	MaxRetries   int
+	InitialDelay time.Duration
+	// MaxDelay caps the delay before each retry, including one requested by Retry-After. Zero
+	// means no cap.
+	MaxDelay time.Duration
+}
+
+// delay returns how long to wait before the given retry, starting from 0. If retryAfter is
+// positive, it's the delay GitHub asked for.
+func (p *RetryPolicy) delay(retry int, retryAfter time.Duration) time.Duration {
+	d := retryAfter
+	if d <= 0 {
+		d = p.InitialDelay << retry
+	}
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d < 0) {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// DefaultClient sends requests to the public GitHub API. The package-level functions use it.
+var DefaultClient = NewClient(DefaultAPIURL)
+
+// NewClient creates a client that sends requests to the GitHub API at baseURL. If baseURL is empty
+// string, uses DefaultAPIURL.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{
+			Timeout: DefaultHTTPTimeout,
+		},
+		RateLimitRetries:    3,
+		RateLimitRetryDelay: 10 * time.Second,
+		MergeablePollDelay:  5 * time.Second,
+	}
+}
+
+// GraphQLURL returns the URL of the GraphQL API that corresponds to the REST API at BaseURL. GitHub
+// Enterprise Server hosts the REST API at "/api/v3" and the GraphQL API at "/api/graphql", while
+// the public API hosts GraphQL at "/graphql".
+func (c *Client) GraphQLURL() string {
+	if base, ok := strings.CutSuffix(c.BaseURL, "/api/v3"); ok {
+		return base + "/api/graphql"
+	}
+	return c.BaseURL + "/graphql"
 }
 
 // ErrPRAlreadyExists is returned when a PR already exists for the given branch.
@@ -37,6 +173,31 @@ func (b PRRefSet) PRBranch() string {
 	return "dev/" + b.Purpose + "/" + b.Name
 }
 
+// CheckBranchName returns an error if name isn't a valid Git branch name, following the rules of
+// "git check-ref-format --branch". This lets callers reject a bad name before running Git.
+func CheckBranchName(name string) error {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	if strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return fmt.Errorf("invalid branch name %q: must not end with '/' or '.'", name)
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return fmt.Errorf("invalid branch name %q: must not contain '..' or '@{'", name)
+	}
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r)
+	}); i >= 0 {
+		return fmt.Errorf("invalid branch name %q: must not contain %q", name, name[i])
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return fmt.Errorf("invalid branch name %q: component %q is empty, starts with '.', or ends with '.lock'", name, part)
+		}
+	}
+	return nil
+}
+
 // BaseBranchFetchRefspec is the refspec with src: PR base branch src, dst: PR head branch dst. This
 // can be used with "fetch" to create a fresh dev branch.
 func (b PRRefSet) BaseBranchFetchRefspec() string {
@@ -48,6 +209,12 @@ func (b PRRefSet) PRBranchRefspec() string {
 	return createRefspec(b.PRBranch(), b.PRBranch())
 }
 
+// BaseBranchPushRefspec is the refspec with src: PR head branch, dst: PR base branch. This can be
+// used with "push" to update the base branch directly, without a PR.
+func (b PRRefSet) BaseBranchPushRefspec() string {
+	return createRefspec(b.PRBranch(), b.Name)
+}
+
 // CreateGitHubPR creates the data model that can be sent to GitHub to create a PR for this branch.
 func (b PRRefSet) CreateGitHubPR(headOwner, title, body string) *GitHubRequest {
 	return &GitHubRequest{
@@ -141,31 +308,60 @@ func (b MirrorRefSet) UpstreamMirrorRefspec() string {
 
 // Remote is a parsed version of a Git Remote. It helps determine how to send a GitHub PR.
 type Remote struct {
-	url      string
-	urlParts []string
+	url   string
+	owner string
+	repo  string
 }
 
 // ParseRemoteURL takes the URL ("https://github.com/microsoft/go", "git@github.com:microsoft/go")
-// and grabs the owner ("microsoft") and repository name ("go"). This assumes the URL follows one of
-// these two patterns, or something that's compatible. Returns an initialized 'Remote'.
-func ParseRemoteURL(url string) (*Remote, error) {
-	r := &Remote{
-		url,
-		strings.FieldsFunc(url, func(r rune) bool { return r == '/' || r == ':' }),
-	}
-	if len(r.urlParts) < 3 {
+// and grabs the owner ("microsoft") and repository name ("go"). URLs with a scheme, such as
+// "ssh://git@github.com:22/microsoft/go", are parsed with net/url, so the host and port are never
+// mistaken for part of the path. An scp-like URL ("git@github.com:microsoft/go") is split at the
+// first ':'. Anything else is treated as a local path. The owner and repository are the last two
+// segments of the path, and a trailing ".git" is removed from the repository name. Returns an
+// initialized 'Remote'.
+func ParseRemoteURL(rawURL string) (*Remote, error) {
+	r := &Remote{url: rawURL}
+	p, err := remoteURLPath(rawURL)
+	if err != nil {
+		return r, err
+	}
+	segments := strings.FieldsFunc(p, func(r rune) bool { return r == '/' })
+	if len(segments) < 2 {
 		return r, fmt.Errorf(
-			"failed to find 3 parts of Remote url '%v'. Found '%v'. Expected a string separated with '/' or ':', like https://github.com/microsoft/go or git@github.com:microsoft/go",
-			r.url,
-			r.urlParts,
+			"failed to find owner and repository in the path of Remote url '%v'. Found '%v'. Expected a URL like https://github.com/microsoft/go or git@github.com:microsoft/go",
+			rawURL,
+			segments,
 		)
 	}
-	fmt.Printf("From repo URL %v, detected %v for the PR target.\n", url, r.urlParts)
+	r.owner = segments[len(segments)-2]
+	r.repo = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	if r.repo == "" {
+		return r, fmt.Errorf("failed to find repository name in Remote url '%v'", rawURL)
+	}
+	logger.Info("Detected parts of repo URL for the PR target", "url", rawURL, "owner", r.owner, "repo", r.repo)
 	return r, nil
 }
 
+// remoteURLPath returns the path part of a Git remote URL, without the scheme, user, host, or port.
+func remoteURLPath(rawURL string) (string, error) {
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse Remote url '%v': %w", rawURL, err)
+		}
+		return u.Path, nil
+	}
+	// Git treats "[user@]host:path" as an scp-like URL if there's no '/' before the first ':'. A
+	// single letter before the ':' is a Windows drive letter instead.
+	if before, after, found := strings.Cut(rawURL, ":"); found && len(before) > 1 && !strings.ContainsAny(before, `/\`) {
+		return after, nil
+	}
+	return strings.ReplaceAll(rawURL, `\`, "/"), nil
+}
+
 func (r Remote) GetOwnerRepo() []string {
-	return r.urlParts[len(r.urlParts)-2:]
+	return []string{r.owner, r.repo}
 }
 
 func (r Remote) GetOwner() string {
@@ -180,9 +376,24 @@ func (r Remote) GetOwnerSlashRepo() string {
 	return strings.Join(r.GetOwnerRepo(), "/")
 }
 
-// GetUsername queries GitHub for the username associated with a PAT.
+// GetUsername queries GitHub for the username associated with a PAT using DefaultClient.
 func GetUsername(pat string) string {
-	request, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	return DefaultClient.GetUsername(pat)
+}
+
+// GetUsername queries GitHub for the username associated with a PAT. The result is cached per PAT
+// for the lifetime of c, unless DisableUsernameCache is set.
+func (c *Client) GetUsername(pat string) string {
+	// Key the cache by a hash so the PAT itself isn't kept around any longer than necessary.
+	patHash := sha256.Sum256([]byte(pat))
+	key := hex.EncodeToString(patHash[:])
+	if !c.DisableUsernameCache {
+		if username, ok := c.usernames.Load(key); ok {
+			return username.(string)
+		}
+	}
+
+	request, err := http.NewRequest("GET", c.BaseURL+"/user", nil)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -192,48 +403,227 @@ func GetUsername(pat string) string {
 		Login string `json:"login"`
 	}{}
 
-	if err := sendJSONRequestSuccessful(request, response); err != nil {
+	if err := c.sendJSONRequestSuccessful(request, response); err != nil {
 		log.Panic(err)
 	}
 
+	c.usernames.Store(key, response.Login)
 	return response.Login
 }
 
+// CheckTokenScopes checks the scopes of a PAT using DefaultClient. See [Client.CheckTokenScopes].
+func CheckTokenScopes(required []string, pat string) error {
+	return DefaultClient.CheckTokenScopes(required, pat)
+}
+
+// ErrMissingTokenScopes is returned (wrapped) by CheckTokenScopes if the token lacks a required
+// scope.
+var ErrMissingTokenScopes = errors.New("token is missing required scopes")
+
+// impliedScopes maps a classic OAuth scope to the narrower scopes it includes. GitHub only lists
+// the scopes that were granted, so a token with "repo" doesn't also list "public_repo".
+var impliedScopes = map[string][]string{
+	"repo":             {"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"},
+	"admin:org":        {"write:org", "read:org"},
+	"write:org":        {"read:org"},
+	"admin:public_key": {"write:public_key", "read:public_key"},
+	"write:public_key": {"read:public_key"},
+	"admin:repo_hook":  {"write:repo_hook", "read:repo_hook"},
+	"write:repo_hook":  {"read:repo_hook"},
+	"admin:gpg_key":    {"write:gpg_key", "read:gpg_key"},
+	"write:gpg_key":    {"read:gpg_key"},
+	"user":             {"read:user", "user:email", "user:follow"},
+	"write:packages":   {"read:packages"},
+}
+
+// CheckTokenScopes makes a lightweight API call with pat and checks the classic OAuth scopes GitHub
+// reports for it in the X-OAuth-Scopes response header. Returns an error wrapping
+// [ErrMissingTokenScopes] that lists each required scope the token doesn't have. Call this before
+// starting work that needs the scopes, to fail early with a clear message rather than later with a
+// 403 or 404.
+//
+// Fine-grained PATs and GitHub App tokens don't have classic scopes, and GitHub doesn't send the
+// header for them. Their permissions can't be checked this way, so CheckTokenScopes logs a message
+// and returns nil.
+func (c *Client) CheckTokenScopes(required []string, pat string) error {
+	// The rate_limit endpoint doesn't count against the rate limit.
+	request, err := http.NewRequest("GET", c.BaseURL+"/rate_limit", nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Accept", "application/vnd.github.v3+json")
+	request.SetBasicAuth("", pat)
+	logger.Info("Sending request", "method", request.Method, "url", request.URL.String())
+
+	httpResponse, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	if _, err := io.Copy(io.Discard, httpResponse.Body); err != nil {
+		return err
+	}
+	if status := httpResponse.StatusCode; status < 200 || status > 299 {
+		return fmt.Errorf("unable to check token scopes, http status %v, %v", status, http.StatusText(status))
+	}
+
+	header, ok := httpResponse.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	if !ok {
+		logger.Info("Token doesn't report OAuth scopes, so it may be a fine-grained PAT or GitHub App token. Not checking scopes.", "required", required)
+		return nil
+	}
+	granted := make(map[string]bool)
+	var grant func(scope string)
+	grant = func(scope string) {
+		granted[scope] = true
+		for _, implied := range impliedScopes[scope] {
+			grant(implied)
+		}
+	}
+	for _, value := range header {
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				grant(scope)
+			}
+		}
+	}
+	var missing []string
+	for _, scope := range required {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %v. Token scopes: %q", ErrMissingTokenScopes, strings.Join(missing, ", "), strings.Join(header, ", "))
+	}
+	return nil
+}
+
 // sendJSONRequest sends a request for JSON information. The JSON response is unmarshalled (parsed)
 // into the 'response' parameter, based on the structure of 'response'.
-func sendJSONRequest(request *http.Request, response interface{}) (status int, err error) {
+//
+// GET requests are conditional if an earlier response for the same URL and credentials had an
+// ETag. If GitHub responds 304 Not Modified, the cached body is used and the status is 200 OK, so
+// callers don't need to handle caching. See DisableETagCache.
+//
+// If c.Retry is set, retries the request if it fails because of a secondary rate limit or a server
+// error. The request must have a nil Body or a GetBody func so it can be sent again.
+func (c *Client) sendJSONRequest(request *http.Request, response interface{}) (status int, err error) {
 	request.Header.Add("Accept", "application/vnd.github.v3+json")
-	fmt.Printf("Sending request: %v %v\n", request.Method, request.URL)
+	for retry := 0; ; retry++ {
+		var retryAfter time.Duration
+		status, retryAfter, err = c.sendJSONRequestOnce(request, response)
+		if c.Retry == nil || retry >= c.Retry.MaxRetries || !isRetryable(status, err) {
+			return status, err
+		}
+		if request.Body != nil {
+			if request.GetBody == nil {
+				return status, err
+			}
+			if request.Body, err = request.GetBody(); err != nil {
+				return status, err
+			}
+		}
+		delay := c.Retry.delay(retry, retryAfter)
+		logger.Info("Request failed. Retrying.", "status", status, "delay", delay, "attempt", retry+1, "error", err)
+		time.Sleep(delay)
+	}
+}
+
+// ErrSecondaryRateLimit is returned (wrapped) when GitHub rejects a request because the client has
+// exceeded a secondary rate limit, for example by sending too many requests concurrently.
+var ErrSecondaryRateLimit = errors.New("GitHub secondary rate limit exceeded")
+
+// isRetryable returns true if a request that resulted in status and err may succeed if sent again.
+func isRetryable(status int, err error) bool {
+	return errors.Is(err, ErrSecondaryRateLimit) ||
+		status == http.StatusTooManyRequests ||
+		status >= 500 && status <= 599
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or
+// an HTTP date. Returns zero if the value is missing or not valid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
 
-	httpResponse, err := client.Do(request)
+// sendJSONRequestOnce sends the request once for sendJSONRequest. Also returns the delay GitHub
+// asked for in the Retry-After header, if any.
+func (c *Client) sendJSONRequestOnce(request *http.Request, response interface{}) (status int, retryAfter time.Duration, err error) {
+	logger.Info("Sending request", "method", request.Method, "url", request.URL.String())
+
+	var cacheKey string
+	var cached *etagResponse
+	if request.Method == http.MethodGet && !c.DisableETagCache {
+		// Include the credentials in the key: the same URL may return different content for
+		// different users. Hash them so they aren't kept around any longer than necessary.
+		authHash := sha256.Sum256([]byte(request.Header.Get("Authorization")))
+		cacheKey = request.URL.String() + " " + hex.EncodeToString(authHash[:])
+		if v, ok := c.etags.Load(cacheKey); ok {
+			cached = v.(*etagResponse)
+			request.Header.Set("If-None-Match", cached.etag)
+		}
+	}
+
+	httpResponse, err := c.HTTPClient.Do(request)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	defer httpResponse.Body.Close()
 	status = httpResponse.StatusCode
+	retryAfter = parseRetryAfter(httpResponse.Header.Get("Retry-After"), time.Now())
 
 	for key, value := range httpResponse.Header {
 		if strings.HasPrefix(key, "X-Ratelimit-") {
-			fmt.Printf("%v : %v\n", key, value)
+			logger.Debug("Rate limit", "header", key, "value", value)
 		}
 	}
 
-	jsonBytes, err := io.ReadAll(httpResponse.Body)
-	if err != nil {
-		return status, err
+	var jsonBytes []byte
+	if status == http.StatusNotModified && cached != nil {
+		logger.Info("Not modified, using cached response", "url", request.URL.String())
+		status = http.StatusOK
+		jsonBytes = cached.body
+	} else {
+		jsonBytes, err = io.ReadAll(httpResponse.Body)
+		if err != nil {
+			return status, retryAfter, err
+		}
+		if cacheKey != "" && status == http.StatusOK {
+			if etag := httpResponse.Header.Get("ETag"); etag != "" {
+				c.etags.Store(cacheKey, &etagResponse{etag: etag, body: jsonBytes})
+			}
+		}
 	}
 
-	fmt.Printf("---- Full response:\n%v\n", string(jsonBytes))
-	fmt.Printf("----\n")
+	logger.Debug("Full response", "status", status, "body", string(jsonBytes))
+
+	if status == http.StatusForbidden || status == http.StatusTooManyRequests {
+		var errorResponse struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(jsonBytes, &errorResponse) == nil && strings.Contains(errorResponse.Message, "secondary rate limit") {
+			return status, retryAfter, fmt.Errorf("%w: http status %v: %v", ErrSecondaryRateLimit, status, errorResponse.Message)
+		}
+	}
 
 	err = json.Unmarshal(jsonBytes, response)
-	return status, err
+	return status, retryAfter, err
 }
 
 // sendJSONRequestSuccessful sends a request for JSON information via sendJSONRequest and verifies
 // the status code is success.
-func sendJSONRequestSuccessful(request *http.Request, response interface{}) error {
-	status, err := sendJSONRequest(request, response)
+func (c *Client) sendJSONRequestSuccessful(request *http.Request, response interface{}) error {
+	status, err := c.sendJSONRequest(request, response)
 	if err != nil {
 		return err
 	}
@@ -265,16 +655,21 @@ type GitHubRequestError struct {
 	Message string `json:"message"`
 }
 
+// PostGitHub creates a PR on GitHub using DefaultClient. See [Client.PostGitHub].
+func PostGitHub(ownerRepo string, request *GitHubRequest, pat string) (*GitHubResponse, error) {
+	return DefaultClient.PostGitHub(ownerRepo, request, pat)
+}
+
 // PostGitHub creates a PR on GitHub using pat for the given owner/repo and request details.
 // If the PR already exists, returns a wrapped [ErrPRAlreadyExists].
-func PostGitHub(ownerRepo string, request *GitHubRequest, pat string) (*GitHubResponse, error) {
+func (c *Client) PostGitHub(ownerRepo string, request *GitHubRequest, pat string) (*GitHubResponse, error) {
 	prSubmitContent, err := json.MarshalIndent(request, "", "")
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("Submitting payload: %s\n", prSubmitContent)
+	logger.Debug("Submitting payload", "body", string(prSubmitContent))
 
-	httpRequest, err := http.NewRequest("POST", "https://api.github.com/repos/"+ownerRepo+"/pulls", bytes.NewReader(prSubmitContent))
+	httpRequest, err := http.NewRequest("POST", c.BaseURL+"/repos/"+ownerRepo+"/pulls", bytes.NewReader(prSubmitContent))
 	if err != nil {
 		return nil, err
 	}
@@ -286,7 +681,7 @@ func PostGitHub(ownerRepo string, request *GitHubRequest, pat string) (*GitHubRe
 		Message string               `json:"message"`
 		Errors  []GitHubRequestError `json:"errors"`
 	}
-	statusCode, err := sendJSONRequest(httpRequest, &response)
+	statusCode, err := c.sendJSONRequest(httpRequest, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -328,7 +723,85 @@ func PostGitHub(ownerRepo string, request *GitHubRequest, pat string) (*GitHubRe
 	return &response.GitHubResponse, nil
 }
 
+// QueryGraphQL sends a GraphQL query using DefaultClient. See [Client.QueryGraphQL].
 func QueryGraphQL(pat string, query string, variables map[string]interface{}, result interface{}) error {
+	return DefaultClient.QueryGraphQL(pat, query, variables, result)
+}
+
+// ErrGraphQLRateLimited is matched by a [GraphQLErrors] that includes a RATE_LIMITED error.
+var ErrGraphQLRateLimited = errors.New("GraphQL request rate limited")
+
+// GraphQLError is one error in the "errors" list of a GraphQL response.
+type GraphQLError struct {
+	Type    string        `json:"type"`
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+// GraphQLErrors is the "errors" list of a GraphQL response. GitHub returns these with HTTP 200 OK,
+// for example when a rate limit is hit or a node ID doesn't exist.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, ge := range e {
+		if ge.Type != "" {
+			messages = append(messages, ge.Type+": "+ge.Message)
+		} else {
+			messages = append(messages, ge.Message)
+		}
+	}
+	return "GraphQL response has errors: " + strings.Join(messages, "; ")
+}
+
+// Is returns true if target is ErrGraphQLRateLimited and one of the errors is RATE_LIMITED.
+func (e GraphQLErrors) Is(target error) bool {
+	if target != ErrGraphQLRateLimited {
+		return false
+	}
+	for _, ge := range e {
+		if ge.Type == "RATE_LIMITED" {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryGraphQL sends a GraphQL query with the given variables and unmarshals the JSON response
+// into result. If the response contains GraphQL errors, returns them as a [GraphQLErrors]. If
+// GitHub rate limits the request, retries according to c.Retry, or if it's nil, up to
+// c.RateLimitRetries times with exponential backoff.
+func (c *Client) QueryGraphQL(pat string, query string, variables map[string]interface{}, result interface{}) error {
+	for i := 0; ; i++ {
+		err := c.queryGraphQLOnce(pat, query, variables, result)
+		if err == nil || !errors.Is(err, ErrGraphQLRateLimited) {
+			return err
+		}
+		delay, ok := c.graphQLRetryDelay(i)
+		if !ok {
+			return err
+		}
+		logger.Info("GraphQL request rate limited. Retrying.", "delay", delay, "attempt", i+1, "error", err)
+		time.Sleep(delay)
+	}
+}
+
+// graphQLRetryDelay returns the delay before the given retry of a rate limited GraphQL request,
+// starting from 0, or false if the request shouldn't be retried again.
+func (c *Client) graphQLRetryDelay(retry int) (time.Duration, bool) {
+	if c.Retry != nil {
+		if retry >= c.Retry.MaxRetries {
+			return 0, false
+		}
+		return c.Retry.delay(retry, 0), true
+	}
+	if retry >= c.RateLimitRetries {
+		return 0, false
+	}
+	return c.RateLimitRetryDelay << retry, true
+}
+
+func (c *Client) queryGraphQLOnce(pat string, query string, variables map[string]interface{}, result interface{}) error {
 	queryBytes, err := json.Marshal(&struct {
 		Query     string                 `json:"query"`
 		Variables map[string]interface{} `json:"variables,omitempty"`
@@ -340,30 +813,135 @@ func QueryGraphQL(pat string, query string, variables map[string]interface{}, re
 		return err
 	}
 
-	httpRequest, err := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewReader(queryBytes))
+	httpRequest, err := http.NewRequest("POST", c.GraphQLURL(), bytes.NewReader(queryBytes))
 	if err != nil {
 		return err
 	}
 	httpRequest.SetBasicAuth("", pat)
 
-	return sendJSONRequestSuccessful(httpRequest, result)
+	var response json.RawMessage
+	if err := c.sendJSONRequestSuccessful(httpRequest, &response); err != nil {
+		return err
+	}
+	var envelope struct {
+		Errors GraphQLErrors `json:"errors"`
+	}
+	if err := json.Unmarshal(response, &envelope); err != nil {
+		return err
+	}
+	if len(envelope.Errors) > 0 {
+		return envelope.Errors
+	}
+	return json.Unmarshal(response, result)
 }
 
+// MutateGraphQL sends a GraphQL mutation using DefaultClient. See [Client.MutateGraphQL].
 func MutateGraphQL(pat string, query string, variables map[string]interface{}) error {
+	return DefaultClient.MutateGraphQL(pat, query, variables)
+}
+
+// MutateGraphQL sends a GraphQL mutation with the given variables and discards the result.
+func (c *Client) MutateGraphQL(pat string, query string, variables map[string]interface{}) error {
 	// Queries and mutations use the same API. But with a mutation, the results aren't useful to us.
-	return QueryGraphQL(pat, query, variables, &struct{}{})
+	return c.QueryGraphQL(pat, query, variables, &struct{}{})
+}
+
+// ErrNotFork is returned when a PR head repository is expected to be a fork of the PR target
+// repository, but it isn't.
+var ErrNotFork = errors.New("head repository is not a fork of the target repository")
+
+// EnsureFork checks using DefaultClient that headOwnerRepo is a fork of targetOwnerRepo. See
+// [Client.EnsureFork].
+func EnsureFork(headOwnerRepo, targetOwnerRepo, pat string) error {
+	return DefaultClient.EnsureFork(headOwnerRepo, targetOwnerRepo, pat)
+}
+
+// EnsureFork checks that the repository headOwnerRepo exists and is a fork of targetOwnerRepo, so
+// a cross-fork PR from headOwnerRepo into targetOwnerRepo can be submitted. Both are in
+// "owner/repo" form. Returns an error wrapping [ErrNotFork] if the repository exists but isn't a
+// fork of the target. Call this before pushing a PR branch to detect a misconfiguration early.
+func (c *Client) EnsureFork(headOwnerRepo, targetOwnerRepo, pat string) error {
+	request, err := http.NewRequest("GET", c.BaseURL+"/repos/"+headOwnerRepo, nil)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth("", pat)
+
+	type repo struct {
+		FullName string `json:"full_name"`
+	}
+	var response struct {
+		Fork   bool  `json:"fork"`
+		Parent *repo `json:"parent"`
+		Source *repo `json:"source"`
+	}
+	status, err := c.sendJSONRequest(request, &response)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return fmt.Errorf("head repository %v not found. Create it as a fork of %v, or check access: %w", headOwnerRepo, targetOwnerRepo, ErrNotFork)
+	case status < 200 || status > 299:
+		return fmt.Errorf("request for head repository %v unsuccessful, http status %v, %v", headOwnerRepo, status, http.StatusText(status))
+	}
+	if !response.Fork {
+		return fmt.Errorf("%v: %w", headOwnerRepo, ErrNotFork)
+	}
+	for _, r := range []*repo{response.Parent, response.Source} {
+		if r != nil && strings.EqualFold(r.FullName, targetOwnerRepo) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v is a fork, but not of %v: %w", headOwnerRepo, targetOwnerRepo, ErrNotFork)
 }
 
+// CheckCrossForkRemotes returns an error if head and target are different repositories owned by
+// the same owner. A PR head is specified as "owner:branch", so GitHub can't tell the two apart.
+// Returns nil if head and target are the same repository, or if the owners differ.
+func CheckCrossForkRemotes(head, target *Remote) error {
+	if head.GetOwnerSlashRepo() == target.GetOwnerSlashRepo() {
+		return nil
+	}
+	if strings.EqualFold(head.GetOwner(), target.GetOwner()) {
+		return fmt.Errorf(
+			"PR head repository %v and target repository %v are different repositories with the same owner. A cross-fork PR head must be owned by a different owner",
+			head.GetOwnerSlashRepo(), target.GetOwnerSlashRepo())
+	}
+	return nil
+}
+
+// ExistingPR is a PR found on GitHub. It can be saved as JSON, for example to persist it in the
+// state of a process that may be resumed later, and read back with ParseExistingPR.
 type ExistingPR struct {
-	Title  string
-	ID     string
-	Number int
+	Title string `json:"title"`
+	// ID is the GraphQL node ID of the PR.
+	ID     string `json:"id"`
+	Number int    `json:"number"`
+}
+
+// ParseExistingPR reads an ExistingPR that was saved as JSON. Returns an error if the data doesn't
+// identify a PR.
+func ParseExistingPR(data []byte) (*ExistingPR, error) {
+	var pr ExistingPR
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse existing PR: %w", err)
+	}
+	if pr.ID == "" || pr.Number <= 0 {
+		return nil, fmt.Errorf("existing PR data is missing the ID or number: %s", data)
+	}
+	return &pr, nil
+}
+
+// FindExistingPR looks for a PR using DefaultClient. See [Client.FindExistingPR].
+func FindExistingPR(r *GitHubRequest, head, target *Remote, headBranch, submitterUser, githubPAT string) (*ExistingPR, error) {
+	return DefaultClient.FindExistingPR(r, head, target, headBranch, submitterUser, githubPAT)
 }
 
 // FindExistingPR looks for a PR submitted to a target branch with a set of filters. Returns the
 // result's graphql identity if one match is found, empty string if no matches are found, and an
 // error if more than one match was found.
-func FindExistingPR(r *GitHubRequest, head, target *Remote, headBranch, submitterUser, githubPAT string) (*ExistingPR, error) {
+func (c *Client) FindExistingPR(r *GitHubRequest, head, target *Remote, headBranch, submitterUser, githubPAT string) (*ExistingPR, error) {
 	prQuery := `query ($githubUser: String!, $headRefName: String!, $baseRefName: String!) {
 		user(login: $githubUser) {
 			pullRequests(states: OPEN, headRefName: $headRefName, baseRefName: $baseRefName, first: 5) {
@@ -422,10 +1000,10 @@ func FindExistingPR(r *GitHubRequest, head, target *Remote, headBranch, submitte
 		}
 	}{}
 
-	if err := QueryGraphQL(githubPAT, prQuery, variables, result); err != nil {
+	if err := c.QueryGraphQL(githubPAT, prQuery, variables, result); err != nil {
 		return nil, err
 	}
-	fmt.Printf("%+v\n", result)
+	logger.Debug("Existing PR query result", "result", fmt.Sprintf("%+v", result))
 
 	// The user.pullRequests GitHub API isn't able to filter by repo name, so do it ourselves.
 	result.Data.User.PullRequests.Nodes = selectFunc(
@@ -458,10 +1036,346 @@ func FindExistingPR(r *GitHubRequest, head, target *Remote, headBranch, submitte
 	return &n.ExistingPR, nil
 }
 
+// ListOpenPRs lists open PRs using DefaultClient. See [Client.ListOpenPRs].
+func ListOpenPRs(owner, headPrefix, pat string) ([]ExistingPR, error) {
+	return DefaultClient.ListOpenPRs(owner, headPrefix, pat)
+}
+
+// ListOpenPRs returns every open PR authored by the GitHub user owner whose head branch name starts
+// with headPrefix, such as "dev/auto-sync/". The PRs may be in any repository. This can be used to
+// find stale automation PRs to clean up.
+func (c *Client) ListOpenPRs(owner, headPrefix, pat string) ([]ExistingPR, error) {
+	query := `query ($searchQuery: String!, $cursor: String) {
+		search(query: $searchQuery, type: ISSUE, first: 100, after: $cursor) {
+			nodes {
+				... on PullRequest {
+					title
+					id
+					number
+					headRefName
+				}
+			}
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
+		}
+	}`
+	type PRNode struct {
+		ExistingPR
+		HeadRefName string
+	}
+	variables := map[string]interface{}{
+		"searchQuery": "is:pr is:open author:" + owner,
+	}
+	var prs []ExistingPR
+	for {
+		result := &struct {
+			Data struct {
+				Search struct {
+					Nodes    []PRNode
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   string
+					}
+				}
+			}
+		}{}
+		if err := c.QueryGraphQL(pat, query, variables, result); err != nil {
+			return nil, err
+		}
+		// The search API isn't able to filter by head branch name prefix, so do it ourselves.
+		nodes := selectFunc(result.Data.Search.Nodes, func(n PRNode) bool {
+			return strings.HasPrefix(n.HeadRefName, headPrefix)
+		})
+		for _, n := range nodes {
+			prs = append(prs, n.ExistingPR)
+		}
+		if !result.Data.Search.PageInfo.HasNextPage {
+			return prs, nil
+		}
+		variables["cursor"] = result.Data.Search.PageInfo.EndCursor
+	}
+}
+
+// UpdatePR updates a PR's title and body using DefaultClient. See [Client.UpdatePR].
+func UpdatePR(ownerRepo string, number int, title, body, pat string) error {
+	return DefaultClient.UpdatePR(ownerRepo, number, title, body, pat)
+}
+
+// UpdatePR sets the title and body of PR number in the given owner/repo using pat. This keeps the
+// description of a reused PR up to date with its latest changes.
+func (c *Client) UpdatePR(ownerRepo string, number int, title, body, pat string) error {
+	_, err := c.updatePR(ownerRepo, number, title, body, pat)
+	return err
+}
+
+// updatePR implements UpdatePR and returns the updated PR.
+func (c *Client) updatePR(ownerRepo string, number int, title, body, pat string) (*GitHubResponse, error) {
+	content, err := json.Marshal(struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}{title, body})
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("Submitting payload", "body", string(content))
+
+	httpRequest, err := http.NewRequest("PATCH", c.BaseURL+"/repos/"+ownerRepo+"/pulls/"+strconv.Itoa(number), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.SetBasicAuth("", pat)
+
+	var response GitHubResponse
+	if err := c.sendJSONRequestSuccessful(httpRequest, &response); err != nil {
+		return nil, fmt.Errorf("failed to update PR %v#%v: %w", ownerRepo, number, err)
+	}
+	return &response, nil
+}
+
+// AddAssignees assigns users to a PR using DefaultClient. See [Client.AddAssignees].
+func AddAssignees(ownerRepo string, number int, assignees []string, pat string) error {
+	return DefaultClient.AddAssignees(ownerRepo, number, assignees, pat)
+}
+
+// ErrAssigneesNotAdded is returned (wrapped) by AddAssignees if GitHub didn't assign some of the
+// users to the PR.
+var ErrAssigneesNotAdded = errors.New("assignees not added")
+
+// AddAssignees assigns the given GitHub users to PR number in the given owner/repo, in addition to
+// any existing assignees. GitHub silently ignores a user who doesn't exist or can't be assigned,
+// so this checks the response and returns an error wrapping [ErrAssigneesNotAdded] that names the
+// users who weren't assigned.
+func (c *Client) AddAssignees(ownerRepo string, number int, assignees []string, pat string) error {
+	// PRs share the issue number space, and GitHub sets PR assignees through the issues API.
+	content, err := json.Marshal(&struct {
+		Assignees []string `json:"assignees"`
+	}{assignees})
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequest("POST", c.BaseURL+"/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/assignees", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	httpRequest.SetBasicAuth("", pat)
+
+	var response struct {
+		Assignees []struct {
+			Login string `json:"login"`
+		} `json:"assignees"`
+	}
+	if err := c.sendJSONRequestSuccessful(httpRequest, &response); err != nil {
+		return fmt.Errorf("failed to add assignees to PR %v#%v: %w", ownerRepo, number, err)
+	}
+	// GitHub logins are case-insensitive.
+	assigned := make(map[string]bool, len(response.Assignees))
+	for _, a := range response.Assignees {
+		assigned[strings.ToLower(a.Login)] = true
+	}
+	var missing []string
+	for _, a := range assignees {
+		if !assigned[strings.ToLower(a)] {
+			missing = append(missing, a)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %v#%v: %v", ErrAssigneesNotAdded, ownerRepo, number, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// EnsurePR creates or updates a PR using DefaultClient. See [Client.EnsurePR].
+func EnsurePR(target, head *Remote, r *GitHubRequest, submitterUser, pat string) (*GitHubResponse, bool, error) {
+	return DefaultClient.EnsurePR(target, head, r, submitterUser, pat)
+}
+
+// EnsurePR makes sure there's an open PR in target for r, submitted by submitterUser, from the
+// head branch in r.Head. If there's already one, updates its title and body to match r. Otherwise,
+// creates it. Returns the PR and whether it was newly created.
+//
+// If another process creates the PR in between the check and creation, EnsurePR finds and updates
+// that PR rather than failing with [ErrPRAlreadyExists].
+func (c *Client) EnsurePR(target, head *Remote, r *GitHubRequest, submitterUser, pat string) (*GitHubResponse, bool, error) {
+	_, headBranch, ok := strings.Cut(r.Head, ":")
+	if !ok {
+		return nil, false, fmt.Errorf("PR head %q isn't in 'owner:branch' form", r.Head)
+	}
+	update := func() (*GitHubResponse, bool, error) {
+		existing, err := c.FindExistingPR(r, head, target, headBranch, submitterUser, pat)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing == nil {
+			return nil, false, nil
+		}
+		logger.Info("Updating existing PR", "number", existing.Number)
+		pr, err := c.updatePR(target.GetOwnerSlashRepo(), existing.Number, r.Title, r.Body, pat)
+		if err != nil {
+			return nil, false, err
+		}
+		if pr.NodeID == "" {
+			pr.NodeID = existing.ID
+		}
+		if pr.Number == 0 {
+			pr.Number = existing.Number
+		}
+		return pr, true, nil
+	}
+
+	if pr, found, err := update(); err != nil || found {
+		return pr, false, err
+	}
+	pr, err := c.PostGitHub(target.GetOwnerSlashRepo(), r, pat)
+	if err == nil {
+		return pr, true, nil
+	}
+	if !errors.Is(err, ErrPRAlreadyExists) {
+		return nil, false, err
+	}
+	pr, found, findErr := update()
+	if findErr != nil {
+		return nil, false, findErr
+	}
+	if !found {
+		return nil, false, fmt.Errorf("unable to submit PR because PR already exists, but no existing PR was found: %w", err)
+	}
+	return pr, false, nil
+}
+
+// ErrPRClosed is returned when a PR is expected to be merged eventually, but it was closed
+// without being merged.
+var ErrPRClosed = errors.New("PR is closed without being merged")
+
+// GetDefaultBranch gets the default branch of a repo using DefaultClient. See
+// [Client.GetDefaultBranch].
+func GetDefaultBranch(ownerRepo, pat string) (string, error) {
+	return DefaultClient.GetDefaultBranch(ownerRepo, pat)
+}
+
+// GetDefaultBranch returns the name of the default branch of the given owner/repo, such as "main".
+func (c *Client) GetDefaultBranch(ownerRepo, pat string) (string, error) {
+	request, err := http.NewRequest("GET", c.BaseURL+"/repos/"+ownerRepo, nil)
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth("", pat)
+
+	var response struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.sendJSONRequestSuccessful(request, &response); err != nil {
+		return "", fmt.Errorf("failed to get repository %v: %w", ownerRepo, err)
+	}
+	if response.DefaultBranch == "" {
+		return "", fmt.Errorf("repository %v has no default branch", ownerRepo)
+	}
+	return response.DefaultBranch, nil
+}
+
+// GetPRMergeCommit gets the merge commit of a PR using DefaultClient. See
+// [Client.GetPRMergeCommit].
+func GetPRMergeCommit(ownerRepo string, number int, pat string) (sha string, merged bool, err error) {
+	return DefaultClient.GetPRMergeCommit(ownerRepo, number, pat)
+}
+
+// GetPRMergeCommit returns the commit that PR number in the given owner/repo was merged as. This
+// is the merge, squash, or rebased commit, depending on how the PR was completed.
+//
+// If the PR is open, returns merged false and no error, so the caller can keep polling. If the PR
+// was closed without being merged, returns an error wrapping [ErrPRClosed].
+func (c *Client) GetPRMergeCommit(ownerRepo string, number int, pat string) (sha string, merged bool, err error) {
+	request, err := http.NewRequest("GET", c.BaseURL+"/repos/"+ownerRepo+"/pulls/"+strconv.Itoa(number), nil)
+	if err != nil {
+		return "", false, err
+	}
+	request.SetBasicAuth("", pat)
+
+	var response struct {
+		State          string `json:"state"`
+		Merged         bool   `json:"merged"`
+		MergeCommitSHA string `json:"merge_commit_sha"`
+	}
+	if err := c.sendJSONRequestSuccessful(request, &response); err != nil {
+		return "", false, fmt.Errorf("failed to get PR %v#%v: %w", ownerRepo, number, err)
+	}
+	if !response.Merged {
+		if response.State == "closed" {
+			return "", false, fmt.Errorf("%w: %v#%v", ErrPRClosed, ownerRepo, number)
+		}
+		// An open PR has a merge_commit_sha too, but it's only a test merge.
+		return "", false, nil
+	}
+	if response.MergeCommitSHA == "" {
+		return "", false, fmt.Errorf("PR %v#%v is merged, but has no merge commit", ownerRepo, number)
+	}
+	return response.MergeCommitSHA, true, nil
+}
+
+// GetRefCommit returns the commit a ref points to using DefaultClient. See
+// [Client.GetRefCommit].
+func GetRefCommit(ownerRepo, ref, pat string) (string, error) {
+	return DefaultClient.GetRefCommit(ownerRepo, ref, pat)
+}
+
+// GetRefCommit returns the full hash of the commit that ref, such as a branch name, points to in
+// the given owner/repo.
+func (c *Client) GetRefCommit(ownerRepo, ref, pat string) (string, error) {
+	request, err := http.NewRequest("GET", c.BaseURL+"/repos/"+ownerRepo+"/commits/"+url.PathEscape(ref), nil)
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth("", pat)
+
+	var response struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.sendJSONRequestSuccessful(request, &response); err != nil {
+		return "", fmt.Errorf("failed to get commit of %v in %v: %w", ref, ownerRepo, err)
+	}
+	return response.SHA, nil
+}
+
+// GetFileContent returns the content of a file using DefaultClient. See
+// [Client.GetFileContent].
+func GetFileContent(ownerRepo, ref, path, pat string) ([]byte, error) {
+	return DefaultClient.GetFileContent(ownerRepo, ref, path, pat)
+}
+
+// GetFileContent returns the content of the file at path in the given owner/repo at ref, which
+// may be a branch name or commit hash. This is much cheaper than cloning the repo to read a single
+// file. The GitHub API only returns the content of files up to 1 MB this way.
+func (c *Client) GetFileContent(ownerRepo, ref, path, pat string) ([]byte, error) {
+	request, err := http.NewRequest("GET", c.BaseURL+"/repos/"+ownerRepo+"/contents/"+path+"?ref="+url.QueryEscape(ref), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.SetBasicAuth("", pat)
+
+	var response struct {
+		Encoding string `json:"encoding"`
+		Content  string `json:"content"`
+	}
+	if err := c.sendJSONRequestSuccessful(request, &response); err != nil {
+		return nil, fmt.Errorf("failed to get %v at %v in %v: %w", path, ref, ownerRepo, err)
+	}
+	if response.Encoding != "base64" {
+		return nil, fmt.Errorf("unexpected encoding %q of %v at %v in %v", response.Encoding, path, ref, ownerRepo)
+	}
+	// GitHub wraps the base64 content in lines.
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(response.Content, "\n", ""))
+}
+
+// ApprovePR approves a PR using DefaultClient. See [Client.ApprovePR].
+func ApprovePR(nodeID string, pat string) error {
+	return DefaultClient.ApprovePR(nodeID, pat)
+}
+
 // ApprovePR adds an approving review on the target GraphQL PR node ID. The review author is the user
 // associated with the PAT.
-func ApprovePR(nodeID string, pat string) error {
-	return MutateGraphQL(
+func (c *Client) ApprovePR(nodeID string, pat string) error {
+	return c.MutateGraphQL(
 		pat,
 		`mutation ($nodeID: ID!) {
 				addPullRequestReview(input: {pullRequestId: $nodeID, event: APPROVE, body: "Thanks! Auto-approving."}) {
@@ -471,16 +1385,301 @@ func ApprovePR(nodeID string, pat string) error {
 		map[string]interface{}{"nodeID": nodeID})
 }
 
-// EnablePRAutoMerge enables PR automerge on the target GraphQL PR node ID.
+// DismissReviews dismisses the PAT user's approving reviews using DefaultClient. See
+// [Client.DismissReviews].
+func DismissReviews(nodeID string, pat string) error {
+	return DefaultClient.DismissReviews(nodeID, pat)
+}
+
+// DismissReviews dismisses every approving review that the user associated with the PAT left on
+// the target GraphQL PR node ID. Other users' reviews are left alone. Use this before approving
+// again after pushing new commits, so the PR's approval is for the latest commit.
+func (c *Client) DismissReviews(nodeID string, pat string) error {
+	var result struct {
+		Data struct {
+			Node struct {
+				Reviews struct {
+					Nodes []struct {
+						ID              string
+						ViewerDidAuthor bool
+					}
+				}
+			}
+		}
+	}
+	err := c.QueryGraphQL(
+		pat,
+		`query ($nodeID: ID!) {
+			node(id: $nodeID) {
+				... on PullRequest {
+					reviews(states: APPROVED, first: 100) {
+						nodes {
+							id
+							viewerDidAuthor
+						}
+					}
+				}
+			}
+		}`,
+		map[string]interface{}{"nodeID": nodeID},
+		&result)
+	if err != nil {
+		return err
+	}
+	for _, r := range result.Data.Node.Reviews.Nodes {
+		if !r.ViewerDidAuthor {
+			continue
+		}
+		err := c.MutateGraphQL(
+			pat,
+			`mutation ($reviewID: ID!) {
+				dismissPullRequestReview(input: {pullRequestReviewId: $reviewID, message: "Dismissing approval of an earlier commit."}) {
+					clientMutationId
+				}
+			}`,
+			map[string]interface{}{"reviewID": r.ID})
+		if err != nil {
+			return fmt.Errorf("failed to dismiss review %v: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// IsAutoMergeEnabled checks whether PR automerge is enabled using DefaultClient. See
+// [Client.IsAutoMergeEnabled].
+func IsAutoMergeEnabled(nodeID string, pat string) (bool, error) {
+	return DefaultClient.IsAutoMergeEnabled(nodeID, pat)
+}
+
+// IsAutoMergeEnabled returns whether auto-merge is already enabled on the target GraphQL PR node
+// ID. Enabling auto-merge again is redundant and may fail, so callers can use this to skip it.
+func (c *Client) IsAutoMergeEnabled(nodeID string, pat string) (bool, error) {
+	var result struct {
+		Data struct {
+			Node struct {
+				AutoMergeRequest *struct {
+					EnabledAt string
+				}
+			}
+		}
+	}
+	err := c.QueryGraphQL(
+		pat,
+		`query ($nodeID: ID!) {
+			node(id: $nodeID) {
+				... on PullRequest {
+					autoMergeRequest {
+						enabledAt
+					}
+				}
+			}
+		}`,
+		map[string]interface{}{"nodeID": nodeID},
+		&result)
+	if err != nil {
+		return false, err
+	}
+	return result.Data.Node.AutoMergeRequest != nil, nil
+}
+
+// ErrBaseBranchMissing indicates that the base branch of a PR no longer exists, for example because
+// it was deleted or renamed. Updating the PR is likely to fail in confusing ways.
+var ErrBaseBranchMissing = errors.New("PR base branch is missing")
+
+// CheckBaseBranch checks that a PR's base branch exists using DefaultClient. See
+// [Client.CheckBaseBranch].
+func CheckBaseBranch(nodeID string, pat string) error {
+	return DefaultClient.CheckBaseBranch(nodeID, pat)
+}
+
+// CheckBaseBranch returns an error wrapping [ErrBaseBranchMissing] if the base branch of the
+// target GraphQL PR node ID no longer exists.
+func (c *Client) CheckBaseBranch(nodeID string, pat string) error {
+	var result struct {
+		Data struct {
+			Node struct {
+				BaseRefName string
+				BaseRef     *struct {
+					Name string
+				}
+			}
+		}
+	}
+	err := c.QueryGraphQL(
+		pat,
+		`query ($nodeID: ID!) {
+			node(id: $nodeID) {
+				... on PullRequest {
+					baseRefName
+					baseRef {
+						name
+					}
+				}
+			}
+		}`,
+		map[string]interface{}{"nodeID": nodeID},
+		&result)
+	if err != nil {
+		return err
+	}
+	// GitHub keeps the name of a deleted base branch in baseRefName, but baseRef is null.
+	if result.Data.Node.BaseRef == nil {
+		return fmt.Errorf("%w: %q", ErrBaseBranchMissing, result.Data.Node.BaseRefName)
+	}
+	return nil
+}
+
+// ErrPRConflicting indicates that a PR can't be merged because it conflicts with its base branch.
+var ErrPRConflicting = errors.New("PR conflicts with its base branch")
+
+// ErrMergeableStateUnknown indicates that GitHub didn't finish computing whether a PR is mergeable
+// in time.
+var ErrMergeableStateUnknown = errors.New("PR mergeable state is unknown")
+
+// WaitForMergeableState waits for GitHub to compute a PR's mergeable state using DefaultClient.
+// See [Client.WaitForMergeableState].
+func WaitForMergeableState(nodeID string, timeout time.Duration, pat string) error {
+	return DefaultClient.WaitForMergeableState(nodeID, timeout, pat)
+}
+
+// WaitForMergeableState polls the target GraphQL PR node ID until GitHub has computed whether it's
+// mergeable. GitHub computes this asynchronously, so right after a PR is created or updated, it
+// may not be known yet, and enabling auto-merge may fail.
+//
+// Returns nil if the PR is mergeable, an error wrapping [ErrPRConflicting] if it conflicts with its
+// base branch, or an error wrapping [ErrMergeableStateUnknown] if the state is still unknown after
+// timeout.
+func (c *Client) WaitForMergeableState(nodeID string, timeout time.Duration, pat string) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var result struct {
+			Data struct {
+				Node struct {
+					Mergeable        string
+					MergeStateStatus string
+				}
+			}
+		}
+		err := c.QueryGraphQL(
+			pat,
+			`query ($nodeID: ID!) {
+				node(id: $nodeID) {
+					... on PullRequest {
+						mergeable
+						mergeStateStatus
+					}
+				}
+			}`,
+			map[string]interface{}{"nodeID": nodeID},
+			&result)
+		if err != nil {
+			return err
+		}
+		n := result.Data.Node
+		switch {
+		case n.Mergeable == "CONFLICTING" || n.MergeStateStatus == "DIRTY":
+			return fmt.Errorf("%w: %v", ErrPRConflicting, nodeID)
+		case n.Mergeable == "MERGEABLE" && n.MergeStateStatus != "UNKNOWN":
+			return nil
+		}
+		if !time.Now().Add(c.MergeablePollDelay).Before(deadline) {
+			return fmt.Errorf("%w after %v: %v", ErrMergeableStateUnknown, timeout, nodeID)
+		}
+		logger.Info("Waiting for GitHub to compute mergeable state", "nodeID", nodeID, "mergeable", n.Mergeable, "mergeStateStatus", n.MergeStateStatus)
+		time.Sleep(c.MergeablePollDelay)
+	}
+}
+
+// EnablePRAutoMerge enables PR automerge using DefaultClient. See [Client.EnablePRAutoMerge].
 func EnablePRAutoMerge(nodeID string, pat string) error {
-	return MutateGraphQL(
+	return DefaultClient.EnablePRAutoMerge(nodeID, pat)
+}
+
+// EnablePRAutoMerge enables PR automerge on the target GraphQL PR node ID using a merge commit.
+func (c *Client) EnablePRAutoMerge(nodeID string, pat string) error {
+	return c.EnablePRAutoMergeWithMethod(nodeID, pat, MergeMethodMerge)
+}
+
+// MergeMethod is a GitHub GraphQL PullRequestMergeMethod.
+type MergeMethod string
+
+const (
+	MergeMethodMerge  MergeMethod = "MERGE"
+	MergeMethodSquash MergeMethod = "SQUASH"
+	MergeMethodRebase MergeMethod = "REBASE"
+)
+
+// ParseMergeMethod returns the MergeMethod named by s, ignoring case. Empty string is
+// MergeMethodMerge.
+func ParseMergeMethod(s string) (MergeMethod, error) {
+	switch m := MergeMethod(strings.ToUpper(s)); m {
+	case "":
+		return MergeMethodMerge, nil
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown merge method %q", s)
+}
+
+// EnablePRAutoMergeWithMethod enables PR automerge using DefaultClient. See
+// [Client.EnablePRAutoMergeWithMethod].
+func EnablePRAutoMergeWithMethod(nodeID string, pat string, method MergeMethod) error {
+	return DefaultClient.EnablePRAutoMergeWithMethod(nodeID, pat, method)
+}
+
+// EnablePRAutoMergeWithMethod enables PR automerge on the target GraphQL PR node ID using the
+// given merge method.
+func (c *Client) EnablePRAutoMergeWithMethod(nodeID string, pat string, method MergeMethod) error {
+	return c.EnablePRAutoMergeWithOptions(nodeID, pat, &AutoMergeOptions{Method: method})
+}
+
+// AutoMergeOptions configures how GitHub completes a PR once auto-merge is enabled.
+type AutoMergeOptions struct {
+	// Method is the merge method. Empty means MergeMethodMerge.
+	Method MergeMethod
+	// CommitHeadline is the headline of the merge or squash commit. If empty, GitHub uses its
+	// default, which includes the PR number.
+	CommitHeadline string
+	// CommitBody is the body of the merge or squash commit. If empty, GitHub uses its default.
+	CommitBody string
+}
+
+// EnablePRAutoMergeWithOptions enables PR automerge using DefaultClient. See
+// [Client.EnablePRAutoMergeWithOptions].
+func EnablePRAutoMergeWithOptions(nodeID string, pat string, opts *AutoMergeOptions) error {
+	return DefaultClient.EnablePRAutoMergeWithOptions(nodeID, pat, opts)
+}
+
+// EnablePRAutoMergeWithOptions enables PR automerge on the target GraphQL PR node ID using the
+// given options. A nil opts uses a merge commit with GitHub's default commit message.
+func (c *Client) EnablePRAutoMergeWithOptions(nodeID string, pat string, opts *AutoMergeOptions) error {
+	if opts == nil {
+		opts = &AutoMergeOptions{}
+	}
+	method := opts.Method
+	if method == "" {
+		method = MergeMethodMerge
+	}
+	// Pass null rather than an empty string to let GitHub pick the default message.
+	nullIfEmpty := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	return c.MutateGraphQL(
 		pat,
-		`mutation ($nodeID: ID!) {
-			enablePullRequestAutoMerge(input: {pullRequestId: $nodeID, mergeMethod: MERGE}) {
+		`mutation ($nodeID: ID!, $mergeMethod: PullRequestMergeMethod!, $commitHeadline: String, $commitBody: String) {
+			enablePullRequestAutoMerge(input: {pullRequestId: $nodeID, mergeMethod: $mergeMethod, commitHeadline: $commitHeadline, commitBody: $commitBody}) {
 				clientMutationId
 			}
 		}`,
-		map[string]interface{}{"nodeID": nodeID})
+		map[string]interface{}{
+			"nodeID":         nodeID,
+			"mergeMethod":    method,
+			"commitHeadline": nullIfEmpty(opts.CommitHeadline),
+			"commitBody":     nullIfEmpty(opts.CommitBody),
+		})
 }
 
 // createRefspec makes a refspec that will fetch or push a branch "source" to "dest". The args must
@@ -0,0 +1,155 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package stringutil
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnifiedDiff returns a unified diff of the lines in old and new, labeled with name, or empty string
+// if they are the same. Lines the files have in common at the start and end are trimmed before
+// comparing the rest, so comparing large files with a small change stays cheap.
+func UnifiedDiff(name string, old, new []byte) string {
+	if bytes.Equal(old, new) {
+		return ""
+	}
+	a, b := splitLines(string(old)), splitLines(string(new))
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	// Each op is a line prefixed by ' ', '-', or '+'.
+	var ops []string
+	for _, line := range a[:prefix] {
+		ops = append(ops, " "+line)
+	}
+	ops = append(ops, diffLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, " "+line)
+	}
+
+	const context = 3
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+	// oldLine and newLine are the 0-based line numbers in a and b of ops[i].
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i][0] == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// Found a change. Start the hunk with up to "context" lines before it, and include
+		// following changes until there are more than 2*context unchanged lines between them.
+		start := max(i-context, 0)
+		for j := start; j < i; j++ {
+			oldLine--
+			newLine--
+		}
+		last := i
+		for j := i; j < len(ops); j++ {
+			if ops[j][0] != ' ' {
+				if j-last-1 > 2*context {
+					break
+				}
+				last = j
+			}
+		}
+		end := min(last+1+context, len(ops))
+
+		var oldCount, newCount int
+		for _, op := range ops[start:end] {
+			if op[0] != '+' {
+				oldCount++
+			}
+			if op[0] != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, op := range ops[start:end] {
+			sb.WriteString(op)
+			if !strings.HasSuffix(op, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		oldLine += oldCount
+		newLine += newCount
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange formats the range of a hunk header given the 0-based line number the hunk starts at
+// and the number of lines it contains.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range refers to the line before the hunk.
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return strconv.Itoa(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s into lines, keeping each line's newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the ops that turn a into b, based on their longest common subsequence.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, "-"+a[i])
+			i++
+		default:
+			ops = append(ops, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, "+"+b[j])
+	}
+	return ops
+}
@@ -4,9 +4,14 @@
 package stringutil
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/text/encoding/unicode"
@@ -60,30 +65,78 @@ func ReadJSONFile(path string, i interface{}) (err error) {
 		}
 	}()
 
-	content := transform.NewReader(f, unicode.BOMOverride(transform.Nop))
+	return ReadJSON(f, "file "+path, i)
+}
+
+// ReadJSON reads one JSON value from r, decoding it the same way as ReadJSONFile. Supports BOM.
+// name describes the source of the JSON in error messages, for example "file config.json".
+func ReadJSON(r io.Reader, name string, i interface{}) error {
+	content := transform.NewReader(r, unicode.BOMOverride(transform.Nop))
 	d := json.NewDecoder(content)
 	if err := d.Decode(i); err != nil {
-		return fmt.Errorf("unable to decode JSON file %v: %w", path, err)
+		return fmt.Errorf("unable to decode JSON %v: %w", name, err)
 	}
 	return nil
 }
 
-// WriteJSONFile writes one specified value to a file as indented JSON with a trailing newline.
-func WriteJSONFile(path string, i interface{}) (err error) {
-	f, err := os.Create(path)
+// MarshalJSONFile returns the content WriteJSONFile would write to a file for the given value.
+func MarshalJSONFile(i interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	d := json.NewEncoder(&b)
+	d.SetIndent("", "  ")
+	if err := d.Encode(i); err != nil {
+		return nil, fmt.Errorf("unable to encode model into JSON: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// WriteJSONFile writes one specified value to a file as indented JSON with a trailing newline. The
+// content is written to a temporary file in the same directory and then renamed to path, so an
+// interrupted write doesn't leave a truncated file behind. If the file already exists, its
+// permissions are preserved. Otherwise, it's created with permissions 0644.
+func WriteJSONFile(path string, i interface{}) error {
+	content, err := MarshalJSONFile(i)
 	if err != nil {
-		return fmt.Errorf("unable to open JSON file %v for writing: %w", path, err)
+		return fmt.Errorf("unable to write JSON file %v: %w", path, err)
+	}
+	if err := writeFileAtomic(path, content); err != nil {
+		return fmt.Errorf("unable to write JSON file %v: %w", path, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes content to a temporary file next to path, then renames it to path.
+func writeFileAtomic(path string, content []byte) (err error) {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
 	}
 	defer func() {
-		if closeErr := f.Close(); err == nil {
-			err = closeErr
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
 		}
 	}()
 
-	d := json.NewEncoder(f)
-	d.SetIndent("", "  ")
-	if err := d.Encode(i); err != nil {
-		return fmt.Errorf("unable to encode model into JSON file %v: %w", path, err)
+	if _, err := f.Write(content); err != nil {
+		return err
 	}
-	return nil
+	// Make sure the content is on disk before the rename makes it visible.
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
 }
@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package stringutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteJSONFile(t *testing.T) {
+	d := t.TempDir()
+	path := filepath.Join(d, "versions.json")
+	if err := os.WriteFile(path, []byte("old content that is longer than the new content\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteJSONFile(path, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"a\": 1\n}\n"; string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Windows only tracks the read-only bit.
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("permissions = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+	entries, err := os.ReadDir(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+
+	// A value that can't be encoded leaves the existing file alone.
+	if err := WriteJSONFile(path, func() {}); err == nil {
+		t.Error("WriteJSONFile() of a func expected error")
+	}
+	if got2, err := os.ReadFile(path); err != nil || string(got2) != string(got) {
+		t.Errorf("content after failed write = %q, %v, want %q", got2, err, got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package sync
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/microsoft/go-infra/azdo"
+	"github.com/microsoft/go-infra/stringutil"
+)
+
+// EntryMetrics counts the outcome of each branch processed for one sync config entry. A list of
+// EntryMetrics is written as JSON to track sync health over time.
+type EntryMetrics struct {
+	Upstream string
+	Target   string
+
+	// Synced is the number of branches that had a PR created or updated, or were pushed directly.
+	Synced int
+	// Skipped is the number of branches that didn't need a PR, or where PR submission was skipped,
+	// for example because the branch was already up to date or this is a dry run.
+	Skipped int
+	// Failed is the number of branches that couldn't be synced due to an error.
+	Failed int
+}
+
+// newEntryMetrics counts the results of a MakeBranchPRs call for the given entry, with the given
+// maximum number of branches per entry. If err is not nil and there are no results, the failure
+// happened before any branch-specific results were available, so every branch that was selected
+// for this run is counted as failed. Branches deferred to a later run aren't counted.
+func newEntryMetrics(entry *ConfigEntry, maxBranches int, results []SyncResult, err error) EntryMetrics {
+	m := EntryMetrics{
+		Upstream: entry.Upstream,
+		Target:   entry.Target,
+	}
+	if err != nil && results == nil {
+		m.Failed = limitBranchCount(len(entry.AutoSyncBranches), maxBranches)
+		return m
+	}
+	for _, r := range results {
+		switch {
+		case r.Failed:
+			m.Failed++
+		case r.PR != nil, r.Pushed:
+			m.Synced++
+		default:
+			m.Skipped++
+		}
+	}
+	return m
+}
+
+// reportMetrics prints a summary of the given metrics and, depending on the flags, writes them to a
+// JSON file and sets AzDO variables with the totals.
+func (f *Flags) reportMetrics(metrics []EntryMetrics) error {
+	var total EntryMetrics
+	for _, m := range metrics {
+		fmt.Printf("--- Metrics for %v -> %v: synced %v, skipped %v, failed %v\n", m.Upstream, m.Target, m.Synced, m.Skipped, m.Failed)
+		total.Synced += m.Synced
+		total.Skipped += m.Skipped
+		total.Failed += m.Failed
+	}
+	fmt.Printf("--- Metrics total: synced %v, skipped %v, failed %v\n", total.Synced, total.Skipped, total.Failed)
+
+	if f.MetricsFile != nil && *f.MetricsFile != "" {
+		if err := stringutil.WriteJSONFile(*f.MetricsFile, metrics); err != nil {
+			return fmt.Errorf("failed to write metrics file: %w", err)
+		}
+	}
+	if f.MetricsAzDOVariablePrefix != nil && *f.MetricsAzDOVariablePrefix != "" {
+		azdo.LogCmdSetVariable(*f.MetricsAzDOVariablePrefix+"Synced", strconv.Itoa(total.Synced))
+		azdo.LogCmdSetVariable(*f.MetricsAzDOVariablePrefix+"Skipped", strconv.Itoa(total.Skipped))
+		azdo.LogCmdSetVariable(*f.MetricsAzDOVariablePrefix+"Failed", strconv.Itoa(total.Failed))
+	}
+	return nil
+}
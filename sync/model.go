@@ -35,7 +35,9 @@ type ConfigEntry struct {
 	// different URL that works with authentication.
 	Target string
 	// Head is the GitHub repository to store the merged branch on. If not specified, defaults to
-	// the value of Target. This can be used to run the PR from a GitHub fork.
+	// the value of Target. This can be used to run the PR from a GitHub fork, such as a dedicated
+	// bot fork. The PR is still opened against Target. A Head that differs from Target must be a
+	// fork of Target with a different owner. The "head-repo" flag overrides this value.
 	Head string
 	// MirrorTarget	is an optional Git repository to push the upstream branch to. All mirroring
 	// operations must succeed before sync continues with this sync config entry. The mirror target
@@ -61,7 +63,8 @@ type ConfigEntry struct {
 	AutoMirrorBranches []string
 
 	// MainBranch is the main/master branch of the target repository. When creating a new release
-	// branch, it is forked from the tip of this branch.
+	// branch, it is forked from the tip of this branch. If not specified, the default branch of the
+	// target repository is used.
 	MainBranch string
 
 	// SourceBranchLatestCommit is a map of source branch names in Upstream (keys) and a full commit
@@ -76,11 +79,69 @@ type ConfigEntry struct {
 	// some cases this isn't possible. In these cases, Target has in-place modifications that must
 	// be auto-resolved during the sync process.
 	AutoResolveTarget []string
+	// AutoResolveTheirsTarget lists files and dirs that we want to take as they are in Upstream,
+	// even if Target has modified them. It is applied before AutoResolveTarget, so if a path is
+	// in both lists, AutoResolveTarget wins and the Target version is kept. Can't be used with
+	// TargetSubdir, because Upstream paths don't match Target paths in that layout.
+	AutoResolveTheirsTarget []string
+	// ExcludePaths lists files and dirs that we never want to import from Upstream. After the
+	// merge, each path is reverted to its state in Target, or removed if it only exists in
+	// Upstream. Unlike AutoResolveTarget, this doesn't depend on the path being modified or
+	// conflicted, and paths that don't exist on either side are ignored. ExcludePaths is applied
+	// after AutoResolveTarget and AutoResolveTheirsTarget. Paths are relative to the root of the
+	// Target repo. Not used for a SubmoduleTarget update.
+	ExcludePaths []string
 	// SubmoduleTarget is the path of a submodule in the Target repo to update with the latest
 	// version of Upstream and UpstreamMirror (if specified). If this option is not specified
 	// (default), that indicates the entire Upstream repository should be merged into the Target
 	// repository.
 	SubmoduleTarget string
+	// TargetSubdir is the path of a directory in the Target repo to merge Upstream into, for a
+	// Target that is a monorepo containing Upstream as one of its parts. Upstream paths are mapped
+	// into this dir using a subtree merge. If the dir doesn't exist yet in a target branch, the
+	// first sync adds the Upstream content there. If this option is not specified (default), the
+	// Upstream and Target repos are expected to share the same file layout. AutoResolveTarget paths
+	// are still relative to the root of the Target repo. Can't be used with SubmoduleTarget.
+	TargetSubdir string
+	// FastForwardOnly makes each Target branch track Upstream exactly rather than merging Upstream
+	// into it. Sync checks that the Target branch is an ancestor of the Upstream commit and moves
+	// the branch to the Upstream commit without creating a merge commit. GitHub completes the PR
+	// with a merge commit or rewritten commits, so a Target branch that has the same tree as an
+	// Upstream commit is also accepted. Otherwise, the branches have diverged, a fast-forward isn't
+	// possible, and sync fails. Can't be used with SubmoduleTarget, TargetSubdir,
+	// AutoResolveTarget, AutoResolveTheirsTarget, or ExcludePaths, because they require a merge.
+	FastForwardOnly bool
+	// FastForwardPush makes a FastForwardOnly entry push the Upstream commit directly to the Target
+	// branch instead of submitting a PR. Use this when the Target branch must contain the same
+	// commits as Upstream: a PR can't be completed as a fast-forward on GitHub. The Target branch
+	// must then always be an ancestor of Upstream.
+	FastForwardPush bool
+	// UpToDateIfSameTree makes a FastForwardOnly entry treat a Target branch as up to date if its
+	// tree is the same as the Upstream commit's tree, even if the commits differ. For example, after
+	// upstream changes are merged into Target manually, sync doesn't submit a redundant PR or fail
+	// because the branches have diverged. This only changes the behavior of a FastForwardPush
+	// entry: other entries are always up to date if Target has the same tree as Upstream.
+	UpToDateIfSameTree bool
+	// AutoMergeMethod is the GitHub merge method to use when enabling auto-merge on the PR:
+	// "MERGE" (default), "SQUASH", or "REBASE". A PR that merges Upstream must be completed with a
+	// merge commit, so only a SubmoduleTarget or FastForwardOnly entry may specify another method.
+	// A submodule update PR is a single commit, so "SQUASH" keeps the Target history tidy.
+	AutoMergeMethod string
+	// AutoMergeUsePRTitle sets the headline of the commit that completes the PR to the PR title.
+	// By default, GitHub appends the PR number to the headline, which adds noise to the history.
+	AutoMergeUsePRTitle bool
+
+	// ValidateCommand is an optional command and its arguments to run in the sync repo after
+	// merging each branch and before pushing it, for example ["go", "build", "./..."]. If the
+	// command fails, the branch isn't pushed and no PR is submitted. In a SubmoduleTarget entry,
+	// the submodule isn't checked out when the command runs.
+	ValidateCommand []string
+
+	// Timeout is an optional limit on how long sync may spend on this entry, as a Go duration
+	// string such as "30m". When it's exceeded, the Git commands sync is running for the entry are
+	// killed and the entry fails, so a stalled fetch or push doesn't hold up the remaining entries.
+	// The "entry-timeout" flag overrides this value.
+	Timeout string
 
 	// GoVersionFileContent	is empty, or the Go version that the microsoft/go build should use
 	// after the sync. Should be in the upstream format, e.g. go1.17.10 and go1.18. Sync examines
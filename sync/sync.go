@@ -5,15 +5,21 @@ package sync
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/microsoft/go-infra/azdo"
 	"github.com/microsoft/go-infra/executil"
@@ -24,6 +30,7 @@ import (
 
 type Flags struct {
 	DryRun          *bool
+	Verbose         *bool
 	InitialCloneDir *string
 
 	GitHubUser        *string
@@ -37,12 +44,82 @@ type Flags struct {
 
 	CreateBranches *bool
 
+	MaxBranchesPerEntry *int
+
+	MaxOpenPRs *int
+
+	BranchPurpose *string
+
+	CommitterName  *string
+	CommitterEmail *string
+
+	SignCommits   *bool
+	SigningKey    *string
+	SigningFormat *string
+
+	NoDiff *bool
+
+	UpstreamLog *bool
+
+	UpstreamCommitTrailer *bool
+
+	HeadRepo *string
+
+	MirrorOnly *bool
+
+	ValidateCommand *string
+
+	EntryTimeout *time.Duration
+
+	ReapproveExistingPRs *bool
+
+	NoAutoMerge *bool
+
 	GitAuthString *string
+
+	MetricsFile               *string
+	MetricsAzDOVariablePrefix *string
+
+	WebhookURL *string
+
+	PRAssignees *string
+
+	RequiredTokenScopes *string
+
+	// PRBackend submits and updates PRs. It isn't set by a flag. If nil, gitpr.DefaultClient is
+	// used. Tests set this to run the full sync flow without calling the GitHub API.
+	PRBackend PRBackend
+}
+
+// PRBackend is the set of GitHub API calls sync uses to submit PRs. *gitpr.Client implements it.
+type PRBackend interface {
+	EnsureFork(headOwnerRepo, targetOwnerRepo, pat string) error
+	CheckTokenScopes(required []string, pat string) error
+	GetDefaultBranch(ownerRepo, pat string) (string, error)
+	FindExistingPR(r *gitpr.GitHubRequest, head, target *gitpr.Remote, headBranch, submitterUser, githubPAT string) (*gitpr.ExistingPR, error)
+	PostGitHub(ownerRepo string, request *gitpr.GitHubRequest, pat string) (*gitpr.GitHubResponse, error)
+	UpdatePR(ownerRepo string, number int, title, body, pat string) error
+	AddAssignees(ownerRepo string, number int, assignees []string, pat string) error
+	CheckBaseBranch(nodeID string, pat string) error
+	ListOpenPRs(owner, headPrefix, pat string) ([]gitpr.ExistingPR, error)
+	ApprovePR(nodeID string, pat string) error
+	DismissReviews(nodeID string, pat string) error
+	IsAutoMergeEnabled(nodeID string, pat string) (bool, error)
+	WaitForMergeableState(nodeID string, timeout time.Duration, pat string) error
+	EnablePRAutoMergeWithOptions(nodeID string, pat string, opts *gitpr.AutoMergeOptions) error
+}
+
+func (f *Flags) prBackend() PRBackend {
+	if f.PRBackend != nil {
+		return f.PRBackend
+	}
+	return gitpr.DefaultClient
 }
 
 func BindFlags(workingDirectory string) *Flags {
 	return &Flags{
-		DryRun: flag.Bool("n", false, "Enable dry run: do not push, do not submit PR."),
+		DryRun:  flag.Bool("n", false, "Enable dry run: do not push, do not submit PR."),
+		Verbose: flag.Bool("v", false, "Log full GitHub API request and response bodies."),
 		InitialCloneDir: flag.String(
 			"initial-clone-dir", "",
 			"When creating a repo, clone this repo/directory rather than starting from scratch.\n"+
@@ -50,11 +127,11 @@ func BindFlags(workingDirectory string) *Flags {
 
 		GitHubUser:        flag.String("github-user", "", "Use this github user to submit pull requests."),
 		GitHubPAT:         flag.String("github-pat", "", "Submit the PR with this GitHub PAT, if specified."),
-		GitHubPATReviewer: flag.String("github-pat-reviewer", "", "Approve the PR and turn on auto-merge with this PAT, if specified. Required, if github-pat specified."),
+		GitHubPATReviewer: flag.String("github-pat-reviewer", "", "Approve the PR and turn on auto-merge with this PAT, if specified. Required, if github-pat specified, unless no-auto-merge is set."),
 
 		AzDODncengPAT: flag.String("azdo-dnceng-pat", "", "Use this Azure DevOps PAT to authenticate to dnceng project HTTPS Git URLs."),
 
-		SyncConfig: flag.String("c", "eng/sync-config.json", "The sync configuration file to run."),
+		SyncConfig: flag.String("c", "eng/sync-config.json", "The sync configuration file to run. Use \"-\" to read it from stdin, or an http(s) URL to download it."),
 		TempGitDir: flag.String(
 			"temp-git-dir",
 			filepath.Join(workingDirectory, "eng", "artifacts", "sync-upstream-temp-repo"),
@@ -63,7 +140,89 @@ func BindFlags(workingDirectory string) *Flags {
 		CreateBranches: flag.Bool(
 			"create-branches", false,
 			"Before running sync, check that each target branch exists in the target repo.\n"+
-				"If not, push it to the target repo as a fork from the configured MainBranch."),
+				"If not, push it to the target repo as a fork from the configured MainBranch.\n"+
+				"If MainBranch isn't configured, fork from the target repo's default branch."),
+
+		MaxBranchesPerEntry: flag.Int(
+			"max-branches-per-entry", 0,
+			"Process at most this many branches of each config entry, in config order, and defer the rest to a later run.\n"+
+				"0 means unlimited."),
+
+		MaxOpenPRs: flag.Int(
+			"max-open-prs", 0,
+			"Don't submit a new PR if the github-user already has this many open auto-sync PRs, and defer the branch to a later run.\n"+
+				"Existing PRs are still updated. 0 means unlimited."),
+
+		BranchPurpose: flag.String(
+			"branch-purpose", syncPRPurpose,
+			"The purpose part of each PR branch name, 'dev/{purpose}/{branch}'. Parallel test runs against the same repo\n"+
+				"can each use a different purpose to avoid clobbering each other's branches."),
+
+		CommitterName: flag.String(
+			"committer-name", "",
+			"The Git 'user.name' to create sync commits with. If not specified, uses the name from Git config.\n"+
+				"Git env vars such as GIT_AUTHOR_NAME take precedence."),
+		CommitterEmail: flag.String(
+			"committer-email", "",
+			"The Git 'user.email' to create sync commits with. If not specified, uses the email from Git config.\n"+
+				"Git env vars such as GIT_AUTHOR_EMAIL take precedence."),
+
+		SignCommits: flag.Bool(
+			"sign-commits", false,
+			"Sign each sync commit using 'git commit -S', then check that the commit has a signature.\n"+
+				"Uses the signing key and format from Git config unless 'signing-key' or 'signing-format' is specified."),
+		SigningKey: flag.String(
+			"signing-key", "",
+			"The key to sign commits with when 'sign-commits' is set: a GPG key ID, or for SSH, a key file path."),
+		SigningFormat: flag.String(
+			"signing-format", "",
+			"The Git 'gpg.format' to use when 'sign-commits' is set, such as 'openpgp' or 'ssh'."),
+
+		NoDiff: flag.Bool(
+			"no-diff", false,
+			"Don't compute the file difference between each PR branch and upstream for the PR description.\n"+
+				"The diff is only informational, and computing it may be slow in a large repo."),
+
+		UpstreamLog: flag.Bool(
+			"upstream-log", false,
+			"Include the list of upstream commits merged by each PR in the PR description, like 'git log --oneline'.\n"+
+				"The list is truncated if it's long. Not used for submodule updates or fast-forwards."),
+
+		UpstreamCommitTrailer: flag.Bool(
+			"upstream-commit-trailer", false,
+			"Add an '"+upstreamCommitTrailerKey+": <sha>' trailer to each upstream merge commit, naming the upstream commit that was merged.\n"+
+				"Submodule update commits already include the upstream commit."),
+
+		HeadRepo: flag.String(
+			"head-repo", "",
+			"Push the PR head branch of every entry to this GitHub repository, overriding the entry's Head.\n"+
+				"The PR is still opened against the entry's Target. If the repositories differ, this must be\n"+
+				"a fork of Target owned by a different user or org, such as a dedicated bot fork."),
+
+		MirrorOnly: flag.Bool(
+			"mirror-only", false,
+			"For each entry with a MirrorTarget, fetch upstream and push to the mirror, then stop.\n"+
+				"Skips merging and PR submission. Entries without a MirrorTarget are skipped entirely."),
+
+		ValidateCommand: flag.String(
+			"validate-command", "",
+			"Run this command in the sync repo after merging each branch and before pushing, overriding each entry's ValidateCommand.\n"+
+				"Arguments are separated by spaces. If the command fails, the branch isn't pushed and no PR is submitted."),
+
+		EntryTimeout: flag.Duration(
+			"entry-timeout", 0,
+			"Stop syncing a config entry if it takes longer than this, for example '30m', overriding each entry's Timeout.\n"+
+				"Git commands running for the entry are killed and the entry fails. Sync continues with the next entry."),
+
+		ReapproveExistingPRs: flag.Bool(
+			"reapprove-existing-prs", false,
+			"When sync pushes a new commit to an existing PR, dismiss the reviewer account's earlier approvals and approve again.\n"+
+				"Use this if the target repo's branch protection requires an approval of the latest commit."),
+
+		NoAutoMerge: flag.Bool(
+			"no-auto-merge", false,
+			"Submit PRs without enabling auto-merge, leaving them for manual review and merge.\n"+
+				"'github-pat-reviewer' isn't required in this mode. If it's specified, new PRs are still approved."),
 
 		GitAuthString: flag.String(
 			"git-auth",
@@ -73,6 +232,29 @@ func BindFlags(workingDirectory string) *Flags {
 				" none - Leave GitHub URLs as they are. Git may use HTTPS authentication in this case.\n"+
 				" ssh - Change the GitHub URL to SSH format.\n"+
 				" pat - Add the 'github-user' and 'github-pat' values into the URL.\n"),
+
+		MetricsFile: flag.String(
+			"metrics-file", "",
+			"After syncing, write the number of synced, skipped, and failed branches for each entry to this JSON file."),
+		MetricsAzDOVariablePrefix: flag.String(
+			"metrics-azdo-variable-prefix", "",
+			"After syncing, set AzDO variables with this prefix and the suffixes 'Synced', 'Skipped', and 'Failed'\n"+
+				"to the total number of branches with each result."),
+
+		WebhookURL: flag.String(
+			"webhook-url", "",
+			"After creating each PR, POST a JSON payload with the entry, branch, PR URL, and PR number to this URL.\n"+
+				"If the request fails, the failure is logged and sync continues."),
+
+		PRAssignees: flag.String(
+			"pr-assignees", "",
+			"A comma-separated list of GitHub users to assign to each new PR.\n"+
+				"If a user can't be assigned, the failure is logged and sync continues."),
+
+		RequiredTokenScopes: flag.String(
+			"required-token-scopes", "",
+			"A comma-separated list of classic OAuth scopes, such as 'repo,workflow', that github-pat must have.\n"+
+				"Checked before syncing to fail early. Skipped for a dry run, and for tokens that don't report scopes, like fine-grained PATs."),
 	}
 }
 
@@ -141,6 +323,209 @@ func (f *Flags) ParseAuth() (gitcmd.URLAuther, error) {
 	return nil, fmt.Errorf("git-auth value %q is not an accepted value.\n", *f.GitAuthString)
 }
 
+// ConfigureLogging sets up the gitpr logger based on the -v flag: without it, only a summary of
+// each GitHub API request is logged. The logger is process-wide, so call this once after parsing
+// flags rather than per entry.
+func (f *Flags) ConfigureLogging() {
+	if f.Verbose == nil || *f.Verbose {
+		return
+	}
+	gitpr.SetLogger(gitpr.NewLogger(slog.LevelInfo))
+}
+
+// signCommits returns true if sync commits should be signed.
+func (f *Flags) signCommits() bool {
+	return f.SignCommits != nil && *f.SignCommits
+}
+
+func (f *Flags) noDiff() bool {
+	return f.NoDiff != nil && *f.NoDiff
+}
+
+func (f *Flags) upstreamLog() bool {
+	return f.UpstreamLog != nil && *f.UpstreamLog
+}
+
+func (f *Flags) upstreamCommitTrailer() bool {
+	return f.UpstreamCommitTrailer != nil && *f.UpstreamCommitTrailer
+}
+
+func (f *Flags) githubPAT() string {
+	if f.GitHubPAT == nil {
+		return ""
+	}
+	return *f.GitHubPAT
+}
+
+// prAssignees returns the users to assign to each new PR.
+func (f *Flags) prAssignees() []string {
+	return splitCommaList(f.PRAssignees)
+}
+
+func (f *Flags) requiredTokenScopes() []string {
+	return splitCommaList(f.RequiredTokenScopes)
+}
+
+// checkTokenScopes checks that github-pat has the scopes given by the required-token-scopes flag.
+// A dry run doesn't submit PRs, so it doesn't need them.
+func (f *Flags) checkTokenScopes() error {
+	scopes := f.requiredTokenScopes()
+	if len(scopes) == 0 || *f.DryRun || f.githubPAT() == "" {
+		return nil
+	}
+	fmt.Printf("---- Checking that github-pat has scopes: %v\n", strings.Join(scopes, ", "))
+	return f.prBackend().CheckTokenScopes(scopes, f.githubPAT())
+}
+
+// splitCommaList splits a comma-separated flag value into its non-empty, trimmed items.
+func splitCommaList(s *string) []string {
+	if s == nil {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(*s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (f *Flags) mirrorOnly() bool {
+	return f.MirrorOnly != nil && *f.MirrorOnly
+}
+
+func (f *Flags) noAutoMerge() bool {
+	return f.NoAutoMerge != nil && *f.NoAutoMerge
+}
+
+func (f *Flags) reapproveExistingPRs() bool {
+	return f.ReapproveExistingPRs != nil && *f.ReapproveExistingPRs
+}
+
+// branchPurpose returns the purpose to use in PR branch names. See gitpr.PRRefSet.
+func (f *Flags) branchPurpose() string {
+	if f.BranchPurpose == nil || *f.BranchPurpose == "" {
+		return syncPRPurpose
+	}
+	return *f.BranchPurpose
+}
+
+// checkBranchPurpose returns an error if the branch-purpose flag doesn't produce valid Git branch
+// names.
+func (f *Flags) checkBranchPurpose() error {
+	b := gitpr.PRRefSet{Name: "main", Purpose: f.branchPurpose()}
+	if err := gitpr.CheckBranchName(b.PRBranch()); err != nil {
+		return fmt.Errorf("invalid branch-purpose %q: %w", f.branchPurpose(), err)
+	}
+	return nil
+}
+
+func (f *Flags) maxBranchesPerEntry() int {
+	if f.MaxBranchesPerEntry == nil {
+		return 0
+	}
+	return *f.MaxBranchesPerEntry
+}
+
+func (f *Flags) maxOpenPRs() int {
+	if f.MaxOpenPRs == nil {
+		return 0
+	}
+	return *f.MaxOpenPRs
+}
+
+// prBranchStorageRepo returns the repo to push the PR branches of entry to. The head-repo flag
+// takes precedence over the entry's config.
+func (f *Flags) prBranchStorageRepo(entry *ConfigEntry) string {
+	if f.HeadRepo != nil && *f.HeadRepo != "" {
+		return *f.HeadRepo
+	}
+	return entry.PRBranchStorageRepo()
+}
+
+// validateCommand returns the command to run to validate the merged result of entry, or nil if
+// there is none. The validate-command flag takes precedence over the entry's config.
+func (f *Flags) validateCommand(entry *ConfigEntry) []string {
+	if f.ValidateCommand != nil && *f.ValidateCommand != "" {
+		return strings.Fields(*f.ValidateCommand)
+	}
+	return entry.ValidateCommand
+}
+
+// entryTimeout returns the maximum time to spend syncing entry, or 0 if there is no limit. The
+// entry-timeout flag takes precedence over the entry's config.
+func (f *Flags) entryTimeout(entry *ConfigEntry) (time.Duration, error) {
+	if f.EntryTimeout != nil && *f.EntryTimeout != 0 {
+		return *f.EntryTimeout, nil
+	}
+	if entry.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(entry.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse Timeout: %w", err)
+	}
+	return d, nil
+}
+
+// checkSigningFlags returns an error if signing options are specified without enabling signing.
+func (f *Flags) checkSigningFlags() error {
+	if f.signCommits() {
+		return nil
+	}
+	if f.SigningKey != nil && *f.SigningKey != "" {
+		return errors.New("signing-key is specified but sign-commits is not")
+	}
+	if f.SigningFormat != nil && *f.SigningFormat != "" {
+		return errors.New("signing-format is specified but sign-commits is not")
+	}
+	return nil
+}
+
+// identityArgs returns the Git args that set the identity sync uses to create commits, as
+// requested by the flags. Merges check the identity even when they don't commit, so these args are
+// passed to every Git command that runs in the sync repo.
+func (f *Flags) identityArgs() []string {
+	var args []string
+	if f.CommitterName != nil && *f.CommitterName != "" {
+		args = append(args, "-c", "user.name="+*f.CommitterName)
+	}
+	if f.CommitterEmail != nil && *f.CommitterEmail != "" {
+		args = append(args, "-c", "user.email="+*f.CommitterEmail)
+	}
+	return args
+}
+
+// commitArgs returns the Git args to commit the stage with the given message, signing the commit
+// if requested by the flags.
+func (f *Flags) commitArgs(message string) []string {
+	var args []string
+	if !f.signCommits() {
+		return append(args, "commit", "-m", message)
+	}
+	if f.SigningFormat != nil && *f.SigningFormat != "" {
+		args = append(args, "-c", "gpg.format="+*f.SigningFormat)
+	}
+	sign := "-S"
+	if f.SigningKey != nil {
+		sign += *f.SigningKey
+	}
+	return append(args, "commit", sign, "-m", message)
+}
+
+// hasCommitSignature returns true if the raw commit object, as printed by "git cat-file commit",
+// contains a signature header.
+func hasCommitSignature(commitObject string) bool {
+	header, _, _ := strings.Cut(commitObject, "\n\n")
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ") {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *Flags) MakeGitWorkDir() (string, error) {
 	d, err := executil.MakeWorkDir(*f.TempGitDir)
 	if err != nil {
@@ -149,10 +534,63 @@ func (f *Flags) MakeGitWorkDir() (string, error) {
 	return d, nil
 }
 
+// CheckTempGitDir returns an error if the temp Git dir is inside a local repository used by any of
+// the given entries. Sync would otherwise create its temp repo inside the repo it's syncing, which
+// causes confusing recursive behavior. Only local paths are checked: URLs can't contain the dir.
+func (f *Flags) CheckTempGitDir(entries []ConfigEntry) error {
+	tempDir, err := filepath.Abs(*f.TempGitDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for _, repo := range []string{entry.Upstream, entry.UpstreamMirror, entry.Target, f.prBranchStorageRepo(&entry), entry.MirrorTarget} {
+			if repo == "" || strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@") {
+				continue
+			}
+			repoDir, err := filepath.Abs(repo)
+			if err != nil {
+				return err
+			}
+			if rel, err := filepath.Rel(repoDir, tempDir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("temp-git-dir %#q is inside the repository %#q used by a sync entry. Use a temp-git-dir outside the repository", tempDir, repoDir)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadConfig reads the sync config entries from the source given by the "c" flag: a file path,
+// "-" to read from stdin, or an http(s) URL.
 func (f *Flags) ReadConfig() ([]ConfigEntry, error) {
+	return readConfig(*f.SyncConfig, os.Stdin)
+}
+
+// configClient downloads a sync config given as a URL.
+var configClient = &http.Client{Timeout: gitpr.DefaultHTTPTimeout}
+
+func readConfig(source string, stdin io.Reader) ([]ConfigEntry, error) {
 	var entries []ConfigEntry
-	if err := stringutil.ReadJSONFile(*f.SyncConfig, &entries); err != nil {
-		return nil, fmt.Errorf("failed to read sync config file: %w", err)
+	switch {
+	case source == "-":
+		if err := stringutil.ReadJSON(stdin, "from stdin", &entries); err != nil {
+			return nil, fmt.Errorf("failed to read sync config: %w", err)
+		}
+	case strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://"):
+		resp, err := configClient.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download sync config: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to download sync config from %v: http status %v, %v", source, resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		if err := stringutil.ReadJSON(resp.Body, "from "+source, &entries); err != nil {
+			return nil, fmt.Errorf("failed to read sync config: %w", err)
+		}
+	default:
+		if err := stringutil.ReadJSONFile(source, &entries); err != nil {
+			return nil, fmt.Errorf("failed to read sync config file: %w", err)
+		}
 	}
 	return entries, nil
 }
@@ -165,6 +603,10 @@ func (f *Flags) ReadConfig() ([]ConfigEntry, error) {
 // issues, but other tools have hit some, and it seems reasonable to set a limit ahead of time.
 const maxDiffLinesToDisplay = 200
 
+// maxUpstreamLogLinesToDisplay is the number of upstream commits to list in the PR description
+// before truncating the rest. See maxDiffLinesToDisplay.
+const maxUpstreamLogLinesToDisplay = 100
+
 var (
 	// maxUpstreamCommitMessageInSnippet is the maximum number of characters to include in the
 	// commit message snippet for a submodule update commit message. The snippet gives context to
@@ -187,9 +629,22 @@ const (
 	GitAuthPAT  GitAuthOption = "pat"
 )
 
+// mergeableStateTimeout is the maximum time to wait for GitHub to determine whether a new or
+// updated PR is mergeable before enabling auto-merge.
+const mergeableStateTimeout = 2 * time.Minute
+
+// upstreamCommitTrailerKey is the Git trailer that names the upstream commit merged by a sync
+// commit. See Flags.UpstreamCommitTrailer.
+const upstreamCommitTrailerKey = "Upstream-Commit"
+
+// syncPRPurpose is the default PRRefSet purpose of sync PRs. See Flags.BranchPurpose.
+const syncPRPurpose = "auto-sync"
+
 var errWouldCreateBranchButCurrentlyDryRun = errors.New("would have pushed a new branch to the target repository to kick off a new version, but this is a dry run. Cannot continue")
 
 func MakePRs(f *Flags) error {
+	f.ConfigureLogging()
+
 	entries, err := f.ReadConfig()
 	if err != nil {
 		return err
@@ -199,6 +654,10 @@ func MakePRs(f *Flags) error {
 		fmt.Printf("No entries found in config file: %v\n", *f.SyncConfig)
 	}
 
+	if err := f.CheckTempGitDir(entries); err != nil {
+		return err
+	}
+
 	currentRunGitDir, err := f.MakeGitWorkDir()
 	if err != nil {
 		return err
@@ -208,24 +667,42 @@ func MakePRs(f *Flags) error {
 	if _, err := f.ParseAuth(); err != nil {
 		return err
 	}
+	if err := f.checkTokenScopes(); err != nil {
+		return err
+	}
 
 	success := true
+	metrics := make([]EntryMetrics, 0, len(entries))
 
 	for i, entry := range entries {
 		syncNum := fmt.Sprintf("%v/%v", i+1, len(entries))
-		fmt.Printf("=== Beginning sync %v, from %v -> %v\n", syncNum, entry.Upstream, entry.Target)
+		if f.mirrorOnly() {
+			if entry.MirrorTarget == "" {
+				fmt.Printf("=== Skipping sync %v, from %v: no MirrorTarget\n", syncNum, entry.Upstream)
+				continue
+			}
+			fmt.Printf("=== Beginning mirror %v, from %v -> %v\n", syncNum, entry.Upstream, entry.MirrorTarget)
+		} else {
+			fmt.Printf("=== Beginning sync %v, from %v -> %v\n", syncNum, entry.Upstream, entry.Target)
 
-		fmt.Printf("--- Repository for PR branch: %v\n", entry.PRBranchStorageRepo())
+			fmt.Printf("--- Repository for PR branch: %v\n", f.prBranchStorageRepo(&entry))
+		}
 
 		// Give each entry a unique dir to avoid interfering with others upon failure.
 		repositoryDir := path.Join(currentRunGitDir, strconv.Itoa(i))
 
-		if _, err := MakeBranchPRs(f, repositoryDir, &entry); err != nil {
+		results, err := MakeBranchPRs(f, repositoryDir, &entry)
+		if err != nil {
 			// Let sync process continue if an error happens with the current entry.
 			fmt.Println(err)
 			fmt.Printf("=== Failed sync %v\n", syncNum)
 			success = false
 		}
+		metrics = append(metrics, newEntryMetrics(&entry, f.maxBranchesPerEntry(), results, err))
+	}
+
+	if err := f.reportMetrics(metrics); err != nil {
+		return err
 	}
 
 	if !success {
@@ -242,6 +719,9 @@ type changedBranch struct {
 	ExistingPR *gitpr.ExistingPR
 
 	SkipReason string
+	// DirectPush is true if the change is pushed directly to the base branch rather than
+	// submitted as a PR. See ConfigEntry.FastForwardPush.
+	DirectPush bool
 
 	// Result contains this branch's [SyncResult] once a PR is submitted/updated.
 	Result *SyncResult
@@ -256,31 +736,92 @@ type SyncResult struct {
 	// Commit is the commit hash that contains the updated result. This is either the commit that
 	// was pushed for the PR, or a commit that already exists in the target repo.
 	Commit string
+	// Failed is true if a sync commit was created for this branch, but it failed validation or
+	// submitting the PR failed.
+	Failed bool
+	// Pushed is true if Commit was pushed directly to the target branch rather than submitted as a
+	// PR. See ConfigEntry.FastForwardPush.
+	Pushed bool
 }
 
 // MakeBranchPRs creates sync changes for each branch in the given entry and submits them as PRs.
 // Multiple branches are processed at the same time in order to efficiently use Git: it is better to
 // tell Git to fetch/push multiple branches at the same time than run the operations individually.
-// Returns an error, or the sync results of each branch.
+// Returns an error, or the sync results of each branch. If the only failure was in submitting PRs,
+// returns both the results and an error: results with Failed set indicate which branches failed.
+//
+// If the entry has a timeout, Git commands still running when it expires are killed and
+// MakeBranchPRs returns an error that wraps context.DeadlineExceeded.
 func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, error) {
+	timeout, err := f.entryTimeout(entry)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	results, err := makeBranchPRs(ctx, f, dir, entry)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return results, fmt.Errorf("sync timed out after %v (%w): %w", timeout, ctx.Err(), err)
+	}
+	return results, err
+}
+
+func makeBranchPRs(ctx context.Context, f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, error) {
 	auther, err := f.ParseAuth()
 	if err != nil {
 		return nil, err
 	}
 
+	if err := f.checkSigningFlags(); err != nil {
+		return nil, err
+	}
+	if err := f.checkBranchPurpose(); err != nil {
+		return nil, err
+	}
+
+	if entry.SubmoduleTarget != "" && entry.TargetSubdir != "" {
+		return nil, errors.New("SubmoduleTarget and TargetSubdir can't both be specified")
+	}
+	if entry.TargetSubdir != "" && len(entry.AutoResolveTheirsTarget) > 0 {
+		return nil, errors.New("AutoResolveTheirsTarget can't be used with TargetSubdir")
+	}
+	mergeMethod, err := gitpr.ParseMergeMethod(entry.AutoMergeMethod)
+	if err != nil {
+		return nil, err
+	}
+	if entry.SubmoduleTarget == "" && !entry.FastForwardOnly && mergeMethod != gitpr.MergeMethodMerge {
+		return nil, fmt.Errorf("AutoMergeMethod %v requires SubmoduleTarget or FastForwardOnly: a PR that merges upstream must use a merge commit", mergeMethod)
+	}
+	if entry.FastForwardOnly {
+		if entry.SubmoduleTarget != "" || entry.TargetSubdir != "" || len(entry.AutoResolveTarget) > 0 || len(entry.AutoResolveTheirsTarget) > 0 || len(entry.ExcludePaths) > 0 {
+			return nil, errors.New("FastForwardOnly can't be used with SubmoduleTarget, TargetSubdir, AutoResolveTarget, AutoResolveTheirsTarget, or ExcludePaths")
+		}
+	} else if entry.FastForwardPush {
+		return nil, errors.New("FastForwardPush requires FastForwardOnly")
+	}
+	targetSubdir := strings.Trim(filepath.ToSlash(entry.TargetSubdir), "/")
+	if f.mirrorOnly() && entry.MirrorTarget == "" {
+		return nil, errors.New("the mirror-only flag requires MirrorTarget to be configured, but it is not")
+	}
+
 	if *f.InitialCloneDir == "" {
-		if err := run(exec.Command("git", "init", dir)); err != nil {
+		if err := run(exec.CommandContext(ctx, "git", "init", dir)); err != nil {
 			return nil, err
 		}
 	} else {
-		if err := run(exec.Command("git", "clone", *f.InitialCloneDir, dir)); err != nil {
+		if err := run(exec.CommandContext(ctx, "git", "clone", *f.InitialCloneDir, dir)); err != nil {
 			return nil, err
 		}
 	}
 
 	// newGitCmd creates a "git {args}" command that runs in the temp fetch repo Git dir.
+	identityArgs := f.identityArgs()
 	newGitCmd := func(args ...string) *exec.Cmd {
-		c := exec.Command("git", args...)
+		c := exec.CommandContext(ctx, "git", slices.Concat(identityArgs, args)...)
 		c.Dir = dir
 		return c
 	}
@@ -298,7 +839,7 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 			UpstreamName: upstream,
 			PRRefSet: gitpr.PRRefSet{
 				Name:    target,
-				Purpose: "auto-sync",
+				Purpose: f.branchPurpose(),
 			},
 		}
 		if commit, ok := entry.SourceBranchLatestCommit[upstream]; ok {
@@ -306,6 +847,11 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 		}
 		branches = append(branches, nb)
 	}
+	var deferred []string
+	branches, deferred = limitBranches(branches, f.maxBranchesPerEntry())
+	if len(deferred) > 0 {
+		fmt.Printf("---- Processing %v branches. Deferred to a later run: %v\n", len(branches), deferred)
+	}
 	// Auto-mirrored branches are simpler: always get the latest commits to
 	// push to the mirror repo with the same branch name.
 	autoMirrorBranches := make([]*gitpr.MirrorRefSet, 0, len(entry.AutoMirrorBranches))
@@ -315,26 +861,32 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 		})
 	}
 
-	if *f.CreateBranches {
-		if entry.MainBranch == "" {
-			return nil, errors.New("the create-branches flag requires MainBranch to be configured, but it is not")
-		}
-
+	if *f.CreateBranches && !f.mirrorOnly() {
+		mainBranch := entry.MainBranch
 		for _, b := range branches {
-			check := newGitCmd("ls-remote", "--exit-code", "--heads", entry.Target, "refs/heads/"+b.Name)
-			if err := run(check); err != nil {
-				exitErr, ok := err.(*exec.ExitError)
-				if !ok {
-					return nil, err
-				}
-				if exitErr.ExitCode() != 2 {
-					return nil, err
+			exists, err := gitcmd.RemoteRefExists(dir, entry.Target, "refs/heads/"+b.Name, auther)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				if mainBranch == "" {
+					// Fall back to the default branch of the target repo. Only look it up once
+					// it's needed, to avoid an API call in the common case that all branches exist.
+					parsedTarget, err := gitpr.ParseRemoteURL(entry.Target)
+					if err != nil {
+						return nil, err
+					}
+					mainBranch, err = f.prBackend().GetDefaultBranch(parsedTarget.GetOwnerSlashRepo(), f.githubPAT())
+					if err != nil {
+						return nil, fmt.Errorf("MainBranch isn't configured and the default branch of the target repo is unknown: %w", err)
+					}
+					fmt.Printf("---- MainBranch isn't configured. Forking new branches from the default branch of the target repo, %#q.\n", mainBranch)
 				}
 
 				// Get a reference to the main branch to fork from.
 				mainRef := gitpr.PRRefSet{
-					Name:    entry.MainBranch,
-					Purpose: "auto-sync-new-branch",
+					Name:    mainBranch,
+					Purpose: f.branchPurpose() + "-new-branch",
 				}
 				fetchMain := newGitCmd(
 					"fetch", "--no-tags",
@@ -363,6 +915,25 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 		}
 	}
 
+	// Parse the URLs involved in the PR to get segment information. Check that the PR can be
+	// submitted before doing any work, to fail early if the head repository is misconfigured.
+	parsedPRTargetRemote, err := gitpr.ParseRemoteURL(entry.Target)
+	if err != nil {
+		return nil, err
+	}
+	parsedPRHeadRemote, err := gitpr.ParseRemoteURL(f.prBranchStorageRepo(entry))
+	if err != nil {
+		return nil, err
+	}
+	if err := gitpr.CheckCrossForkRemotes(parsedPRHeadRemote, parsedPRTargetRemote); err != nil {
+		return nil, err
+	}
+	if parsedPRHeadRemote.GetOwnerSlashRepo() != parsedPRTargetRemote.GetOwnerSlashRepo() && !*f.DryRun && *f.GitHubPAT != "" && !f.mirrorOnly() {
+		if err := f.prBackend().EnsureFork(parsedPRHeadRemote.GetOwnerSlashRepo(), parsedPRTargetRemote.GetOwnerSlashRepo(), *f.GitHubPAT); err != nil {
+			return nil, err
+		}
+	}
+
 	// Fetch latest from remotes. We fetch with one big Git command with many refspecs, instead of
 	// simply looping across every branch. This keeps round-trips to a minimum and may benefit from
 	// innate Git parallelism. Later in the process, we do a batched "push" for the same reasons.
@@ -381,8 +952,10 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 	if err := run(fetchUpstream); err != nil {
 		return nil, err
 	}
-	if err := run(fetchOrigin); err != nil {
-		return nil, err
+	if !f.mirrorOnly() {
+		if err := run(fetchOrigin); err != nil {
+			return nil, err
+		}
 	}
 
 	// Fetch the state of the official/upstream-maintained mirror (if specified) so we can check
@@ -415,15 +988,9 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 			return nil, err
 		}
 	}
-
-	// Parse the URLs involved in the PR to get segment information.
-	parsedPRTargetRemote, err := gitpr.ParseRemoteURL(entry.Target)
-	if err != nil {
-		return nil, err
-	}
-	parsedPRHeadRemote, err := gitpr.ParseRemoteURL(entry.PRBranchStorageRepo())
-	if err != nil {
-		return nil, err
+	if f.mirrorOnly() {
+		fmt.Printf("---- Mirrored %v branches to %v. Skipping merge and PR submission.\n", len(branches)+len(autoMirrorBranches), entry.MirrorTarget)
+		return nil, nil
 	}
 
 	// Track the sync results. In this first section, we figure out the result's Commit value. In
@@ -435,6 +1002,32 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 	// branches have changes, so we can push changes and submit PRs later.
 	changedBranches := make([]changedBranch, 0, len(branches))
 
+	// openPRs is the number of open auto-sync PRs, or -1 if they haven't been counted yet. See
+	// Flags.MaxOpenPRs. deferredPRs lists the upstream names of branches that need a new PR but
+	// exceed the limit.
+	openPRs := -1
+	var deferredPRs []string
+
+	// validate runs the validation command, if any, against the merged result in the work tree.
+	// If it fails, c is marked as failed so it isn't pushed. Returns false if validation failed.
+	validateCmd := f.validateCommand(entry)
+	var validationFailed bool
+	validate := func(c *changedBranch) bool {
+		if len(validateCmd) == 0 {
+			return true
+		}
+		cmd := exec.CommandContext(ctx, validateCmd[0], validateCmd[1:]...)
+		cmd.Dir = dir
+		if err := run(cmd); err != nil {
+			fmt.Printf("---- Validation of %q failed: %v\n", c.Refs.Name, err)
+			c.SkipReason = "Validation failed: " + err.Error()
+			c.Result.Failed = true
+			validationFailed = true
+			return false
+		}
+		return true
+	}
+
 	for i, b := range branches {
 		fmt.Printf("---- Processing branch %q for entry targeting %v\n", b.Name, entry.Target)
 
@@ -448,14 +1041,135 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 		})
 		c := &changedBranches[len(changedBranches)-1]
 
-		prBody := "Hi! I'm a bot, and this is an automatically generated upstream sync PR. 🔃" +
-			"\n\nAfter submitting the PR, I will attempt to enable auto-merge in the \"merge commit\" configuration." +
-			"\n\nFor more information, visit [sync documentation in microsoft/go-infra](https://github.com/microsoft/go-infra/tree/main/docs/automation/sync.md)."
+		prBody := "Hi! I'm a bot, and this is an automatically generated upstream sync PR. 🔃"
+		if f.noAutoMerge() {
+			prBody += "\n\nI won't enable auto-merge on this PR: it's left for manual review and merge."
+		} else {
+			prBody += fmt.Sprintf("\n\nAfter submitting the PR, I will attempt to enable auto-merge in the %q configuration.", mergeMethodDescriptions[mergeMethod])
+		}
+		prBody += "\n\nFor more information, visit [sync documentation in microsoft/go-infra](https://github.com/microsoft/go-infra/tree/main/docs/automation/sync.md)."
 		var prTitle, commitMessage string
 
-		if entry.SubmoduleTarget == "" {
+		if entry.FastForwardOnly {
+			// Move the branch to the upstream commit without a merge commit, if possible.
+			headCommit, err := combinedOutput(newGitCmd("rev-parse", "HEAD"))
+			if err != nil {
+				return nil, err
+			}
+			upstreamCommit, err := combinedOutput(newGitCmd("rev-parse", b.UpstreamLocalSyncTarget()))
+			if err != nil {
+				return nil, err
+			}
+			if headCommit == upstreamCommit {
+				c.SkipReason = "No changes to sync"
+				c.Result.Commit = headCommit
+				continue
+			}
+			if entry.UpToDateIfSameTree {
+				headTree, err := combinedOutput(newGitCmd("rev-parse", "HEAD^{tree}"))
+				if err != nil {
+					return nil, err
+				}
+				upstreamTree, err := combinedOutput(newGitCmd("rev-parse", b.UpstreamLocalSyncTarget()+"^{tree}"))
+				if err != nil {
+					return nil, err
+				}
+				if headTree == upstreamTree {
+					fmt.Printf("---- Target commit %v has the same tree as upstream commit %v.\n", strings.TrimSpace(headCommit), strings.TrimSpace(upstreamCommit))
+					c.SkipReason = "No changes to sync: the target has the same tree as upstream"
+					c.Result.Commit = headCommit
+					continue
+				}
+			}
+			if err := run(newGitCmd("merge-base", "--is-ancestor", "HEAD", b.UpstreamLocalSyncTarget())); err != nil {
+				if _, ok := err.(*exec.ExitError); !ok {
+					return nil, err
+				}
+				// GitHub completes a fast-forward PR with a merge commit or with rewritten commits,
+				// so afterwards, the target isn't an ancestor of upstream. It's still tracking
+				// upstream exactly if it has the same tree as an upstream commit. A direct push
+				// keeps the upstream commits, so this doesn't apply.
+				var mergedCommit string
+				if !entry.FastForwardPush {
+					mergedCommit, err = findUpstreamCommitWithTree(newGitCmd, "HEAD", b.UpstreamLocalSyncTarget())
+					if err != nil {
+						return nil, err
+					}
+				}
+				if mergedCommit == "" {
+					return nil, fmt.Errorf(
+						"unable to fast-forward %v to upstream %v: the branches have diverged. Target commit %v isn't an ancestor of upstream commit %v",
+						b.Name, b.UpstreamName, strings.TrimSpace(headCommit), strings.TrimSpace(upstreamCommit))
+				}
+				fmt.Printf("---- Target commit %v has the same tree as upstream commit %v: a previous sync PR was merged.\n", strings.TrimSpace(headCommit), mergedCommit)
+				if mergedCommit == strings.TrimSpace(upstreamCommit) {
+					c.SkipReason = "No changes to sync: the target has the same tree as upstream"
+					c.Result.Commit = headCommit
+					continue
+				}
+				// The PR branch is force pushed, so it can point at the upstream commit even though
+				// the target branch isn't its ancestor. Completing the PR merges upstream again.
+				if err := run(newGitCmd("reset", "--hard", b.UpstreamLocalSyncTarget())); err != nil {
+					return nil, err
+				}
+			} else if err := run(newGitCmd("merge", "--ff-only", b.UpstreamLocalSyncTarget())); err != nil {
+				return nil, err
+			}
+			c.Result.Commit = upstreamCommit
+			if !validate(c) {
+				continue
+			}
+
+			if entry.FastForwardPush {
+				c.DirectPush = true
+				if *f.DryRun {
+					c.SkipReason = "Dry run"
+				}
+				continue
+			}
+			prTitle = fmt.Sprintf("Fast-forward %#q to upstream %#q", b.Name, b.UpstreamName)
+			prBody += fmt.Sprintf(
+				"\n\nThis PR fast-forwards %#q to %#q. It contains only upstream commits.",
+				c.Refs.Name, c.Refs.UpstreamName,
+			)
+		} else if entry.SubmoduleTarget == "" {
 			// This is not a submodule update, so merge with the upstream repository.
-			if err := run(newGitCmd("merge", "--no-ff", "--no-commit", b.UpstreamLocalSyncTarget())); err != nil {
+			merge := newGitCmd("merge", "--no-ff", "--no-commit", b.UpstreamLocalSyncTarget())
+			if targetSubdir != "" {
+				// Map upstream paths into the subdir. A subtree merge can't add the subdir for the
+				// first time, so if it doesn't exist yet, record the merge without taking any
+				// upstream changes, then read the upstream tree into the subdir.
+				if err := run(newGitCmd("rev-parse", "--verify", "--quiet", "HEAD:"+targetSubdir)); err != nil {
+					if _, ok := err.(*exec.ExitError); !ok {
+						return nil, err
+					}
+					fmt.Printf("---- Target subdir %#q doesn't exist yet. Adding upstream content to it.\n", targetSubdir)
+					merge = newGitCmd("merge", "--no-ff", "--no-commit", "--allow-unrelated-histories", "-s", "ours", b.UpstreamLocalSyncTarget())
+					if err := run(merge); err != nil {
+						return nil, err
+					}
+					merge = newGitCmd("read-tree", "--prefix="+targetSubdir+"/", "-u", b.UpstreamLocalSyncTarget())
+				} else {
+					merge.Args = append(merge.Args, "-Xsubtree="+targetSubdir)
+				}
+			}
+			// List the upstream commits before the merge moves HEAD.
+			var upstreamLog string
+			if f.upstreamLog() {
+				log, err := combinedOutput(newGitCmd("log", "--oneline", "--no-decorate", "HEAD.."+b.UpstreamLocalSyncTarget()))
+				if err != nil {
+					return nil, err
+				}
+				var truncated bool
+				upstreamLog, truncated, err = truncateLines(log, maxUpstreamLogLinesToDisplay)
+				if err != nil {
+					return nil, err
+				}
+				if truncated {
+					upstreamLog += fmt.Sprintf("Commit list truncated: contains more than %v commits.\n", maxUpstreamLogLinesToDisplay)
+				}
+			}
+			if err := run(merge); err != nil {
 				if exitError, ok := err.(*exec.ExitError); ok {
 					fmt.Printf("---- Merge hit an ExitError: %q. A non-zero exit code is expected if there were conflicts. The script will try to resolve them, next.\n", exitError)
 				} else {
@@ -464,6 +1178,13 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 				}
 			}
 
+			if len(entry.AutoResolveTheirsTarget) > 0 {
+				// Take these paths from upstream. This runs first so AutoResolveTarget wins if a path
+				// is in both lists. '--no-overlay' deletes files that upstream has deleted.
+				if err := run(newGitCmd(append([]string{"checkout", "--no-overlay", b.UpstreamLocalSyncTarget(), "--"}, entry.AutoResolveTheirsTarget...)...)); err != nil {
+					return nil, err
+				}
+			}
 			if len(entry.AutoResolveTarget) > 0 {
 				// Automatically resolve conflicts in specific project doc files. Use '--no-overlay' to make
 				// sure we delete new files in e.g. '.github' that are in upstream but don't exist locally.
@@ -472,12 +1193,48 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 					return nil, err
 				}
 			}
+			for _, p := range entry.ExcludePaths {
+				// Remove whatever the merge left at the path, resolving any conflict, then restore
+				// the Target version if there is one.
+				if err := run(newGitCmd("rm", "-r", "-f", "-q", "--ignore-unmatch", "--", p)); err != nil {
+					return nil, err
+				}
+				if err := run(newGitCmd("rev-parse", "--verify", "--quiet", "HEAD:"+p)); err != nil {
+					if _, ok := err.(*exec.ExitError); !ok {
+						return nil, err
+					}
+					continue
+				}
+				if err := run(newGitCmd("checkout", "HEAD", "--", p)); err != nil {
+					return nil, err
+				}
+			}
 			prTitle = fmt.Sprintf("Merge upstream %#q into %#q", b.UpstreamName, b.Name)
 			prBody += fmt.Sprintf(
 				"\n\nThis PR merges %#q into %#q.\n\nIf PR validation fails and you need to fix up the PR, make sure to use a merge commit, not a squash or rebase!",
 				c.Refs.UpstreamName, c.Refs.Name,
 			)
 			commitMessage = fmt.Sprintf("Merge upstream branch %q into %v", b.UpstreamName, b.Name)
+			if targetSubdir != "" {
+				prBody += fmt.Sprintf("\n\nUpstream content is merged into the %#q directory.", targetSubdir)
+				commitMessage += fmt.Sprintf(" (%v)", targetSubdir)
+			}
+			if upstreamLog != "" {
+				prBody += fmt.Sprintf(
+					"\n\n"+
+						"<details><summary>Click on this text to view the upstream commits merged by this PR.</summary>\n\n"+
+						"```\n%v\n```"+
+						"\n\n</details>",
+					upstreamLog,
+				)
+			}
+			if f.upstreamCommitTrailer() {
+				upstreamCommit, err := combinedOutput(newGitCmd("rev-parse", b.UpstreamLocalSyncTarget()))
+				if err != nil {
+					return nil, err
+				}
+				commitMessage += "\n\n" + upstreamCommitTrailerKey + ": " + strings.TrimSpace(upstreamCommit)
+			}
 		} else {
 			// This is a submodule update. We'll be doing more evaluation to figure out which commit
 			// to update to, so define a helper func with captured context.
@@ -599,41 +1356,56 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 			if err != nil {
 				return nil, err
 			}
-			snippet := createCommitMessageSnippet(upstreamCommitMessage)
 
 			prTitle = fmt.Sprintf("Update submodule to latest %#q in %#q", b.UpstreamName, b.Name)
-			commitMessage = fmt.Sprintf("Update submodule to latest %v (%v): %v", b.UpstreamName, newCommit[:8], snippet)
+			commitMessage = submoduleCommitMessage(b.UpstreamName, newCommit, upstreamCommitMessage)
 		}
 
-		// Check if there are any files in the stage. If not, we don't need to process this branch
-		// anymore, because the merge + autoresolve didn't change anything.
-		if err := run(newGitCmd("diff", "--cached", "--quiet")); err != nil {
-			if _, ok := err.(*exec.ExitError); ok {
-				fmt.Printf("---- Detected changes in Git stage. Continuing to commit and submit PR.\n")
+		// A fast-forward has no merge or submodule update to commit: the upstream commit is the
+		// result.
+		if !entry.FastForwardOnly {
+			// Check if there are any files in the stage. If not, we don't need to process this branch
+			// anymore, because the merge + autoresolve didn't change anything.
+			if err := run(newGitCmd("diff", "--cached", "--quiet")); err != nil {
+				if _, ok := err.(*exec.ExitError); ok {
+					fmt.Printf("---- Detected changes in Git stage. Continuing to commit and submit PR.\n")
+				} else {
+					// Make sure we don't ignore more than we intended.
+					return nil, err
+				}
 			} else {
-				// Make sure we don't ignore more than we intended.
-				return nil, err
+				// If the diff had 0 exit code, there are no changes. Skip this branch's next steps.
+				c.SkipReason = "No changes to sync"
+
+				// Save the current commit in the result struct. This lets the caller know exactly what
+				// commit was found to be up to date, to avoid racing with other changes being merged
+				// into the target repo.
+				commit, err := combinedOutput(newGitCmd("rev-parse", "HEAD"))
+				if err != nil {
+					return nil, err
+				}
+				c.Result.Commit = commit
+
+				continue
 			}
-		} else {
-			// If the diff had 0 exit code, there are no changes. Skip this branch's next steps.
-			c.SkipReason = "No changes to sync"
 
-			// Save the current commit in the result struct. This lets the caller know exactly what
-			// commit was found to be up to date, to avoid racing with other changes being merged
-			// into the target repo.
-			commit, err := combinedOutput(newGitCmd("rev-parse", "HEAD"))
-			if err != nil {
+			// If we still have unmerged files, 'git commit' will exit non-zero, causing the script to
+			// exit. This prevents the script from pushing a bad merge.
+			if err := run(newGitCmd(f.commitArgs(commitMessage)...)); err != nil {
 				return nil, err
 			}
-			c.Result.Commit = commit
-
-			continue
-		}
-
-		// If we still have unmerged files, 'git commit' will exit non-zero, causing the script to
-		// exit. This prevents the script from pushing a bad merge.
-		if err := run(newGitCmd("commit", "-m", commitMessage)); err != nil {
-			return nil, err
+			if f.signCommits() {
+				// Git exits non-zero if it fails to sign, but make sure a signature really ended up in
+				// the commit. The target repo's signature requirement would otherwise block the PR.
+				// Validating the signature against trusted keys is left to the target repo.
+				commitObject, err := combinedOutput(newGitCmd("cat-file", "commit", "HEAD"))
+				if err != nil {
+					return nil, err
+				}
+				if !hasCommitSignature(commitObject) {
+					return nil, errors.New("sync commit isn't signed, but sign-commits is set")
+				}
+			}
 		}
 
 		// Save the created commit in the result struct.
@@ -642,16 +1414,25 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 			return nil, err
 		}
 		c.Result.Commit = commit
+		// A fast-forward was already validated before deciding whether to push it directly.
+		if !entry.FastForwardOnly && !validate(c) {
+			continue
+		}
 
-		if entry.SubmoduleTarget == "" {
+		if entry.SubmoduleTarget == "" && !entry.FastForwardOnly && !f.noDiff() {
 			// Show a summary of which files are in our fork branch vs. upstream. This is just
 			// informational. CI is a better place to *enforce* a low diff: it's more visible, can
 			// be fixed up more easily, and doesn't block other branch mirror/merge operations.
+			// If upstream is merged into a subdir, only compare that subdir.
+			prTree := b.PRBranch()
+			if targetSubdir != "" {
+				prTree += ":" + targetSubdir
+			}
 			diff, err := combinedOutput(newGitCmd(
 				"diff",
 				"--name-status",
 				b.UpstreamLocalBranch(),
-				b.PRBranch(),
+				prTree,
 			))
 			if err != nil {
 				return nil, err
@@ -659,20 +1440,13 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 
 			// The diff may be large. Truncate it if it seems unreasonable to show on the console, or to
 			// include in a PR description. The user can use Git to dig deeper if needed.
-			var diffLines strings.Builder
-			diffLineScanner := bufio.NewScanner(strings.NewReader(diff))
-			for lineNumber := 0; diffLineScanner.Scan(); lineNumber++ {
-				if err := diffLineScanner.Err(); err != nil {
-					return nil, err
-				}
-				if lineNumber == maxDiffLinesToDisplay {
-					diffLines.WriteString(fmt.Sprintf("Diff truncated: contains more than %v lines.\n", maxDiffLinesToDisplay))
-					break
-				}
-				diffLines.WriteString(diffLineScanner.Text())
-				diffLines.WriteString("\n")
+			diffString, truncated, err := truncateLines(diff, maxDiffLinesToDisplay)
+			if err != nil {
+				return nil, err
+			}
+			if truncated {
+				diffString += fmt.Sprintf("Diff truncated: contains more than %v lines.\n", maxDiffLinesToDisplay)
 			}
-			diffString := diffLines.String()
 
 			if diffString != "" {
 				prBody += fmt.Sprintf(
@@ -696,17 +1470,16 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 		case *f.GitHubPAT == "":
 			c.SkipReason = "github-pat not provided"
 
-		case *f.GitHubPATReviewer == "":
-			// In theory, if we have githubPAT but no reviewer, we can submit the PR but skip
-			// reviewing it/enabling auto-merge. However, this doesn't seem very useful, so it isn't
-			// implemented.
+		case *f.GitHubPATReviewer == "" && !f.noAutoMerge():
+			// The reviewer approves the PR and enables auto-merge. To submit a PR without them, use
+			// the no-auto-merge flag.
 			c.SkipReason = "github-pat-reviewer not provided"
 		}
 		if c.SkipReason != "" {
 			continue
 		}
 
-		c.ExistingPR, err = gitpr.FindExistingPR(
+		c.ExistingPR, err = f.prBackend().FindExistingPR(
 			c.PRRequest,
 			parsedPRHeadRemote,
 			parsedPRTargetRemote,
@@ -717,11 +1490,20 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 			return nil, err
 		}
 		if c.ExistingPR != nil {
+			// The existing PR can't be updated if its base branch was deleted or renamed since it
+			// was submitted. Leave it for a maintainer to close rather than failing the entry.
+			if err := f.prBackend().CheckBaseBranch(c.ExistingPR.ID, *f.GitHubPAT); err != nil {
+				if !errors.Is(err, gitpr.ErrBaseBranchMissing) {
+					return nil, err
+				}
+				c.SkipReason = fmt.Sprintf("PR #%v already exists, but its base branch is missing: %v", c.ExistingPR.Number, err)
+				continue
+			}
 			// If the PR already exists, we need to check if anyone else has pushed changes to the
 			// branch to make sure we don't overwrite them.
 			remoteCommit, err := gitcmd.FetchRefCommit(
 				dir,
-				auther.InsertAuth(entry.PRBranchStorageRepo()),
+				auther.InsertAuth(f.prBranchStorageRepo(entry)),
 				"refs/heads/"+c.Refs.PRBranch())
 			if err != nil {
 				return nil, err
@@ -742,8 +1524,26 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 					remoteAuthorEmail +
 					"). Skipping PR submission."
 			}
+		} else if maxOpenPRs := f.maxOpenPRs(); maxOpenPRs > 0 {
+			// Only count the open PRs once we know a new one is needed. PRs submitted by earlier
+			// entries in the same run are included in the count.
+			if openPRs < 0 {
+				prs, err := f.prBackend().ListOpenPRs(*f.GitHubUser, "dev/"+f.branchPurpose()+"/", *f.GitHubPAT)
+				if err != nil {
+					return nil, err
+				}
+				openPRs = len(prs)
+			}
+			if openPRs >= maxOpenPRs {
+				c.SkipReason = fmt.Sprintf("%v auto-sync PRs are already open, reaching max-open-prs", openPRs)
+				deferredPRs = append(deferredPRs, c.Refs.UpstreamName)
+				continue
+			}
+			openPRs++
 		}
-
+	}
+	if len(deferredPRs) > 0 {
+		fmt.Printf("---- Reached the limit of %v open PRs. Deferred to a later run: %v\n", f.maxOpenPRs(), deferredPRs)
 	}
 
 	if len(changedBranches) == 0 {
@@ -772,14 +1572,26 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 	// changes from an old PR. There are ways to handle this, but no clear benefit. Force push is
 	// simple and makes the PR flow simple.
 	mergePushRefspecs := make([]string, 0, len(changedBranches))
+	// Fast-forward the base branches that don't need a PR. Don't force push: if the target branch
+	// has moved since we fetched it, the push must fail rather than discard the new commits.
+	directPushRefspecs := make([]string, 0, len(changedBranches))
 	for _, b := range changedBranches {
 		if b.SkipReason != "" {
 			continue
 		}
+		if b.DirectPush {
+			directPushRefspecs = append(directPushRefspecs, b.Refs.BaseBranchPushRefspec())
+			continue
+		}
 		mergePushRefspecs = append(mergePushRefspecs, b.Refs.PRBranchRefspec())
 	}
 	if len(mergePushRefspecs) > 0 {
-		if err := run(newGitPushCommand(entry.PRBranchStorageRepo(), true, mergePushRefspecs)); err != nil {
+		if err := run(newGitPushCommand(f.prBranchStorageRepo(entry), true, mergePushRefspecs)); err != nil {
+			return nil, err
+		}
+	}
+	if len(directPushRefspecs) > 0 {
+		if err := run(newGitPushCommand(entry.Target, false, directPushRefspecs)); err != nil {
 			return nil, err
 		}
 	}
@@ -799,6 +1611,11 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 			fmt.Printf("---- %s: skipping submitting PR: %s\n", prFlowDescription, b.SkipReason)
 			continue
 		}
+		if b.DirectPush {
+			fmt.Printf("---- %s: pushed directly to %v, no PR needed.\n", prFlowDescription, b.Refs.Name)
+			b.Result.Pushed = true
+			continue
+		}
 
 		// err contains any err we get from running the sequence of GitHub PR submission API calls.
 		//
@@ -811,7 +1628,7 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 
 			// POST the PR. The call returns success if the PR is created or if we receive a
 			// specific error message back from GitHub saying the PR is already created.
-			pr, err := gitpr.PostGitHub(parsedPRTargetRemote.GetOwnerSlashRepo(), b.PRRequest, *f.GitHubPAT)
+			pr, err := f.prBackend().PostGitHub(parsedPRTargetRemote.GetOwnerSlashRepo(), b.PRRequest, *f.GitHubPAT)
 			if err != nil {
 				if errors.Is(err, gitpr.ErrPRAlreadyExists) {
 					if b.ExistingPR == nil {
@@ -821,6 +1638,23 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 						NodeID: b.ExistingPR.ID,
 						Number: b.ExistingPR.Number,
 					}
+					// The existing PR now contains the latest merge, so keep its description
+					// current with the new diff.
+					fmt.Printf("---- Updating existing PR #%v title and description...\n", pr.Number)
+					if err := f.prBackend().UpdatePR(parsedPRTargetRemote.GetOwnerSlashRepo(), pr.Number, b.PRRequest.Title, b.PRRequest.Body, *f.GitHubPAT); err != nil {
+						return err
+					}
+					if f.reapproveExistingPRs() && *f.GitHubPATReviewer != "" {
+						// The approval, if any, is for an earlier commit. Replace it so it's for the
+						// commit we just pushed.
+						fmt.Printf("---- Dismissing earlier approvals and approving again with reviewer account...\n")
+						if err := f.prBackend().DismissReviews(pr.NodeID, *f.GitHubPATReviewer); err != nil {
+							return err
+						}
+						if err := f.prBackend().ApprovePR(pr.NodeID, *f.GitHubPATReviewer); err != nil {
+							return err
+						}
+					}
 				} else {
 					return err
 				}
@@ -830,15 +1664,56 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 					return fmt.Errorf("submitted a fresh PR, but failure was expected because an existing PR was found")
 				}
 				fmt.Printf("---- Submitted brand new PR: %v\n", pr.HTMLURL)
+				f.notifyPRCreated(entry, &b, pr)
+
+				if assignees := f.prAssignees(); len(assignees) > 0 {
+					fmt.Printf("---- Assigning PR to %v...\n", strings.Join(assignees, ", "))
+					// Assignees help triage, but the PR is still useful without them.
+					if err := f.prBackend().AddAssignees(parsedPRTargetRemote.GetOwnerSlashRepo(), pr.Number, assignees, *f.GitHubPAT); err != nil {
+						fmt.Printf("---- Unable to assign PR, continuing: %v\n", err)
+					}
+				}
 
-				fmt.Printf("---- Approving with reviewer account...\n")
-				if err = gitpr.ApprovePR(pr.NodeID, *f.GitHubPATReviewer); err != nil {
+				if *f.GitHubPATReviewer != "" {
+					fmt.Printf("---- Approving with reviewer account...\n")
+					if err = f.prBackend().ApprovePR(pr.NodeID, *f.GitHubPATReviewer); err != nil {
+						return err
+					}
+				}
+			}
+
+			if f.noAutoMerge() {
+				fmt.Printf("---- Not enabling auto-merge: no-auto-merge is set. Done.\n")
+				b.Result.PR = pr
+				return nil
+			}
+
+			enabled, err := f.prBackend().IsAutoMergeEnabled(pr.NodeID, *f.GitHubPATReviewer)
+			if err != nil {
+				return err
+			}
+			if enabled {
+				fmt.Printf("---- Auto-merge is already enabled. Done.\n")
+				b.Result.PR = pr
+				return nil
+			}
+
+			// GitHub computes whether the PR is mergeable asynchronously. Enabling auto-merge before
+			// it's known may fail, so wait for it. If it takes too long, try anyway.
+			fmt.Printf("---- Waiting for GitHub to determine whether the PR is mergeable...\n")
+			if err := f.prBackend().WaitForMergeableState(pr.NodeID, mergeableStateTimeout, *f.GitHubPATReviewer); err != nil {
+				if !errors.Is(err, gitpr.ErrMergeableStateUnknown) {
 					return err
 				}
+				fmt.Printf("---- %v. Continuing.\n", err)
 			}
 
 			fmt.Printf("---- Enabling auto-merge with reviewer account...\n")
-			if err = gitpr.EnablePRAutoMerge(pr.NodeID, *f.GitHubPATReviewer); err != nil {
+			autoMergeOptions := &gitpr.AutoMergeOptions{Method: mergeMethod}
+			if entry.AutoMergeUsePRTitle {
+				autoMergeOptions.CommitHeadline = b.PRRequest.Title
+			}
+			if err = f.prBackend().EnablePRAutoMergeWithOptions(pr.NodeID, *f.GitHubPATReviewer, autoMergeOptions); err != nil {
 				return err
 			}
 
@@ -857,6 +1732,7 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 		// why we want to try to keep processing branches.
 		if err != nil {
 			fmt.Println(err)
+			b.Result.Failed = true
 			prFailed = true
 			continue
 		}
@@ -864,12 +1740,74 @@ func MakeBranchPRs(f *Flags, dir string, entry *ConfigEntry) ([]SyncResult, erro
 
 	// If PR submission failed for any branch, exit the overall script with NZEC.
 	if prFailed {
-		return nil, fmt.Errorf("failed to submit one or more PRs")
+		return results, fmt.Errorf("failed to submit one or more PRs")
+	}
+	if validationFailed {
+		return results, fmt.Errorf("one or more branches failed validation and weren't pushed")
 	}
 
 	return results, nil
 }
 
+// mergeMethodDescriptions are the names GitHub uses for each merge method in its UI.
+var mergeMethodDescriptions = map[gitpr.MergeMethod]string{
+	gitpr.MergeMethodMerge:  "merge commit",
+	gitpr.MergeMethodSquash: "squash",
+	gitpr.MergeMethodRebase: "rebase",
+}
+
+// limitBranches returns the first max branches, and the upstream names of the remaining branches.
+// If max is 0 or less, returns all branches.
+func limitBranches(branches []*gitpr.SyncPRRefSet, max int) ([]*gitpr.SyncPRRefSet, []string) {
+	n := limitBranchCount(len(branches), max)
+	if n == len(branches) {
+		return branches, nil
+	}
+	deferred := make([]string, 0, len(branches)-n)
+	for _, b := range branches[n:] {
+		deferred = append(deferred, b.UpstreamName)
+	}
+	return branches[:n], deferred
+}
+
+// limitBranchCount returns the number of branches limitBranches keeps out of n.
+func limitBranchCount(n, max int) int {
+	if max <= 0 || n <= max {
+		return n
+	}
+	return max
+}
+
+// findUpstreamCommitWithTree returns the upstream commit that has the same tree as the target
+// commit, or "" if there isn't one. Only upstream commits that aren't already in target's history,
+// and the merge base itself, are checked. newGitCmd creates a Git command in the repo.
+func findUpstreamCommitWithTree(newGitCmd func(args ...string) *exec.Cmd, target, upstream string) (string, error) {
+	targetTree, err := combinedOutput(newGitCmd("rev-parse", target+"^{tree}"))
+	if err != nil {
+		return "", err
+	}
+	mergeBase, err := combinedOutput(newGitCmd("merge-base", target, upstream))
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// No common history.
+			return "", nil
+		}
+		return "", err
+	}
+	mergeBase = strings.TrimSpace(mergeBase)
+	out, err := combinedOutput(newGitCmd("log", "--format=%H %T", upstream, "--not", mergeBase+"^@"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		commit, tree, ok := strings.Cut(line, " ")
+		if ok && tree == strings.TrimSpace(targetTree) {
+			return commit, nil
+		}
+	}
+	return "", nil
+}
+
 // run sets up the command so it logs directly to our stdout/stderr streams, then runs it.
 func run(c *exec.Cmd) error {
 	fmt.Printf("---- Running command: %v %v\n", c.Path, c.Args)
@@ -888,6 +1826,46 @@ func combinedOutput(c *exec.Cmd) (string, error) {
 	return string(out), nil
 }
 
+// submoduleCommitMessage returns the message of the commit that updates a submodule to commit, the
+// latest commit in upstreamBranch. upstreamMessage is the message of commit.
+func submoduleCommitMessage(upstreamBranch, commit, upstreamMessage string) string {
+	return fmt.Sprintf("Update submodule to latest %v (%v): %v", upstreamBranch, commit[:8], createCommitMessageSnippet(upstreamMessage))
+}
+
+// PreviewSubmoduleCommitMessage returns the message of the commit that sync would create to update
+// a submodule to ref, an upstream commit or branch that has already been fetched into the Git repo
+// at dir. upstreamBranch is the name of the upstream branch to show in the message. If empty, ref
+// is used.
+func PreviewSubmoduleCommitMessage(dir, upstreamBranch, ref string) (string, error) {
+	commit, err := gitcmd.RevParse(dir, ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to find commit %q in %v: %w", ref, dir, err)
+	}
+	upstreamMessage, err := gitcmd.CombinedOutput(dir, "log", "--format=%B", "-n", "1", commit)
+	if err != nil {
+		return "", err
+	}
+	if upstreamBranch == "" {
+		upstreamBranch = ref
+	}
+	return submoduleCommitMessage(upstreamBranch, commit, strings.TrimSpace(upstreamMessage)), nil
+}
+
+// truncateLines returns the first max lines of s, each ending with a newline. If s has more lines,
+// truncated is true.
+func truncateLines(s string, max int) (lines string, truncated bool, err error) {
+	var b strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for lineNumber := 0; scanner.Scan(); lineNumber++ {
+		if lineNumber == max {
+			return b.String(), true, nil
+		}
+		b.WriteString(scanner.Text())
+		b.WriteString("\n")
+	}
+	return b.String(), false, scanner.Err()
+}
+
 func createCommitMessageSnippet(message string) string {
 	if i := strings.IndexAny(message, "\r\n"); i >= 0 {
 		message = message[:i]
@@ -4,12 +4,23 @@
 package sync
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/microsoft/go-infra/gitpr"
 )
 
 func Test_createCommitMessageSnippet(t *testing.T) {
@@ -53,6 +64,255 @@ func Test_createCommitMessageSnippet(t *testing.T) {
 	}
 }
 
+func TestPreviewSubmoduleCommitMessage(t *testing.T) {
+	d := t.TempDir()
+	if err := setupMockRepo(d, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(d, "release-notes.md", "Bug has been fixed"); err != nil {
+		t.Fatal(err)
+	}
+	commit := gitOutput(t, d, "rev-parse", "HEAD")
+
+	for _, tt := range []struct {
+		branch, want string
+	}{
+		{"", "Update submodule to latest main (" + commit[:8] + "): Add release-notes.md"},
+		{"release-branch.go1.22", "Update submodule to latest release-branch.go1.22 (" + commit[:8] + "): Add release-notes.md"},
+	} {
+		got, err := PreviewSubmoduleCommitMessage(d, tt.branch, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("PreviewSubmoduleCommitMessage(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+
+	if _, err := PreviewSubmoduleCommitMessage(d, "", "missing"); err == nil {
+		t.Error("PreviewSubmoduleCommitMessage() of missing ref succeeded, want error")
+	}
+}
+
+func Test_newEntryMetrics(t *testing.T) {
+	entry := &ConfigEntry{
+		Upstream:         "https://example.org/upstream",
+		Target:           "https://example.org/target",
+		AutoSyncBranches: []string{"main", "release-branch.go1.18", "release-branch.go1.19"},
+	}
+	tests := []struct {
+		name        string
+		maxBranches int
+		results     []SyncResult
+		err         error
+		want        EntryMetrics
+	}{
+		{
+			"mixed",
+			0,
+			[]SyncResult{
+				{PR: &gitpr.GitHubResponse{Number: 1}, Commit: "a"},
+				{Commit: "b"},
+				{Commit: "c", Failed: true},
+			},
+			errors.New("failed to submit one or more PRs"),
+			EntryMetrics{Synced: 1, Skipped: 1, Failed: 1},
+		},
+		{
+			"all up to date",
+			0,
+			[]SyncResult{{Commit: "a"}, {Commit: "b"}, {Commit: "c"}},
+			nil,
+			EntryMetrics{Skipped: 3},
+		},
+		{
+			"pushed",
+			0,
+			[]SyncResult{{Commit: "a", Pushed: true}, {Commit: "b"}, {Commit: "c"}},
+			nil,
+			EntryMetrics{Synced: 1, Skipped: 2},
+		},
+		{
+			"entry failed",
+			0,
+			nil,
+			errors.New("fetch failed"),
+			EntryMetrics{Failed: 3},
+		},
+		{
+			"entry failed with deferred branches",
+			2,
+			nil,
+			errors.New("fetch failed"),
+			EntryMetrics{Failed: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Upstream = entry.Upstream
+			tt.want.Target = entry.Target
+			if got := newEntryMetrics(entry, tt.maxBranches, tt.results, tt.err); got != tt.want {
+				t.Errorf("newEntryMetrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlags_CheckTempGitDir(t *testing.T) {
+	d := t.TempDir()
+	repo := filepath.Join(d, "repo")
+	tests := []struct {
+		name       string
+		tempGitDir string
+		entry      ConfigEntry
+		wantErr    bool
+	}{
+		{"remote URLs", filepath.Join(repo, "temp"), ConfigEntry{Upstream: "https://go.googlesource.com/go", Target: "git@github.com:microsoft/go"}, false},
+		{"outside", filepath.Join(d, "temp"), ConfigEntry{Upstream: repo, Target: repo}, false},
+		{"sibling with same prefix", repo + "-temp", ConfigEntry{Target: repo}, false},
+		{"inside target", filepath.Join(repo, "eng", "artifacts"), ConfigEntry{Target: repo}, true},
+		{"inside head", filepath.Join(repo, "temp"), ConfigEntry{Target: "https://github.com/microsoft/go", Head: repo}, true},
+		{"same as upstream", repo, ConfigEntry{Upstream: repo}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Flags{TempGitDir: &tt.tempGitDir}
+			if err := f.CheckTempGitDir([]ConfigEntry{tt.entry}); (err != nil) != tt.wantErr {
+				t.Errorf("CheckTempGitDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_readConfig(t *testing.T) {
+	const config = `[{"Upstream": "https://go.googlesource.com/go", "Target": "https://github.com/microsoft/go"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sync-config.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(config))
+	}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "sync-config.json")
+	if err := os.WriteFile(file, []byte(config), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		stdin   string
+		wantErr bool
+	}{
+		{"file", file, "", false},
+		{"stdin", "-", config, false},
+		{"stdin with BOM", "-", "\ufeff" + config, false},
+		{"bad stdin", "-", "{", true},
+		{"URL", server.URL + "/sync-config.json", "", false},
+		{"URL not found", server.URL + "/missing.json", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := readConfig(tt.source, strings.NewReader(tt.stdin))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readConfig() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readConfig() unexpected error: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Target != "https://github.com/microsoft/go" {
+				t.Errorf("readConfig() = %+v, want one entry", entries)
+			}
+		})
+	}
+}
+
+func Test_truncateLines(t *testing.T) {
+	tests := []struct {
+		s             string
+		max           int
+		want          string
+		wantTruncated bool
+	}{
+		{"", 2, "", false},
+		{"a\nb", 2, "a\nb\n", false},
+		{"a\nb\n", 2, "a\nb\n", false},
+		{"a\nb\nc\n", 2, "a\nb\n", true},
+	}
+	for _, tt := range tests {
+		got, truncated, err := truncateLines(tt.s, tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want || truncated != tt.wantTruncated {
+			t.Errorf("truncateLines(%q, %v) = %q, %v, want %q, %v", tt.s, tt.max, got, truncated, tt.want, tt.wantTruncated)
+		}
+	}
+}
+
+func TestFlags_checkTokenScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		dryRun  bool
+		pat     string
+		scopes  string
+		wantErr error
+	}{
+		{"no required scopes", false, "pat", "", nil},
+		{"has scopes", false, "pat", "repo", nil},
+		{"missing scope", false, "pat", "repo, workflow", gitpr.ErrMissingTokenScopes},
+		{"dry run", true, "pat", "workflow", nil},
+		{"no PAT", false, "", "workflow", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Flags{
+				DryRun:              &tt.dryRun,
+				GitHubPAT:           &tt.pat,
+				RequiredTokenScopes: &tt.scopes,
+				PRBackend:           &fakePRBackend{scopes: map[string]bool{"repo": true}},
+			}
+			if err := f.checkTokenScopes(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkTokenScopes() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_limitBranches(t *testing.T) {
+	branches := []*gitpr.SyncPRRefSet{
+		{UpstreamName: "main"},
+		{UpstreamName: "release-branch.go1.18"},
+		{UpstreamName: "release-branch.go1.19"},
+	}
+	tests := []struct {
+		name         string
+		max          int
+		wantKept     int
+		wantDeferred []string
+	}{
+		{"unlimited", 0, 3, nil},
+		{"above count", 5, 3, nil},
+		{"equal count", 3, 3, nil},
+		{"limited", 1, 1, []string{"release-branch.go1.18", "release-branch.go1.19"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, deferred := limitBranches(branches, tt.max)
+			if len(kept) != tt.wantKept {
+				t.Errorf("limitBranches() kept %v branches, want %v", len(kept), tt.wantKept)
+			}
+			if !reflect.DeepEqual(deferred, tt.wantDeferred) {
+				t.Errorf("limitBranches() deferred = %v, want %v", deferred, tt.wantDeferred)
+			}
+		})
+	}
+}
+
 func Test_MakeBranchPRs_VersionUpdate(t *testing.T) {
 	makeFlags := func(createBranches bool) *Flags {
 		trueBool := true
@@ -220,6 +480,1367 @@ func Test_MakeBranchPRs_VersionUpdate(t *testing.T) {
 	}
 }
 
+func Test_MakeBranchPRs_CreateBranchFromDefaultBranch(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultBranch string
+		wantErr       error
+	}{
+		{"default branch", "microsoft/main", errWouldCreateBranchButCurrentlyDryRun},
+		{"unknown default branch", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trueBool := true
+			none := "none"
+			var emptyString string
+			flags := &Flags{
+				DryRun:          &trueBool,
+				GitAuthString:   &none,
+				InitialCloneDir: &emptyString,
+				CreateBranches:  &trueBool,
+				PRBackend:       &fakePRBackend{defaultBranch: tt.defaultBranch},
+			}
+
+			d := t.TempDir()
+			target := filepath.Join(d, "target") + "/microsoft/go"
+			upstream := filepath.Join(d, "upstream") + "/golang/go"
+			workDir := filepath.Join(d, "work")
+
+			if err := setupMockRepo(upstream, "main"); err != nil {
+				t.Fatal(err)
+			}
+			if err := setupMockRepo(target, "microsoft/main"); err != nil {
+				t.Fatal(err)
+			}
+
+			// MainBranch isn't configured, so the new target branch is forked from the default
+			// branch of the target repo.
+			c := &ConfigEntry{
+				Upstream:         upstream,
+				Target:           target,
+				BranchMap:        map[string]string{"release-branch*": "microsoft/release-branch?"},
+				AutoSyncBranches: []string{"release-branch.go1.18"},
+			}
+			_, err := MakeBranchPRs(flags, workDir, c)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MakeBranchPRs() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "default branch") {
+				t.Fatalf("MakeBranchPRs() error = %v, want default branch error", err)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_TargetSubdir(t *testing.T) {
+	trueBool, falseBool := true, false
+	none := "none"
+	var emptyString string
+	flags := &Flags{
+		DryRun:          &trueBool,
+		GitAuthString:   &none,
+		InitialCloneDir: &emptyString,
+		CreateBranches:  &falseBool,
+	}
+
+	tests := []struct {
+		name         string
+		subdirExists bool
+	}{
+		{"add subdir", false},
+		{"update subdir", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d := t.TempDir()
+			target := filepath.Join(d, "target") + "/microsoft/monorepo"
+			upstream := filepath.Join(d, "upstream") + "/golang/go"
+			workDir := filepath.Join(d, "work")
+
+			if err := setupMockRepo(upstream, "main"); err != nil {
+				t.Fatal(err)
+			}
+			if err := setupMockRepo(target, "main"); err != nil {
+				t.Fatal(err)
+			}
+			if err := addMockFile(target, "README.md", "Monorepo"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.subdirExists {
+				// Add the current upstream content to the subdir, the same way sync would.
+				if err := runGit(target, "fetch", upstream, "main:upstream"); err != nil {
+					t.Fatal(err)
+				}
+				if err := runGit(target, "merge", "--allow-unrelated-histories", "-s", "ours", "--no-commit", "upstream"); err != nil {
+					t.Fatal(err)
+				}
+				if err := runGit(target, "read-tree", "--prefix=third_party/go/", "-u", "upstream"); err != nil {
+					t.Fatal(err)
+				}
+				if err := runGit(target, "commit", "-m", "Add upstream"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Simulate an upstream change that needs to be synced.
+			if err := addMockFile(upstream, "release-notes.md", "Bug has been fixed"); err != nil {
+				t.Fatal(err)
+			}
+
+			c := &ConfigEntry{
+				Upstream:         upstream,
+				Target:           target,
+				BranchMap:        map[string]string{"main": "main"},
+				AutoSyncBranches: []string{"main"},
+				TargetSubdir:     "third_party/go",
+			}
+
+			if _, err := MakeBranchPRs(flags, workDir, c); err != nil {
+				t.Fatal(err)
+			}
+
+			ensureFileContent(t, filepath.Join(workDir, "README.md"), "Monorepo")
+			ensureFileContent(t, filepath.Join(workDir, "third_party", "go", "README.md"), "Hello")
+			ensureFileContent(t, filepath.Join(workDir, "third_party", "go", "release-notes.md"), "Bug has been fixed")
+			ensureMissing(t, filepath.Join(workDir, "release-notes.md"))
+		})
+	}
+}
+
+// fakePRBackend is a PRBackend that keeps track of PRs in memory rather than calling GitHub.
+type fakePRBackend struct {
+	// prs maps the PR head, in "owner:branch" form, to the PR.
+	prs map[string]*gitpr.GitHubResponse
+	// missingBase is the set of PR node IDs whose base branch was deleted.
+	missingBase map[string]bool
+	// conflicting makes every PR conflict with its base branch.
+	conflicting bool
+	// defaultBranch is the default branch of every repo.
+	defaultBranch string
+	// assignable is the set of users that can be assigned to a PR.
+	assignable map[string]bool
+	// assigned maps each PR number to the users assigned to it.
+	assigned map[int][]string
+	// scopes is the set of scopes every token has.
+	scopes     map[string]bool
+	posted     []*gitpr.GitHubRequest
+	updated    []int
+	approved   []string
+	dismissed  []string
+	autoMerged []string
+	// autoMergeOptions are the options of each auto-merge call, in the same order as autoMerged.
+	autoMergeOptions []*gitpr.AutoMergeOptions
+}
+
+func (b *fakePRBackend) EnsureFork(headOwnerRepo, targetOwnerRepo, pat string) error {
+	return nil
+}
+
+func (b *fakePRBackend) GetDefaultBranch(ownerRepo, pat string) (string, error) {
+	if b.defaultBranch == "" {
+		return "", errors.New("fake repo has no default branch")
+	}
+	return b.defaultBranch, nil
+}
+
+func (b *fakePRBackend) AddAssignees(ownerRepo string, number int, assignees []string, pat string) error {
+	if b.assigned == nil {
+		b.assigned = make(map[int][]string)
+	}
+	var missing []string
+	for _, a := range assignees {
+		if b.assignable[a] {
+			b.assigned[number] = append(b.assigned[number], a)
+		} else {
+			missing = append(missing, a)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %v", gitpr.ErrAssigneesNotAdded, missing)
+	}
+	return nil
+}
+
+func (b *fakePRBackend) CheckTokenScopes(required []string, pat string) error {
+	for _, scope := range required {
+		if !b.scopes[scope] {
+			return fmt.Errorf("%w: %v", gitpr.ErrMissingTokenScopes, scope)
+		}
+	}
+	return nil
+}
+
+func (b *fakePRBackend) FindExistingPR(r *gitpr.GitHubRequest, head, target *gitpr.Remote, headBranch, submitterUser, githubPAT string) (*gitpr.ExistingPR, error) {
+	pr, ok := b.prs[r.Head]
+	if !ok {
+		return nil, nil
+	}
+	return &gitpr.ExistingPR{Title: r.Title, ID: pr.NodeID, Number: pr.Number}, nil
+}
+
+func (b *fakePRBackend) PostGitHub(ownerRepo string, request *gitpr.GitHubRequest, pat string) (*gitpr.GitHubResponse, error) {
+	if _, ok := b.prs[request.Head]; ok {
+		return nil, fmt.Errorf("%w: fake PR for %v", gitpr.ErrPRAlreadyExists, request.Head)
+	}
+	if b.prs == nil {
+		b.prs = make(map[string]*gitpr.GitHubResponse)
+	}
+	b.posted = append(b.posted, request)
+	n := len(b.prs) + 1
+	pr := &gitpr.GitHubResponse{
+		HTMLURL: "https://example.org/" + ownerRepo + "/pull/" + strconv.Itoa(n),
+		NodeID:  "PR_" + strconv.Itoa(n),
+		Number:  n,
+	}
+	b.prs[request.Head] = pr
+	return pr, nil
+}
+
+func (b *fakePRBackend) UpdatePR(ownerRepo string, number int, title, body, pat string) error {
+	b.updated = append(b.updated, number)
+	return nil
+}
+
+func (b *fakePRBackend) ListOpenPRs(owner, headPrefix, pat string) ([]gitpr.ExistingPR, error) {
+	var prs []gitpr.ExistingPR
+	for head, pr := range b.prs {
+		if _, branch, _ := strings.Cut(head, ":"); strings.HasPrefix(branch, headPrefix) {
+			prs = append(prs, gitpr.ExistingPR{ID: pr.NodeID, Number: pr.Number})
+		}
+	}
+	return prs, nil
+}
+
+func (b *fakePRBackend) CheckBaseBranch(nodeID string, pat string) error {
+	if b.missingBase[nodeID] {
+		return fmt.Errorf("%w: fake PR %v", gitpr.ErrBaseBranchMissing, nodeID)
+	}
+	return nil
+}
+
+func (b *fakePRBackend) ApprovePR(nodeID string, pat string) error {
+	b.approved = append(b.approved, nodeID)
+	return nil
+}
+
+func (b *fakePRBackend) DismissReviews(nodeID string, pat string) error {
+	b.dismissed = append(b.dismissed, nodeID)
+	return nil
+}
+
+func (b *fakePRBackend) IsAutoMergeEnabled(nodeID string, pat string) (bool, error) {
+	return slices.Contains(b.autoMerged, nodeID), nil
+}
+
+func (b *fakePRBackend) WaitForMergeableState(nodeID string, timeout time.Duration, pat string) error {
+	if b.conflicting {
+		return fmt.Errorf("%w: fake PR %v", gitpr.ErrPRConflicting, nodeID)
+	}
+	return nil
+}
+
+func (b *fakePRBackend) EnablePRAutoMergeWithOptions(nodeID string, pat string, opts *gitpr.AutoMergeOptions) error {
+	b.autoMerged = append(b.autoMerged, nodeID)
+	b.autoMergeOptions = append(b.autoMergeOptions, opts)
+	return nil
+}
+
+// syncTest is a set of local repos to run sync against without dry run, using a fake PR backend
+// instead of GitHub. Tests adjust flags, backend, and the entry to exercise a specific feature.
+type syncTest struct {
+	// dir is a temp dir for repos and work dirs.
+	dir string
+	// upstream is a repo with a "main" branch, simulating golang/go. target is a bare clone of
+	// upstream, simulating microsoft/go. The paths end in "/<owner>/<repo>" so they can be parsed
+	// as if they're GitHub repository URLs.
+	upstream, target string
+
+	flags   *Flags
+	backend *fakePRBackend
+
+	// dirs is the number of dirs created by newDir.
+	dirs int
+}
+
+func newSyncTest(t *testing.T) *syncTest {
+	t.Helper()
+	falseBool := false
+	none := "none"
+	user, pat, reviewerPAT := "bot", "pat", "reviewer-pat"
+	var emptyString string
+
+	d := t.TempDir()
+	s := &syncTest{
+		dir:      d,
+		upstream: filepath.Join(d, "upstream") + "/golang/go",
+		target:   filepath.Join(d, "target") + "/microsoft/go",
+		backend:  &fakePRBackend{},
+	}
+	s.flags = &Flags{
+		DryRun:            &falseBool,
+		GitHubUser:        &user,
+		GitHubPAT:         &pat,
+		GitHubPATReviewer: &reviewerPAT,
+		GitAuthString:     &none,
+		InitialCloneDir:   &emptyString,
+		CreateBranches:    &falseBool,
+		PRBackend:         s.backend,
+	}
+
+	if err := setupMockRepo(s.upstream, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(exec.Command("git", "clone", "--bare", s.upstream, s.target)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// entry returns a config entry that syncs upstream main into target main.
+func (s *syncTest) entry() *ConfigEntry {
+	return &ConfigEntry{
+		Upstream:         s.upstream,
+		Target:           s.target,
+		BranchMap:        map[string]string{"main": "main"},
+		AutoSyncBranches: []string{"main"},
+	}
+}
+
+// addUpstreamChange simulates an upstream change that needs to be synced.
+func (s *syncTest) addUpstreamChange(t *testing.T) {
+	t.Helper()
+	if err := addMockFile(s.upstream, "release-notes.md", "Bug has been fixed"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newDir returns the path of a new dir in s.dir that doesn't exist yet.
+func (s *syncTest) newDir(name string) string {
+	s.dirs++
+	return filepath.Join(s.dir, name+strconv.Itoa(s.dirs))
+}
+
+// cloneTarget clones target into a new work tree, so the test can push commits to it.
+func (s *syncTest) cloneTarget(t *testing.T) string {
+	t.Helper()
+	clone := s.newDir("clone")
+	if err := run(exec.Command("git", "clone", s.target, clone)); err != nil {
+		t.Fatal(err)
+	}
+	return clone
+}
+
+// addTargetCommit pushes a commit to target main that upstream doesn't have.
+func (s *syncTest) addTargetCommit(t *testing.T, relativePath, content string) {
+	t.Helper()
+	clone := s.cloneTarget(t)
+	if err := addMockFile(clone, relativePath, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGit(clone, "push", "origin", "main"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sync runs MakeBranchPRs with a new work dir.
+func (s *syncTest) sync(c *ConfigEntry) ([]SyncResult, error) {
+	return MakeBranchPRs(s.flags, s.newDir("work"), c)
+}
+
+// Test_MakeBranchPRs_FullFlow runs sync without dry run against local repos, using a fake PR
+// backend instead of GitHub. It syncs twice to cover creating a PR and updating an existing one.
+func Test_MakeBranchPRs_FullFlow(t *testing.T) {
+	s := newSyncTest(t)
+	c := s.entry()
+
+	for i := range 2 {
+		if i == 1 && !gitFetchSupportsPorcelain(t) {
+			t.Skip("updating an existing PR uses 'git fetch --porcelain', which requires Git 2.41 or later")
+		}
+
+		// Simulate an upstream change that needs to be synced.
+		if err := addMockFile(s.upstream, "release-notes.md", "Bug fix "+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := s.sync(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].PR == nil || results[0].PR.Number != 1 {
+			t.Fatalf("sync %v: results = %+v, want PR 1", i, results)
+		}
+
+		// The PR branch must be in the target repo and contain the latest upstream commit.
+		upstreamCommit := gitOutput(t, s.upstream, "rev-parse", "HEAD")
+		prCommit := gitOutput(t, s.target, "rev-parse", "refs/heads/dev/auto-sync/main")
+		if prCommit != strings.TrimSpace(results[0].Commit) {
+			t.Errorf("sync %v: PR branch is %v, want synced commit %v", i, prCommit, results[0].Commit)
+		}
+		if err := runGit(s.target, "merge-base", "--is-ancestor", upstreamCommit, prCommit); err != nil {
+			t.Errorf("sync %v: PR branch doesn't contain upstream commit %v: %v", i, upstreamCommit, err)
+		}
+
+		// The PR is only approved and auto-merge is only enabled when it's created. Later syncs
+		// find auto-merge is already enabled.
+		if len(s.backend.approved) != 1 || len(s.backend.autoMerged) != 1 {
+			t.Errorf("sync %v: approved %v, auto-merged %v; want 1 each", i, s.backend.approved, s.backend.autoMerged)
+		}
+		// The existing PR's description is refreshed when it's reused.
+		if len(s.backend.updated) != i {
+			t.Errorf("sync %v: updated PRs %v, want %v updates", i, s.backend.updated, i)
+		}
+	}
+}
+
+func Test_MakeBranchPRs_ReapproveExistingPRs(t *testing.T) {
+	if !gitFetchSupportsPorcelain(t) {
+		t.Skip("updating an existing PR uses 'git fetch --porcelain', which requires Git 2.41 or later")
+	}
+	s := newSyncTest(t)
+	trueBool := true
+	s.flags.ReapproveExistingPRs = &trueBool
+
+	c := s.entry()
+	for i := range 2 {
+		if err := addMockFile(s.upstream, "release-notes.md", "Bug fix "+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.sync(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The new PR is approved once. Updating it dismisses that approval and approves again.
+	if want := []string{"PR_1"}; !reflect.DeepEqual(s.backend.dismissed, want) {
+		t.Errorf("dismissed %v, want %v", s.backend.dismissed, want)
+	}
+	if want := []string{"PR_1", "PR_1"}; !reflect.DeepEqual(s.backend.approved, want) {
+		t.Errorf("approved %v, want %v", s.backend.approved, want)
+	}
+}
+
+func Test_MakeBranchPRs_BranchPurpose(t *testing.T) {
+	s := newSyncTest(t)
+	purpose := "test-run-1"
+	s.flags.BranchPurpose = &purpose
+	s.addUpstreamChange(t)
+
+	c := s.entry()
+	if _, err := s.sync(c); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.backend.posted) != 1 || s.backend.posted[0].Head != "microsoft:dev/test-run-1/main" {
+		t.Errorf("posted PRs = %+v, want 1 PR from dev/test-run-1/main", s.backend.posted)
+	}
+	if err := runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/test-run-1/main"); err != nil {
+		t.Errorf("PR branch wasn't pushed: %v", err)
+	}
+
+	invalid := "auto sync"
+	s.flags.BranchPurpose = &invalid
+	if _, err := s.sync(c); err == nil || !strings.Contains(err.Error(), "branch-purpose") {
+		t.Errorf("MakeBranchPRs() with invalid branch-purpose error = %v, want branch-purpose error", err)
+	}
+}
+
+func Test_MakeBranchPRs_Conflicting(t *testing.T) {
+	s := newSyncTest(t)
+	s.backend.conflicting = true
+	s.addUpstreamChange(t)
+
+	results, err := s.sync(s.entry())
+	if err == nil {
+		t.Fatal("MakeBranchPRs() succeeded, want error")
+	}
+	if len(results) != 1 || !results[0].Failed {
+		t.Errorf("results = %+v, want one failed result", results)
+	}
+	if len(s.backend.autoMerged) != 0 {
+		t.Errorf("auto-merged %v, want none", s.backend.autoMerged)
+	}
+}
+
+func Test_MakeBranchPRs_AutoMergeUsePRTitle(t *testing.T) {
+	for _, usePRTitle := range []bool{false, true} {
+		t.Run("use-pr-title="+strconv.FormatBool(usePRTitle), func(t *testing.T) {
+			s := newSyncTest(t)
+			s.addUpstreamChange(t)
+
+			c := s.entry()
+			c.AutoMergeUsePRTitle = usePRTitle
+			if _, err := s.sync(c); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(s.backend.posted) != 1 || len(s.backend.autoMergeOptions) != 1 {
+				t.Fatalf("posted %v PRs and enabled auto-merge %v times, want 1 each", len(s.backend.posted), len(s.backend.autoMergeOptions))
+			}
+			var want string
+			if usePRTitle {
+				want = s.backend.posted[0].Title
+			}
+			if got := s.backend.autoMergeOptions[0].CommitHeadline; got != want {
+				t.Errorf("auto-merge commit headline = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_NoAutoMerge(t *testing.T) {
+	tests := []struct {
+		name         string
+		reviewerPAT  string
+		wantApproved int
+	}{
+		{"no reviewer", "", 0},
+		{"reviewer", "reviewer-pat", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSyncTest(t)
+			trueBool := true
+			s.flags.GitHubPATReviewer = &tt.reviewerPAT
+			s.flags.NoAutoMerge = &trueBool
+			s.addUpstreamChange(t)
+
+			results, err := s.sync(s.entry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].PR == nil {
+				t.Fatalf("results = %+v, want one result with a PR", results)
+			}
+			if len(s.backend.posted) != 1 || strings.Contains(s.backend.posted[0].Body, "enable auto-merge in") {
+				t.Errorf("posted PRs = %+v, want 1 PR that doesn't mention auto-merge", s.backend.posted)
+			}
+			if len(s.backend.approved) != tt.wantApproved {
+				t.Errorf("approved %v, want %v approvals", s.backend.approved, tt.wantApproved)
+			}
+			if len(s.backend.autoMerged) != 0 {
+				t.Errorf("auto-merged %v, want none", s.backend.autoMerged)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_MaxOpenPRs(t *testing.T) {
+	tests := []struct {
+		maxOpenPRs int
+		wantPosted bool
+	}{
+		{0, true},
+		{1, false},
+		{2, true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.maxOpenPRs), func(t *testing.T) {
+			s := newSyncTest(t)
+			maxOpenPRs := tt.maxOpenPRs
+			s.flags.MaxOpenPRs = &maxOpenPRs
+			// Another branch already has an open sync PR. The PR for a different purpose doesn't
+			// count toward the limit.
+			s.backend.prs = map[string]*gitpr.GitHubResponse{
+				"microsoft:dev/auto-sync/release-branch.go1.22": {NodeID: "PR_1", Number: 1},
+				"microsoft:dev/auto-update/main":                {NodeID: "PR_2", Number: 2},
+			}
+			s.addUpstreamChange(t)
+
+			results, err := s.sync(s.entry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if posted := len(s.backend.posted) == 1; posted != tt.wantPosted {
+				t.Fatalf("posted PRs = %+v, want posted: %v", s.backend.posted, tt.wantPosted)
+			}
+			if len(results) != 1 || (results[0].PR != nil) != tt.wantPosted {
+				t.Errorf("results = %+v, want PR: %v", results, tt.wantPosted)
+			}
+			// A deferred branch isn't pushed, so the next run can pick it up cleanly.
+			prBranchExists := runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main") == nil
+			if prBranchExists != tt.wantPosted {
+				t.Errorf("PR branch exists: %v, want %v", prBranchExists, tt.wantPosted)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_BaseBranchMissing(t *testing.T) {
+	s := newSyncTest(t)
+	s.backend.prs = map[string]*gitpr.GitHubResponse{
+		"microsoft:dev/auto-sync/main": {NodeID: "PR_1", Number: 1},
+	}
+	s.backend.missingBase = map[string]bool{"PR_1": true}
+	s.addUpstreamChange(t)
+
+	results, err := s.sync(s.entry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].PR != nil {
+		t.Errorf("results = %+v, want one result without a PR", results)
+	}
+	if len(s.backend.posted) != 0 || len(s.backend.updated) != 0 {
+		t.Errorf("posted %v and updated %v, want no PR changes", s.backend.posted, s.backend.updated)
+	}
+	if err := runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main"); err == nil {
+		t.Errorf("PR branch was pushed, want skipped")
+	}
+}
+
+func Test_MakeBranchPRs_Webhook(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusInternalServerError} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			var events []PRCreatedEvent
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var e PRCreatedEvent
+				if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+					t.Error(err)
+				}
+				events = append(events, e)
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			s := newSyncTest(t)
+			webhookURL := server.URL
+			s.flags.WebhookURL = &webhookURL
+			s.addUpstreamChange(t)
+
+			// A webhook failure must not fail the sync.
+			results, err := s.sync(s.entry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].PR == nil || results[0].Failed {
+				t.Fatalf("results = %+v, want one successful PR", results)
+			}
+
+			want := PRCreatedEvent{
+				Upstream:       s.upstream,
+				Target:         s.target,
+				UpstreamBranch: "main",
+				Branch:         "main",
+				PRURL:          results[0].PR.HTMLURL,
+				PRNumber:       results[0].PR.Number,
+			}
+			if len(events) != 1 || events[0] != want {
+				t.Errorf("webhook events = %+v, want %+v", events, want)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_NoDiff(t *testing.T) {
+	for _, noDiff := range []bool{false, true} {
+		t.Run("no-diff="+strconv.FormatBool(noDiff), func(t *testing.T) {
+			s := newSyncTest(t)
+			s.flags.NoDiff = &noDiff
+			// Make the target differ from upstream so there's a diff to show.
+			s.addTargetCommit(t, "README.microsoft.md", "fork")
+			s.addUpstreamChange(t)
+
+			if _, err := s.sync(s.entry()); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(s.backend.posted) != 1 {
+				t.Fatalf("posted %v PRs, want 1", len(s.backend.posted))
+			}
+			body := s.backend.posted[0].Body
+			if gotDiff := strings.Contains(body, "README.microsoft.md"); gotDiff == noDiff {
+				t.Errorf("PR body contains diff: %v, want %v. Body:\n%v", gotDiff, !noDiff, body)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_UpstreamLog(t *testing.T) {
+	for _, upstreamLog := range []bool{false, true} {
+		t.Run("upstream-log="+strconv.FormatBool(upstreamLog), func(t *testing.T) {
+			s := newSyncTest(t)
+			s.flags.UpstreamLog = &upstreamLog
+			s.addTargetCommit(t, "README.microsoft.md", "fork")
+			s.addUpstreamChange(t)
+			upstreamCommit := gitOutput(t, s.upstream, "rev-parse", "--short", "HEAD")
+
+			if _, err := s.sync(s.entry()); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(s.backend.posted) != 1 {
+				t.Fatalf("posted %v PRs, want 1", len(s.backend.posted))
+			}
+			body := s.backend.posted[0].Body
+			if gotLog := strings.Contains(body, upstreamCommit+" Add release-notes.md\n"); gotLog != upstreamLog {
+				t.Errorf("PR body contains upstream log: %v, want %v. Body:\n%v", gotLog, upstreamLog, body)
+			}
+			// The target's own commits aren't listed. The diff mentions the file, but not the
+			// commit message.
+			if strings.Contains(body, "Add README.microsoft.md") {
+				t.Errorf("PR body lists a target commit. Body:\n%v", body)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_PRAssignees(t *testing.T) {
+	s := newSyncTest(t)
+	// bob can't be assigned, but that doesn't stop the sync.
+	assignees := "alice, bob"
+	s.flags.PRAssignees = &assignees
+	s.backend.assignable = map[string]bool{"alice": true}
+	s.addUpstreamChange(t)
+
+	results, err := s.sync(s.entry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].PR == nil {
+		t.Fatalf("results = %+v, want one PR", results)
+	}
+	if got := s.backend.assigned[results[0].PR.Number]; !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("assigned = %v, want [alice]", got)
+	}
+}
+
+func Test_MakeBranchPRs_HeadRepo(t *testing.T) {
+	tests := []struct {
+		name    string
+		head    string
+		wantErr bool
+	}{
+		{"bot fork", "bot/go", false},
+		{"same owner", "microsoft/go-fork", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSyncTest(t)
+			head := filepath.Join(s.dir, "head") + "/" + tt.head
+			s.flags.HeadRepo = &head
+			if err := run(exec.Command("git", "clone", "--bare", s.upstream, head)); err != nil {
+				t.Fatal(err)
+			}
+			s.addUpstreamChange(t)
+
+			_, err := s.sync(s.entry())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("MakeBranchPRs() succeeded, want error for a head repo with the same owner as the target")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The PR branch is stored in the head repo, not the target, and the PR is opened
+			// against the target with a cross-fork head.
+			if err := runGit(head, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main"); err != nil {
+				t.Errorf("PR branch not pushed to head repo: %v", err)
+			}
+			if err := runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main"); err == nil {
+				t.Errorf("PR branch pushed to target repo, want only head repo")
+			}
+			if len(s.backend.posted) != 1 || s.backend.posted[0].Head != "bot:dev/auto-sync/main" {
+				t.Fatalf("posted PRs %+v, want one PR with head bot:dev/auto-sync/main", s.backend.posted)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_MirrorOnly(t *testing.T) {
+	s := newSyncTest(t)
+	trueBool := true
+	s.flags.MirrorOnly = &trueBool
+	mirror := filepath.Join(s.dir, "mirror") + "/microsoft/go-mirror"
+	if err := run(exec.Command("git", "clone", "--bare", s.upstream, mirror)); err != nil {
+		t.Fatal(err)
+	}
+	s.addUpstreamChange(t)
+
+	c := s.entry()
+	c.MirrorTarget = mirror
+	results, err := s.sync(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results != nil {
+		t.Errorf("results = %+v, want nil", results)
+	}
+
+	// The mirror is up to date with upstream, but nothing was merged into the target.
+	upstreamCommit := gitOutput(t, s.upstream, "rev-parse", "main")
+	if mirrorCommit := gitOutput(t, mirror, "rev-parse", "main"); mirrorCommit != upstreamCommit {
+		t.Errorf("mirror main = %v, want upstream main %v", mirrorCommit, upstreamCommit)
+	}
+	if err := runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main"); err == nil {
+		t.Errorf("PR branch pushed to target repo, want no merge in mirror-only mode")
+	}
+	if len(s.backend.posted) != 0 {
+		t.Errorf("posted %v PRs, want 0", len(s.backend.posted))
+	}
+
+	// An entry without a mirror can't run in mirror-only mode.
+	c.MirrorTarget = ""
+	if _, err := s.sync(c); err == nil {
+		t.Error("MakeBranchPRs() succeeded without MirrorTarget, want error")
+	}
+}
+
+func Test_MakeBranchPRs_ValidateCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		// The command runs in the sync repo after the merge, so the upstream file exists.
+		{"pass", "release-notes.md", false},
+		{"fail", "missing.md", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSyncTest(t)
+			s.addUpstreamChange(t)
+
+			c := s.entry()
+			c.ValidateCommand = []string{"git", "rev-parse", "--verify", "HEAD:" + tt.path}
+			results, err := s.sync(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MakeBranchPRs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(results) != 1 || results[0].Failed != tt.wantErr {
+				t.Fatalf("results = %+v, want one result with Failed = %v", results, tt.wantErr)
+			}
+
+			pushed := runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main") == nil
+			if pushed == tt.wantErr {
+				t.Errorf("PR branch pushed: %v, want %v", pushed, !tt.wantErr)
+			}
+			if wantPosted := !tt.wantErr; (len(s.backend.posted) == 1) != wantPosted {
+				t.Errorf("posted %v PRs, want PR: %v", len(s.backend.posted), wantPosted)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_Timeout(t *testing.T) {
+	s := newSyncTest(t)
+	// The flag overrides the entry's longer Timeout.
+	timeout := 200 * time.Millisecond
+	s.flags.EntryTimeout = &timeout
+	s.addUpstreamChange(t)
+
+	c := s.entry()
+	// Simulate a hang that the timeout must interrupt.
+	c.ValidateCommand = []string{"sleep", "60"}
+	c.Timeout = "1h"
+	start := time.Now()
+	_, err := s.sync(c)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("MakeBranchPRs() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("MakeBranchPRs() took %v, want the timeout to stop it", elapsed)
+	}
+	if runGit(s.target, "rev-parse", "--verify", "refs/heads/dev/auto-sync/main") == nil {
+		t.Error("PR branch pushed, want no push after timeout")
+	}
+	if len(s.backend.posted) != 0 {
+		t.Errorf("posted %v PRs, want none", len(s.backend.posted))
+	}
+
+	c.Timeout = "soon"
+	none := "none"
+	if _, err := MakeBranchPRs(&Flags{GitAuthString: &none}, t.TempDir(), c); err == nil {
+		t.Error("MakeBranchPRs() succeeded with an invalid Timeout, want error")
+	}
+}
+
+func Test_MakeBranchPRs_FastForwardOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		push     bool
+		diverged bool
+	}{
+		{"pr", false, false},
+		{"push", true, false},
+		{"diverged", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSyncTest(t)
+			if tt.diverged {
+				// Add a commit to the target that upstream doesn't have.
+				s.addTargetCommit(t, "README.microsoft.md", "fork")
+			}
+			s.addUpstreamChange(t)
+			targetBefore := gitOutput(t, s.target, "rev-parse", "refs/heads/main")
+
+			c := s.entry()
+			c.FastForwardOnly = true
+			c.FastForwardPush = tt.push
+			results, err := s.sync(c)
+			if tt.diverged {
+				if err == nil || !strings.Contains(err.Error(), "diverged") {
+					t.Fatalf("MakeBranchPRs() error = %v, want diverged error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			upstreamCommit := gitOutput(t, s.upstream, "rev-parse", "HEAD")
+			if len(results) != 1 || strings.TrimSpace(results[0].Commit) != upstreamCommit {
+				t.Fatalf("results = %+v, want commit %v", results, upstreamCommit)
+			}
+			targetMain := gitOutput(t, s.target, "rev-parse", "refs/heads/main")
+			if tt.push {
+				if targetMain != upstreamCommit {
+					t.Errorf("target main = %v, want upstream commit %v", targetMain, upstreamCommit)
+				}
+				if !results[0].Pushed {
+					t.Errorf("results[0].Pushed = false, want true")
+				}
+				if len(s.backend.posted) != 0 {
+					t.Errorf("posted %v PRs, want 0", len(s.backend.posted))
+				}
+				return
+			}
+			if targetMain != targetBefore {
+				t.Errorf("target main = %v, want it unchanged (%v) until the PR is merged", targetMain, targetBefore)
+			}
+			prCommit := gitOutput(t, s.target, "rev-parse", "refs/heads/dev/auto-sync/main")
+			if prCommit != upstreamCommit {
+				t.Errorf("PR branch = %v, want upstream commit %v", prCommit, upstreamCommit)
+			}
+			if len(s.backend.posted) != 1 || !strings.HasPrefix(s.backend.posted[0].Title, "Fast-forward") {
+				t.Errorf("posted PRs = %+v, want 1 fast-forward PR", s.backend.posted)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_FastForwardOnlyAfterMerge(t *testing.T) {
+	for _, advance := range []bool{false, true} {
+		t.Run("advance="+strconv.FormatBool(advance), func(t *testing.T) {
+			s := newSyncTest(t)
+			s.addUpstreamChange(t)
+			c := s.entry()
+			c.FastForwardOnly = true
+			if _, err := s.sync(c); err != nil {
+				t.Fatal(err)
+			}
+
+			// Complete the PR the way GitHub does: with a merge commit, then close the PR and
+			// delete the PR branch.
+			clone := s.cloneTarget(t)
+			if err := runGit(clone, "merge", "--no-ff", "-m", "Merge pull request #1", "origin/dev/auto-sync/main"); err != nil {
+				t.Fatal(err)
+			}
+			if err := runGit(clone, "push", "origin", "main", ":dev/auto-sync/main"); err != nil {
+				t.Fatal(err)
+			}
+			s.backend.prs = nil
+			targetMain := gitOutput(t, s.target, "rev-parse", "refs/heads/main")
+
+			if advance {
+				if err := addMockFile(s.upstream, "VERSION", "go1.99"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.backend.posted = nil
+			results, err := s.sync(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !advance {
+				if len(results) != 1 || strings.TrimSpace(results[0].Commit) != targetMain {
+					t.Fatalf("results = %+v, want up to date target commit %v", results, targetMain)
+				}
+				if len(s.backend.posted) != 0 {
+					t.Errorf("posted %v PRs, want 0", len(s.backend.posted))
+				}
+				return
+			}
+			upstreamCommit := gitOutput(t, s.upstream, "rev-parse", "HEAD")
+			if len(results) != 1 || strings.TrimSpace(results[0].Commit) != upstreamCommit {
+				t.Fatalf("results = %+v, want commit %v", results, upstreamCommit)
+			}
+			prCommit := gitOutput(t, s.target, "rev-parse", "refs/heads/dev/auto-sync/main")
+			if prCommit != upstreamCommit {
+				t.Errorf("PR branch = %v, want upstream commit %v", prCommit, upstreamCommit)
+			}
+			if len(s.backend.posted) != 1 || !strings.HasPrefix(s.backend.posted[0].Title, "Fast-forward") {
+				t.Errorf("posted PRs = %+v, want 1 fast-forward PR", s.backend.posted)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_UpToDateIfSameTree(t *testing.T) {
+	for _, sameTree := range []bool{false, true} {
+		t.Run(strconv.FormatBool(sameTree), func(t *testing.T) {
+			s := newSyncTest(t)
+			// Make the same change in the target and upstream in different commits, like a manual
+			// merge would.
+			clone := s.cloneTarget(t)
+			if err := addMockFile(clone, "release-notes.md", "Bug has been fixed"); err != nil {
+				t.Fatal(err)
+			}
+			if err := runGit(clone, "commit", "--amend", "-m", "Manually merge release notes"); err != nil {
+				t.Fatal(err)
+			}
+			if err := runGit(clone, "push", "origin", "main"); err != nil {
+				t.Fatal(err)
+			}
+			s.addUpstreamChange(t)
+			targetCommit := gitOutput(t, s.target, "rev-parse", "refs/heads/main")
+
+			c := s.entry()
+			c.FastForwardOnly = true
+			// Without FastForwardPush, a target with the same tree as upstream is always up to date.
+			c.FastForwardPush = true
+			c.UpToDateIfSameTree = sameTree
+			results, err := s.sync(c)
+			if !sameTree {
+				if err == nil || !strings.Contains(err.Error(), "diverged") {
+					t.Fatalf("MakeBranchPRs() error = %v, want diverged error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || strings.TrimSpace(results[0].Commit) != targetCommit {
+				t.Fatalf("results = %+v, want up to date target commit %v", results, targetCommit)
+			}
+			if len(s.backend.posted) != 0 {
+				t.Errorf("posted %v PRs, want 0", len(s.backend.posted))
+			}
+		})
+	}
+}
+
+// gitFetchSupportsPorcelain returns true if the installed Git supports "git fetch --porcelain",
+// added in Git 2.41.
+func gitFetchSupportsPorcelain(t *testing.T) bool {
+	version := strings.TrimPrefix(gitOutput(t, "", "version"), "git version ")
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		t.Fatalf("unable to parse Git version %q: %v", version, err)
+	}
+	return major > 2 || (major == 2 && minor >= 41)
+}
+
+func Test_MakeBranchPRs_AutoMergeMethodRequiresSubmodule(t *testing.T) {
+	c := &ConfigEntry{
+		Upstream:         "https://github.com/golang/go",
+		Target:           "https://github.com/microsoft/go",
+		AutoSyncBranches: []string{"main"},
+		AutoMergeMethod:  "squash",
+	}
+	none := "none"
+	if _, err := MakeBranchPRs(&Flags{GitAuthString: &none}, t.TempDir(), c); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := combinedOutput(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out)
+}
+
+func Test_MakeBranchPRs_ExcludePaths(t *testing.T) {
+	trueBool, falseBool := true, false
+	none := "none"
+	var emptyString string
+	flags := &Flags{
+		DryRun:          &trueBool,
+		GitAuthString:   &none,
+		InitialCloneDir: &emptyString,
+		CreateBranches:  &falseBool,
+	}
+
+	d := t.TempDir()
+	target := filepath.Join(d, "target") + "/microsoft/go"
+	upstream := filepath.Join(d, "upstream") + "/golang/go"
+	workDir := filepath.Join(d, "work")
+
+	if err := setupMockRepo(upstream, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(upstream, "codereview.cfg", "upstream"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(exec.Command("git", "clone", upstream, target)); err != nil {
+		t.Fatal(err)
+	}
+	// Both repos change codereview.cfg, causing a conflict. Upstream also adds a CI config and
+	// makes a change we want.
+	if err := addMockFile(target, "codereview.cfg", "target"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(upstream, "codereview.cfg", "upstream changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(upstream, ".ci"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(upstream, filepath.Join(".ci", "ci.yml"), "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(upstream, "release-notes.md", "Bug has been fixed"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &ConfigEntry{
+		Upstream:         upstream,
+		Target:           target,
+		BranchMap:        map[string]string{"main": "main"},
+		AutoSyncBranches: []string{"main"},
+		ExcludePaths:     []string{".ci", "codereview.cfg", "not-in-either-repo"},
+	}
+	if _, err := MakeBranchPRs(flags, workDir, c); err != nil {
+		t.Fatal(err)
+	}
+
+	ensureFileContent(t, filepath.Join(workDir, "codereview.cfg"), "target")
+	ensureFileContent(t, filepath.Join(workDir, "release-notes.md"), "Bug has been fixed")
+	ensureMissing(t, filepath.Join(workDir, ".ci"))
+}
+
+func Test_MakeBranchPRs_AutoResolveTheirsTarget(t *testing.T) {
+	trueBool, falseBool := true, false
+	none := "none"
+	var emptyString string
+	flags := &Flags{
+		DryRun:          &trueBool,
+		GitAuthString:   &none,
+		InitialCloneDir: &emptyString,
+		CreateBranches:  &falseBool,
+	}
+
+	d := t.TempDir()
+	target := filepath.Join(d, "target") + "/microsoft/go"
+	upstream := filepath.Join(d, "upstream") + "/golang/go"
+	workDir := filepath.Join(d, "work")
+
+	if err := setupMockRepo(upstream, "main"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"VERSION", "codereview.cfg"} {
+		if err := addMockFile(upstream, name, "upstream"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := run(exec.Command("git", "clone", upstream, target)); err != nil {
+		t.Fatal(err)
+	}
+	// Both repos change both files, causing conflicts. codereview.cfg is in both lists, so the
+	// Target version is kept.
+	for _, name := range []string{"VERSION", "codereview.cfg"} {
+		if err := addMockFile(target, name, "target"); err != nil {
+			t.Fatal(err)
+		}
+		if err := addMockFile(upstream, name, "upstream changed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &ConfigEntry{
+		Upstream:                upstream,
+		Target:                  target,
+		BranchMap:               map[string]string{"main": "main"},
+		AutoSyncBranches:        []string{"main"},
+		AutoResolveTarget:       []string{"codereview.cfg"},
+		AutoResolveTheirsTarget: []string{"VERSION", "codereview.cfg"},
+	}
+	if _, err := MakeBranchPRs(flags, workDir, c); err != nil {
+		t.Fatal(err)
+	}
+
+	ensureFileContent(t, filepath.Join(workDir, "VERSION"), "upstream changed")
+	ensureFileContent(t, filepath.Join(workDir, "codereview.cfg"), "target")
+}
+
+func Test_MakeBranchPRs_SignCommits(t *testing.T) {
+	keygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip("ssh-keygen not found")
+	}
+
+	d := t.TempDir()
+	keyPath := filepath.Join(d, "key")
+	if err := run(exec.Command(keygen, "-q", "-t", "ed25519", "-N", "", "-f", keyPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	trueBool, falseBool := true, false
+	none, ssh := "none", "ssh"
+	var emptyString string
+	flags := &Flags{
+		DryRun:          &trueBool,
+		GitAuthString:   &none,
+		InitialCloneDir: &emptyString,
+		CreateBranches:  &falseBool,
+		SignCommits:     &trueBool,
+		SigningKey:      &keyPath,
+		SigningFormat:   &ssh,
+	}
+
+	target := filepath.Join(d, "target") + "/microsoft/go"
+	upstream := filepath.Join(d, "upstream") + "/golang/go"
+	workDir := filepath.Join(d, "work")
+
+	if err := setupMockRepo(upstream, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(exec.Command("git", "clone", upstream, target)); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(upstream, "release-notes.md", "Bug has been fixed"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &ConfigEntry{
+		Upstream:         upstream,
+		Target:           target,
+		BranchMap:        map[string]string{"main": "main"},
+		AutoSyncBranches: []string{"main"},
+	}
+	if _, err := MakeBranchPRs(flags, workDir, c); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("git", "cat-file", "commit", "HEAD")
+	cmd.Dir = workDir
+	commitObject, err := combinedOutput(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCommitSignature(commitObject) {
+		t.Errorf("sync commit isn't signed:\n%v", commitObject)
+	}
+}
+
+func Test_MakeBranchPRs_UpstreamCommitTrailer(t *testing.T) {
+	for _, trailer := range []bool{false, true} {
+		t.Run("upstream-commit-trailer="+strconv.FormatBool(trailer), func(t *testing.T) {
+			trueBool, falseBool := true, false
+			none := "none"
+			var emptyString string
+			flags := &Flags{
+				DryRun:                &trueBool,
+				GitAuthString:         &none,
+				InitialCloneDir:       &emptyString,
+				CreateBranches:        &falseBool,
+				UpstreamCommitTrailer: &trailer,
+			}
+
+			d := t.TempDir()
+			target := filepath.Join(d, "target") + "/microsoft/go"
+			upstream := filepath.Join(d, "upstream") + "/golang/go"
+			workDir := filepath.Join(d, "work")
+
+			if err := setupMockRepo(upstream, "main"); err != nil {
+				t.Fatal(err)
+			}
+			if err := run(exec.Command("git", "clone", upstream, target)); err != nil {
+				t.Fatal(err)
+			}
+			if err := addMockFile(upstream, "release-notes.md", "Bug has been fixed"); err != nil {
+				t.Fatal(err)
+			}
+
+			c := &ConfigEntry{
+				Upstream:         upstream,
+				Target:           target,
+				BranchMap:        map[string]string{"main": "main"},
+				AutoSyncBranches: []string{"main"},
+			}
+			if _, err := MakeBranchPRs(flags, workDir, c); err != nil {
+				t.Fatal(err)
+			}
+
+			var want string
+			if trailer {
+				want = gitOutput(t, upstream, "rev-parse", "HEAD")
+			}
+			got := gitOutput(t, workDir, "log", "-1", "--format=%(trailers:key=Upstream-Commit,valueonly)")
+			if got != want {
+				t.Errorf("Upstream-Commit trailer = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_MakeBranchPRs_CommitterIdentity(t *testing.T) {
+	trueBool, falseBool := true, false
+	none := "none"
+	name, email := "Sync Bot", "sync-bot@example.org"
+	var emptyString string
+	flags := &Flags{
+		DryRun:          &trueBool,
+		GitAuthString:   &none,
+		InitialCloneDir: &emptyString,
+		CreateBranches:  &falseBool,
+		CommitterName:   &name,
+		CommitterEmail:  &email,
+	}
+
+	d := t.TempDir()
+	target := filepath.Join(d, "target") + "/microsoft/go"
+	upstream := filepath.Join(d, "upstream") + "/golang/go"
+	workDir := filepath.Join(d, "work")
+
+	if err := setupMockRepo(upstream, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(exec.Command("git", "clone", upstream, target)); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMockFile(upstream, "release-notes.md", "Bug has been fixed"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &ConfigEntry{
+		Upstream:         upstream,
+		Target:           target,
+		BranchMap:        map[string]string{"main": "main"},
+		AutoSyncBranches: []string{"main"},
+	}
+	// Git identity env vars take precedence over config. Unset them to make sure the sync commit
+	// identity comes from the flags.
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"} {
+		if v, ok := os.LookupEnv(key); ok {
+			os.Unsetenv(key)
+			t.Cleanup(func() { os.Setenv(key, v) })
+		}
+	}
+
+	if _, err := MakeBranchPRs(flags, workDir, c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := name + " <" + email + "> " + name + " <" + email + ">"
+	if got := gitOutput(t, workDir, "log", "-1", "--format=%an <%ae> %cn <%ce>"); got != want {
+		t.Errorf("sync commit identity = %q, want %q", got, want)
+	}
+}
+
 func ensureMissing(t *testing.T, path string) {
 	_, err := os.Stat(path)
 	if err != nil {
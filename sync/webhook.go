@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/microsoft/go-infra/gitpr"
+)
+
+// PRCreatedEvent is the JSON payload sync posts to the webhook URL after it creates a PR. It lets
+// an external tracker register sync PRs without scraping the sync logs.
+type PRCreatedEvent struct {
+	Upstream string
+	Target   string
+
+	// UpstreamBranch is the upstream branch that was merged.
+	UpstreamBranch string
+	// Branch is the target branch the PR merges into.
+	Branch string
+
+	PRURL    string
+	PRNumber int
+}
+
+// webhookClient sends webhook requests. A webhook is only informational, so don't let a slow
+// endpoint hold up sync for long.
+var webhookClient = &http.Client{Timeout: gitpr.DefaultHTTPTimeout}
+
+// postWebhook posts e as JSON to url. Returns an error if the request fails or the response status
+// isn't success.
+func postWebhook(url string, e *PRCreatedEvent) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook request unsuccessful, http status %v, %v", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// notifyPRCreated posts a PRCreatedEvent to the webhook URL, if one is set. A webhook failure is
+// logged rather than returned: the PR was created successfully, and the tracker isn't essential.
+func (f *Flags) notifyPRCreated(entry *ConfigEntry, b *changedBranch, pr *gitpr.GitHubResponse) {
+	if f.WebhookURL == nil || *f.WebhookURL == "" {
+		return
+	}
+	e := &PRCreatedEvent{
+		Upstream:       entry.Upstream,
+		Target:         entry.Target,
+		UpstreamBranch: b.Refs.UpstreamName,
+		Branch:         b.Refs.Name,
+		PRURL:          pr.HTMLURL,
+		PRNumber:       pr.Number,
+	}
+	fmt.Printf("---- Notifying webhook of new PR #%v...\n", pr.Number)
+	if err := postWebhook(*f.WebhookURL, e); err != nil {
+		fmt.Printf("---- Failed to notify webhook, continuing: %v\n", err)
+	}
+}